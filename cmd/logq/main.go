@@ -0,0 +1,176 @@
+//	The MIT License (MIT)
+//
+//	Copyright (c) 2016, Cagatay Dogan
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//		The above copyright notice and this permission notice shall be included in
+//		all copies or substantial portions of the Software.
+//
+//		THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//		IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//		FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//		AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//		LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//		OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+//		THE SOFTWARE.
+
+// Command logq reads the JSON line and text files written by the logmanager handlers,
+// filters the entries and writes the matching ones as JSON, text or a table.
+//
+//	logq -level 'error|fatal' -since 1h -arg user=42 -output table app.log
+//	logq -f -message "timeout" app.log
+package main
+
+import (
+    "context"
+    "flag"
+    "fmt"
+    "os"
+    "os/signal"
+    "regexp"
+    "strings"
+    "sync"
+    "time"
+    "github.com/ocdogan/goutils/logmanager"
+    "github.com/ocdogan/goutils/logquery"
+)
+
+type argFlags []*logquery.ArgPredicate
+
+func (a *argFlags) String() string {
+    return fmt.Sprint(len(*a))
+}
+
+func (a *argFlags) Set(s string) error {
+    p, err := logquery.ParseArgPredicate(s)
+    if err != nil {
+        return err
+    }
+    *a = append(*a, p)
+    return nil
+}
+
+// parseTime parses RFC3339 times and durations which are taken relative to now
+func parseTime(s string) (time.Time, error) {
+    if s == "" {
+        return time.Time{}, nil
+    }
+    if d, err := time.ParseDuration(s); err == nil {
+        return time.Now().Add(-d), nil
+    }
+    return time.Parse(time.RFC3339Nano, s)
+}
+
+func fail(err error) {
+    fmt.Fprintln(os.Stderr, "logq:", err)
+    os.Exit(2)
+}
+
+func main() {
+    var args argFlags
+
+    format := flag.String("format", "auto", "input format: auto, json or text")
+    output := flag.String("output", "text", "output format: json, text or table")
+    level := flag.String("level", "", "levels to match, e.g. error|fatal")
+    since := flag.String("since", "", "match the entries created after, RFC3339 time or duration like 1h")
+    until := flag.String("until", "", "match the entries created before, RFC3339 time or duration like 10m")
+    id := flag.String("id", "", "match the entry with the id")
+    message := flag.String("message", "", "match the messages with the regular expression")
+    follow := flag.Bool("f", false, "follow the files like tail -f")
+    interval := flag.Duration("interval", 250*time.Millisecond, "polling interval when following")
+    flag.Var(&args, "arg", "arg predicate like key, key=value, key!=value, key~regexp, key>n; can be repeated")
+    flag.Parse()
+
+    inFormat, err := logquery.ParseFormat(*format)
+    if err != nil {
+        fail(err)
+    }
+    outFormat, err := logquery.ParseOutputFormat(*output)
+    if err != nil {
+        fail(err)
+    }
+
+    q := &logquery.Query{ ID: *id, Args: args }
+    if *level != "" {
+        if q.Levels, err = logmanager.ParseLogLevel(*level); err != nil {
+            fail(err)
+        }
+    }
+    if q.Since, err = parseTime(*since); err != nil {
+        fail(err)
+    }
+    if q.Until, err = parseTime(*until); err != nil {
+        fail(err)
+    }
+    if *message != "" {
+        if q.Message, err = regexp.Compile(*message); err != nil {
+            fail(err)
+        }
+    }
+
+    printer := logquery.NewPrinter(os.Stdout, outFormat)
+    defer printer.Flush()
+
+    files := flag.Args()
+    if len(files) == 0 {
+        if *follow {
+            fail(fmt.Errorf("-f requires file names"))
+        }
+        if err = logquery.Scan(os.Stdin, inFormat, q, printer.Print); err != nil {
+            fail(err)
+        }
+        return
+    }
+
+    if !*follow {
+        for _, name := range files {
+            file, err := os.Open(name)
+            if err != nil {
+                fail(err)
+            }
+            err = logquery.Scan(file, inFormat, q, printer.Print)
+            file.Close()
+            if err != nil {
+                fail(err)
+            }
+        }
+        return
+    }
+
+    ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+    defer cancel()
+
+    var wg sync.WaitGroup
+    errs := make(chan error, len(files))
+    for _, name := range files {
+        wg.Add(1)
+        go func(name string) {
+            defer wg.Done()
+            errs <- logquery.Follow(ctx, name, inFormat, q, *interval, func(entry *logmanager.LogEntry) error {
+                if err := printer.Print(entry); err != nil {
+                    return err
+                }
+                return printer.Flush()
+            })
+        }(name)
+    }
+    wg.Wait()
+    close(errs)
+
+    var msgs []string
+    for err := range errs {
+        if err != nil {
+            msgs = append(msgs, err.Error())
+        }
+    }
+    if len(msgs) > 0 {
+        printer.Flush()
+        fail(fmt.Errorf("%s", strings.Join(msgs, "; ")))
+    }
+}
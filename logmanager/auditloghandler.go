@@ -0,0 +1,352 @@
+//	The MIT License (MIT)
+//
+//	Copyright (c) 2016, Cagatay Dogan
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//		The above copyright notice and this permission notice shall be included in
+//		all copies or substantial portions of the Software.
+//
+//		THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//		IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//		FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//		AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//		LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//		OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+//		THE SOFTWARE.
+
+package logmanager
+
+import (
+    "bufio"
+    "bytes"
+    "crypto/hmac"
+    "crypto/sha256"
+    "encoding/hex"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "hash"
+    "io"
+    "os"
+    "strconv"
+    "sync"
+    "sync/atomic"
+)
+
+const (
+    auditAnchorSuffix = ".anchor"
+)
+
+var (
+    auditGenesis = make([]byte, sha256.Size)
+)
+
+// AuditError reports a broken hash chain found while verifying an audit log
+type AuditError struct {
+    // Line is the line number of the invalid record, 0 for the anchor
+    Line int
+    // Seq is the expected sequence number
+    Seq uint64
+    // Reason describes the problem
+    Reason string
+}
+
+func (e *AuditError) Error() string {
+    if e.Line == 0 {
+        return fmt.Sprintf("logmanager: audit log anchor mismatch at seq %d: %s", e.Seq, e.Reason)
+    }
+    return fmt.Sprintf("logmanager: audit log broken at line %d, seq %d: %s", e.Line, e.Seq, e.Reason)
+}
+
+type auditAnchor struct {
+    Seq uint64 `json:"seq"`
+    Hash string `json:"hash"`
+}
+
+type auditRecord struct {
+    Seq uint64 `json:"seq"`
+    Prev string `json:"prev"`
+    Hash string `json:"hash"`
+    Entry json.RawMessage `json:"entry"`
+}
+
+// AuditLogHandler writes the entries into a tamper evident file. Every record carries
+// a sequence number and the hash of the previous record, the hash is a HMAC-SHA256
+// if a key is given or a plain SHA-256 otherwise. The last sequence number and hash are
+// persisted into the anchor file (path + ".anchor"), so VerifyAuditLog can detect
+// deleted, reordered or modified records including the ones removed from the end.
+type AuditLogHandler struct {
+    sync.Mutex
+    disabled uint32
+    name string
+    path string
+    key []byte
+    file *os.File
+    seq uint64
+    last []byte
+    data []byte
+    line []byte
+    err error
+}
+
+// NewAuditLogHandler opens the audit log at path and continues its chain
+func NewAuditLogHandler(name, path string, key []byte) (*AuditLogHandler, error) {
+    if name == "" {
+        name = "audit"
+    }
+
+    handler := &AuditLogHandler{
+        name: name,
+        path: path,
+        key: key,
+        last: auditGenesis,
+    }
+
+    anchor, err := readAuditAnchor(path)
+    switch {
+    case err == nil:
+        handler.seq = anchor.Seq
+        if handler.last, err = hex.DecodeString(anchor.Hash); err != nil {
+            return nil, err
+        }
+    case os.IsNotExist(err):
+        // no anchor yet, the chain of an existing file is verified to continue from its last record
+        seq, last, err := verifyAuditRecords(path, key)
+        if err != nil && !os.IsNotExist(err) {
+            return nil, err
+        }
+        if seq > 0 {
+            handler.seq, handler.last = seq, last
+        }
+    default:
+        return nil, err
+    }
+
+    handler.file, err = os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+    if err != nil {
+        return nil, err
+    }
+    return handler, nil
+}
+
+// Name returns the name of the handler used for registration
+func (handler *AuditLogHandler) Name() string {
+    return handler.name
+}
+
+// Enabled returns if the handler is active
+func (handler *AuditLogHandler) Enabled() bool {
+    return atomic.LoadUint32(&handler.disabled) == falseUint32
+}
+
+// Enable activates the handler
+func (handler *AuditLogHandler) Enable() {
+    atomic.StoreUint32(&handler.disabled, falseUint32)
+}
+
+// Disable deactivates the handler
+func (handler *AuditLogHandler) Disable() {
+    atomic.StoreUint32(&handler.disabled, trueUint32)
+}
+
+// Level gives if the pushed entry should be logged by the handler
+func (handler *AuditLogHandler) Level() LogLevel {
+    return AllLogLevels
+}
+
+// Format gives the format that will be used by the handler
+func (handler *AuditLogHandler) Format() LogFormat {
+    return CustomFormat
+}
+
+// QueueLen gives the queue length that will be used when the entry is queued
+func (handler *AuditLogHandler) QueueLen() int {
+    return -1
+}
+
+// Err returns the last error occurred while writing a record
+func (handler *AuditLogHandler) Err() error {
+    handler.Lock()
+    defer handler.Unlock()
+    return handler.err
+}
+
+// Process evaluates the given entry
+func (handler *AuditLogHandler) Process(entry interface{}) {
+    handler.TryProcess(entry)
+}
+
+// TryProcess evaluates the given entry and returns the error occurred
+func (handler *AuditLogHandler) TryProcess(entry interface{}) error {
+    if e, ok := entry.(*LogEntry); ok && e != nil {
+        handler.Lock()
+        defer handler.Unlock()
+
+        if err := handler.write(e); err != nil {
+            handler.err = err
+            return err
+        }
+    }
+    return nil
+}
+
+func (handler *AuditLogHandler) write(entry *LogEntry) error {
+    if handler.file == nil {
+        return os.ErrClosed
+    }
+
+    seq := handler.seq + 1
+    handler.data = entry.AppendJSON(handler.data[:0])
+    sum := auditHash(handler.key, seq, handler.last, handler.data)
+
+    line := append(handler.line[:0], `{"seq":`...)
+    line = strconv.AppendUint(line, seq, 10)
+    line = append(line, `,"prev":"`...)
+    line = hex.AppendEncode(line, handler.last)
+    line = append(line, `","hash":"`...)
+    line = hex.AppendEncode(line, sum)
+    line = append(line, `","entry":`...)
+    line = append(line, handler.data...)
+    line = append(line, "}\n"...)
+    handler.line = line
+
+    if _, err := handler.file.Write(line); err != nil {
+        return err
+    }
+    handler.seq, handler.last = seq, sum
+
+    return writeAuditAnchor(handler.path, auditAnchor{
+        Seq: seq,
+        Hash: hex.EncodeToString(sum),
+    })
+}
+
+// Close closes the audit log file
+func (handler *AuditLogHandler) Close() error {
+    handler.Lock()
+    defer handler.Unlock()
+
+    if handler.file == nil {
+        return nil
+    }
+    err := handler.file.Close()
+    handler.file = nil
+    return err
+}
+
+// VerifyAuditLog checks the hash chain of the audit log at path against its anchor
+// and returns the number of the valid records. An *AuditError is returned if a record
+// is modified, deleted or reordered.
+func VerifyAuditLog(path string, key []byte) (int, error) {
+    seq, last, err := verifyAuditRecords(path, key)
+    if err != nil {
+        return int(seq), err
+    }
+
+    anchor, err := readAuditAnchor(path)
+    if err != nil {
+        if os.IsNotExist(err) {
+            return int(seq), &AuditError{ Seq: seq, Reason: "anchor is missing" }
+        }
+        return int(seq), err
+    }
+
+    if anchor.Seq != seq {
+        return int(seq), &AuditError{ Seq: seq, Reason: fmt.Sprintf("anchor is at seq %d", anchor.Seq) }
+    }
+    if anchor.Hash != hex.EncodeToString(last) {
+        return int(seq), &AuditError{ Seq: seq, Reason: "last hash does not match the anchor" }
+    }
+    return int(seq), nil
+}
+
+func verifyAuditRecords(path string, key []byte) (uint64, []byte, error) {
+    file, err := os.Open(path)
+    if err != nil {
+        return 0, nil, err
+    }
+    defer file.Close()
+
+    seq, last := uint64(0), auditGenesis
+    reader := bufio.NewReaderSize(file, 64*1024)
+    for lineNo := 1; ; lineNo++ {
+        line, err := reader.ReadBytes('\n')
+        if len(line) == 0 && err != nil {
+            if err == io.EOF {
+                return seq, last, nil
+            }
+            return seq, last, err
+        }
+
+        var record auditRecord
+        if jerr := json.Unmarshal(line, &record); jerr != nil {
+            return seq, last, &AuditError{ Line: lineNo, Seq: seq + 1, Reason: jerr.Error() }
+        }
+        if record.Seq != seq + 1 {
+            return seq, last, &AuditError{ Line: lineNo, Seq: seq + 1,
+                Reason: fmt.Sprintf("found seq %d", record.Seq) }
+        }
+        if record.Prev != hex.EncodeToString(last) {
+            return seq, last, &AuditError{ Line: lineNo, Seq: seq + 1, Reason: "previous hash does not match" }
+        }
+
+        sum := auditHash(key, record.Seq, last, record.Entry)
+        if record.Hash != hex.EncodeToString(sum) {
+            return seq, last, &AuditError{ Line: lineNo, Seq: seq + 1, Reason: "record hash does not match" }
+        }
+        seq, last = record.Seq, sum
+
+        if err == io.EOF {
+            return seq, last, nil
+        }
+    }
+}
+
+func auditHash(key []byte, seq uint64, prev, data []byte) []byte {
+    var h hash.Hash
+    if len(key) > 0 {
+        h = hmac.New(sha256.New, key)
+    } else {
+        h = sha256.New()
+    }
+
+    var num [20]byte
+    h.Write(strconv.AppendUint(num[:0], seq, 10))
+    h.Write([]byte{ '\n' })
+    h.Write(prev)
+    h.Write([]byte{ '\n' })
+    h.Write(data)
+    return h.Sum(nil)
+}
+
+func readAuditAnchor(path string) (*auditAnchor, error) {
+    data, err := os.ReadFile(path + auditAnchorSuffix)
+    if err != nil {
+        return nil, err
+    }
+
+    anchor := &auditAnchor{}
+    if err = json.Unmarshal(bytes.TrimSpace(data), anchor); err != nil {
+        return nil, errors.New("logmanager: invalid audit anchor: " + err.Error())
+    }
+    return anchor, nil
+}
+
+func writeAuditAnchor(path string, anchor auditAnchor) error {
+    data, err := json.Marshal(anchor)
+    if err != nil {
+        return err
+    }
+
+    tmp := path + auditAnchorSuffix + ".tmp"
+    if err = os.WriteFile(tmp, data, 0600); err != nil {
+        return err
+    }
+    return os.Rename(tmp, path + auditAnchorSuffix)
+}
@@ -0,0 +1,87 @@
+//	The MIT License (MIT)
+//
+//	Copyright (c) 2016, Cagatay Dogan
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//		The above copyright notice and this permission notice shall be included in
+//		all copies or substantial portions of the Software.
+//
+//		THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//		IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//		FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//		AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//		LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//		OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+//		THE SOFTWARE.
+
+package logmanager
+
+import (
+    "bytes"
+    "fmt"
+    "os"
+    "path/filepath"
+    "testing"
+)
+
+func writeAuditLog(t *testing.T, path string, key []byte, count int) {
+    handler, err := NewAuditLogHandler("audit", path, key)
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer handler.Close()
+
+    for i := 0; i < count; i++ {
+        handler.Process(NewInfoLogEntry(fmt.Sprintf("payment %d", i), map[string]interface{}{ "amount": i }))
+    }
+    if err = handler.Err(); err != nil {
+        t.Fatal(err)
+    }
+}
+
+func TestAuditLogHandler(t *testing.T) {
+    fmt.Println("\nTestAuditLogHandler\n~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~")
+
+    key := []byte("secret")
+    path := filepath.Join(t.TempDir(), "audit.log")
+
+    writeAuditLog(t, path, key, 3)
+    // reopening continues the chain from the anchor
+    writeAuditLog(t, path, key, 2)
+
+    if n, err := VerifyAuditLog(path, key); err != nil || n != 5 {
+        t.Fatalf("expected 5 valid records, got %d: %v", n, err)
+    }
+    if _, err := VerifyAuditLog(path, []byte("other")); err == nil {
+        t.Error("expected the verification with a wrong key to fail")
+    }
+
+    original, err := os.ReadFile(path)
+    if err != nil {
+        t.Fatal(err)
+    }
+    lines := bytes.SplitAfter(original, []byte("\n"))
+
+    tampered := map[string][]byte{
+        "modified": bytes.Replace(original, []byte("payment 1"), []byte("payment 9"), 1),
+        "deleted": bytes.Join(append(lines[:1:1], lines[2:]...), nil),
+        "reordered": bytes.Join(append([][]byte{ lines[1], lines[0] }, lines[2:]...), nil),
+        "truncated": bytes.Join(lines[:4], nil),
+    }
+    for name, data := range tampered {
+        if err = os.WriteFile(path, data, 0600); err != nil {
+            t.Fatal(err)
+        }
+        if _, err = VerifyAuditLog(path, key); err == nil {
+            t.Errorf("expected the %s audit log to fail the verification", name)
+        } else if _, ok := err.(*AuditError); !ok {
+            t.Errorf("expected an AuditError for the %s audit log, got %v", name, err)
+        }
+    }
+}
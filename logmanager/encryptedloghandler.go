@@ -0,0 +1,378 @@
+//	The MIT License (MIT)
+//
+//	Copyright (c) 2016, Cagatay Dogan
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//		The above copyright notice and this permission notice shall be included in
+//		all copies or substantial portions of the Software.
+//
+//		THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//		IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//		FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//		AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//		LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//		OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+//		THE SOFTWARE.
+
+package logmanager
+
+import (
+    "bufio"
+    "crypto/aes"
+    "crypto/cipher"
+    "crypto/rand"
+    "encoding/binary"
+    "errors"
+    "fmt"
+    "io"
+    "os"
+    "sync"
+    "sync/atomic"
+)
+
+const (
+    encryptedRecordMagic = "LME1"
+    maxEncryptedKeyIDLen = 255
+    maxEncryptedRecordLen = 64*1024*1024
+)
+
+var (
+    // ErrUnknownKey is returned by a KeyProvider which does not have the requested key
+    ErrUnknownKey = errors.New("logmanager: unknown encryption key")
+    // ErrInvalidRecord is returned by the EncryptedLogReader for a corrupted record
+    ErrInvalidRecord = errors.New("logmanager: invalid encrypted log record")
+)
+
+// KeyProvider supplies the AES keys (16, 24 or 32 bytes) used by the encrypted log handler.
+// The current key is used to encrypt the new records, the older keys must still be returned
+// by Key to decrypt the records written before a rotation.
+type KeyProvider interface {
+    // CurrentKey returns the id and the value of the key used for encryption
+    CurrentKey() (id string, key []byte, err error)
+    // Key returns the key with the given id
+    Key(id string) ([]byte, error)
+}
+
+// StaticKeyProvider is an in memory KeyProvider, rotating is done by adding a new key
+// and making it current
+type StaticKeyProvider struct {
+    sync.RWMutex
+    current string
+    keys map[string][]byte
+}
+
+// NewStaticKeyProvider creates a key provider with the given current key
+func NewStaticKeyProvider(id string, key []byte) *StaticKeyProvider {
+    return &StaticKeyProvider{
+        current: id,
+        keys: map[string][]byte{ id: key },
+    }
+}
+
+// Rotate adds the key and makes it the current key
+func (kp *StaticKeyProvider) Rotate(id string, key []byte) {
+    kp.Lock()
+    defer kp.Unlock()
+    kp.keys[id] = key
+    kp.current = id
+}
+
+// CurrentKey returns the id and the value of the key used for encryption
+func (kp *StaticKeyProvider) CurrentKey() (string, []byte, error) {
+    kp.RLock()
+    defer kp.RUnlock()
+    return kp.current, kp.keys[kp.current], nil
+}
+
+// Key returns the key with the given id
+func (kp *StaticKeyProvider) Key(id string) ([]byte, error) {
+    kp.RLock()
+    defer kp.RUnlock()
+    if key, ok := kp.keys[id]; ok {
+        return key, nil
+    }
+    return nil, ErrUnknownKey
+}
+
+// EncryptedLogHandler appends every text or JSON payload produced by the bucket to a file as
+// a separate AES-GCM encrypted record. Each record has a header with the id of the key it is
+// encrypted with, so the keys can be rotated without re-encrypting the older records:
+//
+//	magic "LME1" | key id length (1 byte) | key id | nonce (12 bytes) | ciphertext length (4 bytes) | ciphertext
+//
+// The header is authenticated as additional data.
+type EncryptedLogHandler struct {
+    sync.Mutex
+    disabled uint32
+    name string
+    format LogFormat
+    keys KeyProvider
+    file *os.File
+    aeads map[string]cipher.AEAD
+    // header is the scratch buffer of the record header, kept apart from buf since Seal appends to buf
+    header []byte
+    buf []byte
+    err error
+}
+
+// NewEncryptedLogHandler creates a handler which appends the encrypted records to the file at path,
+// format should be either TextFormat or JSONFormat
+func NewEncryptedLogHandler(name, path string, format LogFormat, keys KeyProvider) (*EncryptedLogHandler, error) {
+    if keys == nil {
+        return nil, ErrUnknownKey
+    }
+    if format != TextFormat {
+        format = JSONFormat
+    }
+    if name == "" {
+        name = "encrypted"
+    }
+
+    file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+    if err != nil {
+        return nil, err
+    }
+
+    return &EncryptedLogHandler{
+        name: name,
+        format: format,
+        keys: keys,
+        file: file,
+        aeads: make(map[string]cipher.AEAD),
+    }, nil
+}
+
+// Name returns the name of the handler used for registration
+func (handler *EncryptedLogHandler) Name() string {
+    return handler.name
+}
+
+// Enabled returns if the handler is active
+func (handler *EncryptedLogHandler) Enabled() bool {
+    return atomic.LoadUint32(&handler.disabled) == falseUint32
+}
+
+// Enable activates the handler
+func (handler *EncryptedLogHandler) Enable() {
+    atomic.StoreUint32(&handler.disabled, falseUint32)
+}
+
+// Disable deactivates the handler
+func (handler *EncryptedLogHandler) Disable() {
+    atomic.StoreUint32(&handler.disabled, trueUint32)
+}
+
+// Level gives if the pushed entry should be logged by the handler
+func (handler *EncryptedLogHandler) Level() LogLevel {
+    return AllLogLevels
+}
+
+// Format gives the format that will be used by the handler
+func (handler *EncryptedLogHandler) Format() LogFormat {
+    return handler.format
+}
+
+// QueueLen gives the queue length that will be used when the entry is queued
+func (handler *EncryptedLogHandler) QueueLen() int {
+    return -1
+}
+
+// Err returns the last error occurred while writing a record
+func (handler *EncryptedLogHandler) Err() error {
+    handler.Lock()
+    defer handler.Unlock()
+    return handler.err
+}
+
+// Process evaluates the given entry
+func (handler *EncryptedLogHandler) Process(entry interface{}) {
+    handler.TryProcess(entry)
+}
+
+// TryProcess evaluates the given entry and returns the error occurred
+func (handler *EncryptedLogHandler) TryProcess(entry interface{}) error {
+    if data, ok := entry.([]byte); ok && len(data) > 0 {
+        handler.Lock()
+        defer handler.Unlock()
+
+        if err := handler.write(data); err != nil {
+            handler.err = err
+            return err
+        }
+    }
+    return nil
+}
+
+func (handler *EncryptedLogHandler) write(data []byte) error {
+    if handler.file == nil {
+        return os.ErrClosed
+    }
+
+    id, key, err := handler.keys.CurrentKey()
+    if err != nil {
+        return err
+    }
+    if len(id) == 0 || len(id) > maxEncryptedKeyIDLen {
+        return fmt.Errorf("logmanager: invalid encryption key id %q", id)
+    }
+
+    aead, ok := handler.aeads[id]
+    if !ok {
+        if aead, err = newLogAEAD(key); err != nil {
+            return err
+        }
+        handler.aeads[id] = aead
+    }
+
+    header := append(handler.header[:0], encryptedRecordMagic...)
+    header = append(header, byte(len(id)))
+    header = append(header, id...)
+
+    nonceStart := len(header)
+    header = append(header, make([]byte, aead.NonceSize())...)
+    if _, err = rand.Read(header[nonceStart:]); err != nil {
+        return err
+    }
+    nonceEnd := len(header)
+
+    header = binary.BigEndian.AppendUint32(header, uint32(len(data) + aead.Overhead()))
+    handler.header = header
+
+    buf := append(handler.buf[:0], header...)
+    buf = aead.Seal(buf, header[nonceStart:nonceEnd], data, header)
+    handler.buf = buf
+
+    _, err = handler.file.Write(buf)
+    return err
+}
+
+// Close closes the encrypted log file
+func (handler *EncryptedLogHandler) Close() error {
+    handler.Lock()
+    defer handler.Unlock()
+
+    if handler.file == nil {
+        return nil
+    }
+    err := handler.file.Close()
+    handler.file = nil
+    return err
+}
+
+func newLogAEAD(key []byte) (cipher.AEAD, error) {
+    block, err := aes.NewCipher(key)
+    if err != nil {
+        return nil, err
+    }
+    return cipher.NewGCM(block)
+}
+
+// EncryptedLogReader decrypts the records written by the EncryptedLogHandler
+type EncryptedLogReader struct {
+    reader *bufio.Reader
+    keys KeyProvider
+    aeads map[string]cipher.AEAD
+    keyID string
+}
+
+// NewEncryptedLogReader creates a reader which decrypts the records read from r
+func NewEncryptedLogReader(r io.Reader, keys KeyProvider) *EncryptedLogReader {
+    return &EncryptedLogReader{
+        reader: bufio.NewReader(r),
+        keys: keys,
+        aeads: make(map[string]cipher.AEAD),
+    }
+}
+
+// KeyID returns the key id of the last record read
+func (r *EncryptedLogReader) KeyID() string {
+    return r.keyID
+}
+
+// Next returns the next decrypted payload, io.EOF is returned at the end of the input
+func (r *EncryptedLogReader) Next() ([]byte, error) {
+    header := make([]byte, len(encryptedRecordMagic) + 1, 64)
+    if _, err := io.ReadFull(r.reader, header); err != nil {
+        if err == io.ErrUnexpectedEOF {
+            return nil, ErrInvalidRecord
+        }
+        return nil, err
+    }
+    if string(header[:len(encryptedRecordMagic)]) != encryptedRecordMagic {
+        return nil, ErrInvalidRecord
+    }
+
+    idLen := int(header[len(encryptedRecordMagic)])
+    start := len(header)
+    header = append(header, make([]byte, idLen)...)
+    if _, err := io.ReadFull(r.reader, header[start:]); err != nil {
+        return nil, ErrInvalidRecord
+    }
+    id := string(header[start:])
+
+    aead, ok := r.aeads[id]
+    if !ok {
+        key, err := r.keys.Key(id)
+        if err != nil {
+            return nil, err
+        }
+        if aead, err = newLogAEAD(key); err != nil {
+            return nil, err
+        }
+        r.aeads[id] = aead
+    }
+
+    start = len(header)
+    header = append(header, make([]byte, aead.NonceSize() + 4)...)
+    if _, err := io.ReadFull(r.reader, header[start:]); err != nil {
+        return nil, ErrInvalidRecord
+    }
+    nonce := header[start:start+aead.NonceSize()]
+
+    size := binary.BigEndian.Uint32(header[len(header)-4:])
+    if size < uint32(aead.Overhead()) || size > maxEncryptedRecordLen {
+        return nil, ErrInvalidRecord
+    }
+
+    ciphertext := make([]byte, size)
+    if _, err := io.ReadFull(r.reader, ciphertext); err != nil {
+        return nil, ErrInvalidRecord
+    }
+
+    plain, err := aead.Open(ciphertext[:0], nonce, ciphertext, header)
+    if err != nil {
+        return nil, ErrInvalidRecord
+    }
+
+    r.keyID = id
+    return plain, nil
+}
+
+// DecryptLogFile decrypts the encrypted log at path and writes the payloads into w line by line
+func DecryptLogFile(path string, keys KeyProvider, w io.Writer) error {
+    file, err := os.Open(path)
+    if err != nil {
+        return err
+    }
+    defer file.Close()
+
+    reader := NewEncryptedLogReader(file, keys)
+    for {
+        data, err := reader.Next()
+        if err == io.EOF {
+            return nil
+        }
+        if err != nil {
+            return err
+        }
+        if _, err = w.Write(append(data, '\n')); err != nil {
+            return err
+        }
+    }
+}
@@ -0,0 +1,73 @@
+//	The MIT License (MIT)
+//
+//	Copyright (c) 2016, Cagatay Dogan
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//		The above copyright notice and this permission notice shall be included in
+//		all copies or substantial portions of the Software.
+//
+//		THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//		IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//		FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//		AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//		LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//		OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+//		THE SOFTWARE.
+
+package logmanager
+
+import (
+    "bytes"
+    "fmt"
+    "os"
+    "path/filepath"
+    "testing"
+)
+
+func TestEncryptedLogHandler(t *testing.T) {
+    fmt.Println("\nTestEncryptedLogHandler\n~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~")
+
+    path := filepath.Join(t.TempDir(), "secret.log")
+    keys := NewStaticKeyProvider("k1", bytes.Repeat([]byte{ 1 }, 32))
+
+    handler, err := NewEncryptedLogHandler("secret", path, JSONFormat, keys)
+    if err != nil {
+        t.Fatal(err)
+    }
+    handler.Process([]byte(`{"message":"card 4111"}`))
+    keys.Rotate("k2", bytes.Repeat([]byte{ 2 }, 16))
+    handler.Process([]byte(`{"message":"after rotation"}`))
+    if err = handler.Close(); err != nil || handler.Err() != nil {
+        t.Fatal(err, handler.Err())
+    }
+
+    data, err := os.ReadFile(path)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if bytes.Contains(data, []byte("4111")) {
+        t.Fatal("the payload is written in plain text")
+    }
+
+    out := &bytes.Buffer{}
+    if err = DecryptLogFile(path, keys, out); err != nil {
+        t.Fatal(err)
+    }
+    if expected := "{\"message\":\"card 4111\"}\n{\"message\":\"after rotation\"}\n"; out.String() != expected {
+        t.Errorf("expected %q, got %q", expected, out.String())
+    }
+
+    data[len(data)-1] ^= 0xff
+    if err = os.WriteFile(path, data, 0600); err != nil {
+        t.Fatal(err)
+    }
+    if err = DecryptLogFile(path, keys, &bytes.Buffer{}); err != ErrInvalidRecord {
+        t.Errorf("expected ErrInvalidRecord for a modified record, got %v", err)
+    }
+}
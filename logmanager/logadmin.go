@@ -0,0 +1,329 @@
+//	The MIT License (MIT)
+//
+//	Copyright (c) 2016, Cagatay Dogan
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//		The above copyright notice and this permission notice shall be included in
+//		all copies or substantial portions of the Software.
+//
+//		THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//		IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//		FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//		AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//		LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//		OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+//		THE SOFTWARE.
+
+package logmanager
+
+import (
+    "encoding/json"
+    "net/http"
+    "strconv"
+    "strings"
+    "sync"
+    "sync/atomic"
+)
+
+const (
+    tapQueueLen = 256
+)
+
+var (
+    tapMtx = &sync.Mutex{}
+    taps atomic.Value
+)
+
+// entryTap receives the JSON formatted entries for a stream subscriber
+type entryTap struct {
+    ch chan []byte
+    level LogLevel
+    category string
+    dropped uint64
+}
+
+func currentTaps() []*entryTap {
+    t, _ := taps.Load().([]*entryTap)
+    return t
+}
+
+func subscribe(level LogLevel, category string) *entryTap {
+    tap := &entryTap{
+        ch: make(chan []byte, tapQueueLen),
+        level: level,
+        category: category,
+    }
+
+    tapMtx.Lock()
+    defer tapMtx.Unlock()
+
+    current := currentTaps()
+    taps.Store(append(current[:len(current):len(current)], tap))
+    UpdateEnabledLevels()
+    return tap
+}
+
+func unsubscribe(tap *entryTap) {
+    tapMtx.Lock()
+    defer tapMtx.Unlock()
+
+    current := currentTaps()
+    result := make([]*entryTap, 0, len(current))
+    for _, t := range current {
+        if t != tap {
+            result = append(result, t)
+        }
+    }
+    taps.Store(result)
+    UpdateEnabledLevels()
+}
+
+func (tap *entryTap) matches(entry *LogEntry) bool {
+    return tap.level.Has(entry.level) && 
+        (tap.category == "" || categoryMatches(tap.category, entry.category))
+}
+
+// tapped returns if any of the taps receives the entry
+func tapped(taps []*entryTap, entry *LogEntry) bool {
+    for _, tap := range taps {
+        if tap.matches(entry) {
+            return true
+        }
+    }
+    return false
+}
+
+// publish sends the resolved entry to the matching taps, the entries are dropped for the slow subscribers
+func publish(taps []*entryTap, entry *LogEntry, bufs *[logFormatCount]*logBuffer) {
+    for _, tap := range taps {
+        if !tap.matches(entry) {
+            continue
+        }
+
+        buf := encodeOnce(bufs, entry, JSONFormat)
+        select {
+        case tap.ch <- append([]byte(nil), buf.b...):
+        default:
+            atomic.AddUint64(&tap.dropped, 1)
+        }
+    }
+}
+
+// AdminSettings are the global settings changeable over the admin handler
+type AdminSettings struct {
+    Enabled bool `json:"enabled"`
+    Stacktrace bool `json:"stacktrace"`
+    BucketCapacity uint32 `json:"bucket_capacity"`
+}
+
+type adminHandler struct {
+    prefix string
+}
+
+// NewAdminHandler creates an http.Handler to inspect and change the log manager at runtime.
+// The paths are relative to the given prefix the handler is mounted on:
+//
+//     GET    buckets                     the registered handlers with their stats
+//     POST   buckets/{name}/enable       enables the handler
+//     POST   buckets/{name}/disable      disables the handler
+//     POST   buckets/{name}/level        overrides the handler level with the level form value, empty restores it
+//     GET    categories                  the category levels
+//     POST   categories/level            sets the level of the category form value
+//     DELETE categories/level            resets the level of the category form value
+//     GET    settings                    the global settings
+//     POST   settings                    changes the enabled, stacktrace and bucket_capacity form values given
+//     GET    stream                      streams the entries as Server-Sent Events, filtered by level and category
+func NewAdminHandler(prefix string) http.Handler {
+    return &adminHandler{ prefix: strings.TrimSuffix(prefix, "/") }
+}
+
+func (handler *adminHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+    path := strings.Trim(strings.TrimPrefix(r.URL.Path, handler.prefix), "/")
+    parts := strings.Split(path, "/")
+
+    switch {
+    case path == "buckets":
+        if handler.allow(w, r, http.MethodGet) {
+            handler.reply(w, Stats())
+        }
+    case len(parts) == 3 && parts[0] == "buckets":
+        handler.serveBucket(w, r, parts[1], parts[2])
+    case path == "categories":
+        if handler.allow(w, r, http.MethodGet) {
+            handler.reply(w, handler.categories())
+        }
+    case path == "categories/level":
+        handler.serveCategoryLevel(w, r)
+    case path == "settings":
+        handler.serveSettings(w, r)
+    case path == "stream":
+        if handler.allow(w, r, http.MethodGet) {
+            handler.serveStream(w, r)
+        }
+    default:
+        http.NotFound(w, r)
+    }
+}
+
+func (handler *adminHandler) allow(w http.ResponseWriter, r *http.Request, methods ...string) bool {
+    for _, method := range methods {
+        if r.Method == method {
+            return true
+        }
+    }
+    w.Header().Set("Allow", strings.Join(methods, ", "))
+    http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+    return false
+}
+
+func (handler *adminHandler) reply(w http.ResponseWriter, v interface{}) {
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(v)
+}
+
+func (handler *adminHandler) serveBucket(w http.ResponseWriter, r *http.Request, name, action string) {
+    if !handler.allow(w, r, http.MethodPost) {
+        return
+    }
+
+    var ok bool
+    switch action {
+    case "enable":
+        ok = EnableHandler(name)
+    case "disable":
+        ok = DisableHandler(name)
+    case "level":
+        level := LogLevel(0)
+        if s := r.FormValue("level"); s != "" {
+            var err error
+            if level, err = ParseLogLevel(s); err != nil {
+                http.Error(w, err.Error(), http.StatusBadRequest)
+                return
+            }
+        }
+        ok = SetHandlerLevel(name, level)
+    default:
+        http.NotFound(w, r)
+        return
+    }
+
+    if !ok {
+        http.Error(w, "logmanager: unknown handler " + name, http.StatusNotFound)
+        return
+    }
+    handler.reply(w, Stats())
+}
+
+func (handler *adminHandler) categories() map[string]string {
+    result := make(map[string]string)
+    for category, level := range CategoryLevels() {
+        result[category] = level.String()
+    }
+    return result
+}
+
+func (handler *adminHandler) serveCategoryLevel(w http.ResponseWriter, r *http.Request) {
+    if !handler.allow(w, r, http.MethodPost, http.MethodDelete) {
+        return
+    }
+
+    category := r.FormValue("category")
+    if r.Method == http.MethodDelete {
+        ResetCategoryLevel(category)
+    } else {
+        level, err := ParseLogLevel(r.FormValue("level"))
+        if err != nil {
+            http.Error(w, err.Error(), http.StatusBadRequest)
+            return
+        }
+        SetCategoryLevel(category, level)
+    }
+    handler.reply(w, handler.categories())
+}
+
+func (handler *adminHandler) serveSettings(w http.ResponseWriter, r *http.Request) {
+    if !handler.allow(w, r, http.MethodGet, http.MethodPost) {
+        return
+    }
+
+    if r.Method == http.MethodPost {
+        if s := r.FormValue("enabled"); s != "" {
+            on, err := strconv.ParseBool(s)
+            if err != nil {
+                http.Error(w, err.Error(), http.StatusBadRequest)
+                return
+            }
+            if on {
+                Enable()
+            } else {
+                Disable()
+            }
+        }
+        if s := r.FormValue("stacktrace"); s != "" {
+            on, err := strconv.ParseBool(s)
+            if err != nil {
+                http.Error(w, err.Error(), http.StatusBadRequest)
+                return
+            }
+            if on {
+                EnableStacktrace()
+            } else {
+                DisableStacktrace()
+            }
+        }
+        if s := r.FormValue("bucket_capacity"); s != "" {
+            cap, err := strconv.ParseUint(s, 10, 32)
+            if err != nil {
+                http.Error(w, err.Error(), http.StatusBadRequest)
+                return
+            }
+            SetBucketCapacity(uint32(cap))
+        }
+    }
+
+    handler.reply(w, AdminSettings{
+        Enabled: Enabled(),
+        Stacktrace: StacktraceEnabled(),
+        BucketCapacity: BucketCapacity(),
+    })
+}
+
+func (handler *adminHandler) serveStream(w http.ResponseWriter, r *http.Request) {
+    flusher, ok := w.(http.Flusher)
+    if !ok {
+        http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+        return
+    }
+
+    level, err := ParseLogLevel(r.FormValue("level"))
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+
+    tap := subscribe(level, r.FormValue("category"))
+    defer unsubscribe(tap)
+
+    w.Header().Set("Content-Type", "text/event-stream")
+    w.Header().Set("Cache-Control", "no-cache")
+    w.WriteHeader(http.StatusOK)
+    flusher.Flush()
+
+    for {
+        select {
+        case <-r.Context().Done():
+            return
+        case data := <-tap.ch:
+            w.Write([]byte("event: entry\ndata: "))
+            w.Write(data)
+            w.Write([]byte("\n\n"))
+            flusher.Flush()
+        }
+    }
+}
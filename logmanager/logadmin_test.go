@@ -0,0 +1,134 @@
+//	The MIT License (MIT)
+//
+//	Copyright (c) 2016, Cagatay Dogan
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//		The above copyright notice and this permission notice shall be included in
+//		all copies or substantial portions of the Software.
+//
+//		THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//		IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//		FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//		AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//		LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//		OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+//		THE SOFTWARE.
+
+package logmanager
+
+import (
+    "bufio"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "net/http"
+    "net/http/httptest"
+    "net/url"
+    "strings"
+    "testing"
+    "time"
+)
+
+func TestAdminHandler(t *testing.T) {
+    fmt.Println("\nTestAdminHandler\n~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~")
+
+    RegisterHandler(&discardLogHandler{ name: "admin-test", format: JSONFormat })
+
+    server := httptest.NewServer(NewAdminHandler("/debug/log/"))
+    defer server.Close()
+    base := server.URL + "/debug/log/"
+
+    var stats []BucketStats
+    post(t, base + "buckets/admin-test/level", url.Values{ "level": { "error|fatal" } }, &stats)
+    found := false
+    for _, s := range stats {
+        if s.Name == "admin-test" {
+            found = s.Level == "error|fatal" && s.Format == "json"
+        }
+    }
+    if !found {
+        t.Errorf("expected the level override in the stats, got %+v", stats)
+    }
+    SetHandlerLevel("admin-test", 0)
+
+    resp, err := http.PostForm(base + "buckets/unknown/enable", nil)
+    if err != nil {
+        t.Fatal(err)
+    }
+    resp.Body.Close()
+    if resp.StatusCode != http.StatusNotFound {
+        t.Errorf("expected 404 for an unknown handler, got %d", resp.StatusCode)
+    }
+
+    var categories map[string]string
+    post(t, base + "categories/level", url.Values{ "category": { "admin" }, "level": { "warning" } }, &categories)
+    defer ResetCategoryLevel("admin")
+    if categories["admin"] != "warning" {
+        t.Errorf("expected the admin category level, got %v", categories)
+    }
+
+    var settings AdminSettings
+    capacity := BucketCapacity()
+    post(t, base + "settings", url.Values{ "stacktrace": { "true" }, "bucket_capacity": { "64" } }, &settings)
+    defer SetBucketCapacity(capacity)
+    defer DisableStacktrace()
+    if !settings.Enabled || !settings.Stacktrace || settings.BucketCapacity != 64 {
+        t.Errorf("unexpected settings %+v", settings)
+    }
+    DisableStacktrace()
+
+    stream, err := http.Get(base + "stream?level=error")
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer stream.Body.Close()
+
+    go func() {
+        for i := 0; i < 50 && len(currentTaps()) == 0; i++ {
+            time.Sleep(10*time.Millisecond)
+        }
+        LogMessage("not streamed", nil)
+        LogError(errors.New("streamed"), nil)
+    }()
+
+    done := make(chan string, 1)
+    go func() {
+        scanner := bufio.NewScanner(stream.Body)
+        for scanner.Scan() {
+            if line := scanner.Text(); strings.HasPrefix(line, "data: ") {
+                done <- line
+                return
+            }
+        }
+    }()
+
+    select {
+    case line := <-done:
+        if !strings.Contains(line, `"message":"streamed"`) {
+            t.Errorf("unexpected streamed entry %s", line)
+        }
+    case <-time.After(2*time.Second):
+        t.Fatal("timeout waiting for the streamed entry")
+    }
+}
+
+func post(t *testing.T, url string, values url.Values, v interface{}) {
+    resp, err := http.PostForm(url, values)
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer resp.Body.Close()
+
+    if resp.StatusCode != http.StatusOK {
+        t.Fatalf("%s returned %s", url, resp.Status)
+    }
+    if err = json.NewDecoder(resp.Body).Decode(v); err != nil {
+        t.Fatal(err)
+    }
+}
@@ -0,0 +1,247 @@
+//	The MIT License (MIT)
+//
+//	Copyright (c) 2016, Cagatay Dogan
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//		The above copyright notice and this permission notice shall be included in
+//		all copies or substantial portions of the Software.
+//
+//		THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//		IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//		FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//		AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//		LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//		OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+//		THE SOFTWARE.
+
+package logmanager
+
+import (
+    "errors"
+    "fmt"
+    "sync"
+    "sync/atomic"
+    "time"
+)
+
+// CircuitState is the state of the circuit breaker of a bucket
+type CircuitState byte
+
+const (
+    // CircuitClosed is the normal state, the entries are delivered to the handler
+    CircuitClosed CircuitState = iota
+    // CircuitOpen is the state after the handler fails consecutively, the entries are not delivered
+    CircuitOpen
+    // CircuitHalfOpen is the state while the handler is probed to close the circuit again
+    CircuitHalfOpen
+)
+
+// CircuitPolicy defines what happens to the entries while the circuit is open
+type CircuitPolicy byte
+
+const (
+    // CircuitBuffer keeps the entries in the bucket queue, the oldest entries are 
+    // dropped when the queue is full
+    CircuitBuffer CircuitPolicy = iota
+    // CircuitDrop drops the entries
+    CircuitDrop
+)
+
+const (
+    defaultCircuitThreshold = 5
+    defaultCircuitProbeInterval = 5*time.Second
+)
+
+var (
+    circuitOptions atomic.Value
+
+    // ErrCircuitOpen is reported by the health check for the handlers whose circuit is open
+    ErrCircuitOpen = errors.New("logmanager: circuit open")
+)
+
+// FallibleLogHandler is implemented by the handlers which report the failures of processing 
+// an entry, the buckets call TryProcess instead of Process and open their circuit breakers 
+// after consecutive failures
+type FallibleLogHandler interface {
+    LogHandler
+    // TryProcess evaluates the given entry like Process and returns the error occurred
+    TryProcess(entry interface{}) error
+}
+
+// HealthChecker is implemented by the handlers which can check their destinations, 
+// it is used by HealthCheck and to probe an open circuit
+type HealthChecker interface {
+    // Healthy returns nil if the handler can deliver entries
+    Healthy() error
+}
+
+// CircuitBreakerOptions configure the circuit breakers of the buckets
+type CircuitBreakerOptions struct {
+    // Threshold is the number of the consecutive failures which opens the circuit, 0 disables the breaker
+    Threshold int
+    // ProbeInterval is the time to wait before probing an open circuit
+    ProbeInterval time.Duration
+    // Policy defines what happens to the entries while the circuit is open
+    Policy CircuitPolicy
+}
+
+// DefaultCircuitBreakerOptions returns the options used if SetCircuitBreakerOptions is not called
+func DefaultCircuitBreakerOptions() CircuitBreakerOptions {
+    return CircuitBreakerOptions{
+        Threshold: defaultCircuitThreshold,
+        ProbeInterval: defaultCircuitProbeInterval,
+        Policy: CircuitBuffer,
+    }
+}
+
+// SetCircuitBreakerOptions sets the circuit breaker options of all the buckets
+func SetCircuitBreakerOptions(options CircuitBreakerOptions) {
+    if options.ProbeInterval <= 0 {
+        options.ProbeInterval = defaultCircuitProbeInterval
+    }
+    circuitOptions.Store(options)
+}
+
+// CurrentCircuitBreakerOptions returns the circuit breaker options of the buckets
+func CurrentCircuitBreakerOptions() CircuitBreakerOptions {
+    if options, ok := circuitOptions.Load().(CircuitBreakerOptions); ok {
+        return options
+    }
+    return DefaultCircuitBreakerOptions()
+}
+
+func (s CircuitState) String() string {
+    switch s {
+    case CircuitOpen:
+        return "open"
+    case CircuitHalfOpen:
+        return "half-open"
+    }
+    return "closed"
+}
+
+// circuitBreaker tracks the consecutive failures of a bucket handler
+type circuitBreaker struct {
+    sync.Mutex
+    state CircuitState
+    failures int
+    lastErr error
+    probeAt time.Time
+}
+
+// allow returns if an entry can be delivered, probing the handler if the circuit is open and the probe is due,
+// the health check of the handler runs without the lock since it may take as long as a connection attempt
+func (breaker *circuitBreaker) allow(handler LogHandler, options CircuitBreakerOptions) bool {
+    breaker.Lock()
+    switch breaker.state {
+    case CircuitClosed, CircuitHalfOpen:
+        breaker.Unlock()
+        return true
+    }
+
+    if time.Now().Before(breaker.probeAt) {
+        breaker.Unlock()
+        return false
+    }
+
+    // let the next entry through as the probe, its result closes or opens the circuit again
+    breaker.state = CircuitHalfOpen
+    breaker.Unlock()
+
+    if checker, ok := handler.(HealthChecker); ok {
+        if err := checker.Healthy(); err != nil {
+            breaker.Lock()
+            breaker.open(err, options)
+            breaker.Unlock()
+            return false
+        }
+    }
+    return true
+}
+
+// report records the result of delivering an entry
+func (breaker *circuitBreaker) report(err error, options CircuitBreakerOptions) {
+    breaker.Lock()
+    defer breaker.Unlock()
+
+    if err == nil {
+        if breaker.state != CircuitClosed || breaker.failures > 0 {
+            breaker.close()
+        }
+        return
+    }
+
+    breaker.failures++
+    breaker.lastErr = err
+    if breaker.state == CircuitHalfOpen || 
+        (options.Threshold > 0 && breaker.failures >= options.Threshold) {
+        breaker.open(err, options)
+    }
+}
+
+func (breaker *circuitBreaker) open(err error, options CircuitBreakerOptions) {
+    breaker.state = CircuitOpen
+    breaker.lastErr = err
+    breaker.probeAt = time.Now().Add(options.ProbeInterval)
+}
+
+func (breaker *circuitBreaker) close() {
+    breaker.state = CircuitClosed
+    breaker.failures = 0
+}
+
+// wait returns the time left to the next probe, 0 if the circuit is not open
+func (breaker *circuitBreaker) wait() time.Duration {
+    breaker.Lock()
+    defer breaker.Unlock()
+
+    if breaker.state != CircuitOpen {
+        return 0
+    }
+    if wait := time.Until(breaker.probeAt); wait > 0 {
+        return wait
+    }
+    return time.Millisecond
+}
+
+func (breaker *circuitBreaker) status() (CircuitState, int, error) {
+    breaker.Lock()
+    defer breaker.Unlock()
+    return breaker.state, breaker.failures, breaker.lastErr
+}
+
+// HealthCheck returns the errors of the unhealthy handlers by their registration names. 
+// A handler is unhealthy if its circuit is open or its Healthy method returns an error.
+func HealthCheck() map[string]error {
+    bucketMtx.Lock()
+    checked := make(map[string]*logBucket, len(buckets))
+    for name, bucket := range buckets {
+        checked[name] = bucket
+    }
+    bucketMtx.Unlock()
+
+    result := make(map[string]error)
+    for name, bucket := range checked {
+        if state, _, err := bucket.breaker.status(); state == CircuitOpen {
+            if err == nil {
+                err = ErrCircuitOpen
+            } else {
+                err = fmt.Errorf("%w: %v", ErrCircuitOpen, err)
+            }
+            result[name] = err
+            continue
+        }
+        if checker, ok := bucket.handler.(HealthChecker); ok {
+            if err := checker.Healthy(); err != nil {
+                result[name] = err
+            }
+        }
+    }
+    return result
+}
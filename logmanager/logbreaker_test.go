@@ -0,0 +1,168 @@
+//	The MIT License (MIT)
+//
+//	Copyright (c) 2016, Cagatay Dogan
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//		The above copyright notice and this permission notice shall be included in
+//		all copies or substantial portions of the Software.
+//
+//		THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//		IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//		FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//		AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//		LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//		OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+//		THE SOFTWARE.
+
+package logmanager
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "testing"
+    "time"
+)
+
+type flakyLogHandler struct {
+    discardLogHandler
+    err error
+}
+
+func (handler *flakyLogHandler) TryProcess(entry interface{}) error {
+    if handler.err == nil {
+        handler.Process(entry)
+    }
+    return handler.err
+}
+
+func TestCircuitBreaker(t *testing.T) {
+    fmt.Println("\nTestCircuitBreaker\n~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~")
+
+    SetCircuitBreakerOptions(CircuitBreakerOptions{ Threshold: 2, ProbeInterval: 20*time.Millisecond })
+    defer SetCircuitBreakerOptions(DefaultCircuitBreakerOptions())
+
+    handler := &flakyLogHandler{ 
+        discardLogHandler: discardLogHandler{ name: "flaky", format: JSONFormat }, 
+        err: errors.New("connection refused"),
+    }
+    bucket := newBucket(handler, HandlerOptions{})
+
+    bucketMtx.Lock()
+    buckets["flaky"] = bucket
+    bucketMtx.Unlock()
+    defer func() {
+        bucketMtx.Lock()
+        delete(buckets, "flaky")
+        bucketMtx.Unlock()
+    }()
+
+    for i := 0; i < 5; i++ {
+        bucket.queue.push([]byte(`{"n":1}`))
+    }
+    bucket.drain(context.Background())
+
+    // two failures open the circuit and the rest of the entries are buffered
+    stats := bucket.stats("flaky")
+    if stats.Circuit != "open" || stats.Queued != 3 || stats.LastError != "connection refused" {
+        t.Fatalf("expected an open circuit with 3 queued entries, got %+v", stats)
+    }
+    if err := HealthCheck()["flaky"]; !errors.Is(err, ErrCircuitOpen) {
+        t.Errorf("expected the handler to be unhealthy, got %v", err)
+    }
+
+    handler.err = nil
+    bucket.drain(context.Background())
+    if bucket.queue.count() != 3 {
+        t.Fatal("expected no delivery before the probe")
+    }
+
+    time.Sleep(30*time.Millisecond)
+    bucket.drain(context.Background())
+    if stats = bucket.stats("flaky"); stats.Circuit != "closed" || stats.Queued != 0 || handler.processed != 3 {
+        t.Errorf("expected the probe to close the circuit and deliver the entries, got %+v", stats)
+    }
+    if err := HealthCheck()["flaky"]; err != nil {
+        t.Errorf("expected the handler to be healthy, got %v", err)
+    }
+}
+
+// slowHealthLogHandler blocks its health check until it is released
+type slowHealthLogHandler struct {
+    flakyLogHandler
+    probing chan struct{}
+    release chan struct{}
+}
+
+func (handler *slowHealthLogHandler) Healthy() error {
+    handler.probing <- struct{}{}
+    <-handler.release
+    return nil
+}
+
+func TestCircuitBreakerSlowProbe(t *testing.T) {
+    fmt.Println("\nTestCircuitBreakerSlowProbe\n~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~")
+
+    SetCircuitBreakerOptions(CircuitBreakerOptions{ Threshold: 1, ProbeInterval: time.Millisecond })
+    defer SetCircuitBreakerOptions(DefaultCircuitBreakerOptions())
+
+    handler := &slowHealthLogHandler{ 
+        flakyLogHandler: flakyLogHandler{ 
+            discardLogHandler: discardLogHandler{ name: "slow-probe", format: JSONFormat }, 
+            err: errors.New("connection refused"),
+        },
+        probing: make(chan struct{}),
+        release: make(chan struct{}),
+    }
+    bucket := newBucket(handler, HandlerOptions{})
+
+    bucketMtx.Lock()
+    buckets["slow-probe"] = bucket
+    bucketMtx.Unlock()
+    defer func() {
+        bucketMtx.Lock()
+        delete(buckets, "slow-probe")
+        bucketMtx.Unlock()
+    }()
+
+    bucket.queue.push([]byte(`{"n":1}`))
+    bucket.queue.push([]byte(`{"n":2}`))
+    bucket.drain(context.Background())
+    handler.err = nil
+    time.Sleep(5*time.Millisecond)
+
+    done := make(chan struct{})
+    go func() {
+        bucket.drain(context.Background())
+        close(done)
+    }()
+    <-handler.probing
+
+    // the stats and the logging are not blocked while the handler is probed
+    stats := make(chan []BucketStats, 1)
+    go func() {
+        stats <- Stats()
+    }()
+    select {
+    case s := <-stats:
+        for _, bucketStats := range s {
+            if bucketStats.Name == "slow-probe" && bucketStats.Circuit != "half-open" {
+                t.Errorf("expected a half-open circuit while probing, got %+v", bucketStats)
+            }
+        }
+    case <-time.After(time.Second):
+        t.Fatal("expected Stats not to wait for the probe")
+    }
+
+    close(handler.release)
+    <-done
+    if s := bucket.stats("slow-probe"); s.Circuit != "closed" || s.Queued != 0 {
+        t.Errorf("expected the probe to close the circuit, got %+v", s)
+    }
+}
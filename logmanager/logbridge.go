@@ -0,0 +1,266 @@
+//	The MIT License (MIT)
+//
+//	Copyright (c) 2016, Cagatay Dogan
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//		The above copyright notice and this permission notice shall be included in
+//		all copies or substantial portions of the Software.
+//
+//		THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//		IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//		FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//		AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//		LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//		OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+//		THE SOFTWARE.
+
+package logmanager
+
+import (
+    "bytes"
+    "context"
+    "fmt"
+    "log"
+    "log/slog"
+    "runtime"
+    "sync"
+)
+
+// LogWriter is an io.Writer which turns every line written into it into a log entry,
+// so the output of the standard log package can be passed through the registered handlers
+type LogWriter struct {
+    sync.Mutex
+    level LogLevel
+    args map[string]interface{}
+    pending []byte
+}
+
+// NewLogWriter creates a LogWriter which logs the written lines with the given level and args
+func NewLogWriter(level LogLevel, args map[string]interface{}) *LogWriter {
+    return &LogWriter{
+        level: singleLevel(level),
+        args: args,
+    }
+}
+
+// RedirectStandardLog sends the output of the standard logger into the logging chain
+// with the given level and returns a function which restores the previous output and flags
+func RedirectStandardLog(level LogLevel) (restore func()) {
+    flags := log.Flags()
+    writer := log.Writer()
+
+    log.SetFlags(0)
+    log.SetOutput(NewLogWriter(level, nil))
+
+    return func() {
+        log.SetFlags(flags)
+        log.SetOutput(writer)
+    }
+}
+
+// Write logs every complete line in p, the incomplete tail is kept until the line is completed
+func (w *LogWriter) Write(p []byte) (int, error) {
+    w.Lock()
+    defer w.Unlock()
+
+    data := p
+    if len(w.pending) > 0 {
+        w.pending = append(w.pending, p...)
+        data = w.pending
+    }
+
+    for {
+        pos := bytes.IndexByte(data, '\n')
+        if pos < 0 {
+            break
+        }
+        w.logLine(data[:pos])
+        data = data[pos+1:]
+    }
+
+    w.pending = append(w.pending[:0], data...)
+    return len(p), nil
+}
+
+// Flush logs the incomplete line waiting for a new line, if any
+func (w *LogWriter) Flush() {
+    w.Lock()
+    defer w.Unlock()
+
+    if len(w.pending) > 0 {
+        w.logLine(w.pending)
+        w.pending = w.pending[:0]
+    }
+}
+
+func (w *LogWriter) logLine(line []byte) {
+    line = bytes.TrimRight(line, "\r")
+    if len(line) > 0 && IsEnabledFor(w.level) {
+        Log(acquireLogEntry(w.level, string(line), w.args))
+    }
+}
+
+// singleLevel reduces the given level mask to the lowest level it includes
+func singleLevel(level LogLevel) LogLevel {
+    for _, l := range allLogLevels {
+        if level.Has(l) {
+            return l
+        }
+    }
+    return LevelInfo
+}
+
+// SlogHandlerOptions are used to configure a SlogHandler
+type SlogHandlerOptions struct {
+    // Level is the minimum slog level which will be logged, slog.LevelInfo if nil
+    Level slog.Leveler
+    // AddSource adds the source file and line of the log call as the "source" arg
+    AddSource bool
+}
+
+type slogGroupAttrs struct {
+    groups []string
+    attrs []slog.Attr
+}
+
+// SlogHandler is a slog.Handler which passes the slog records through the registered handlers.
+// The slog levels below warning are logged as info, the levels starting from slog.LevelError+4 as fatal.
+// Attrs are written into the entry args, the groups become nested maps.
+type SlogHandler struct {
+    options SlogHandlerOptions
+    groups []string
+    attrs []slogGroupAttrs
+}
+
+// NewSlogHandler creates a slog.Handler which logs through the logging manager
+func NewSlogHandler(options *SlogHandlerOptions) *SlogHandler {
+    result := &SlogHandler{}
+    if options != nil {
+        result.options = *options
+    }
+    return result
+}
+
+// SlogLevelToLogLevel maps the given slog level onto a LogLevel
+func SlogLevelToLogLevel(level slog.Level) LogLevel {
+    switch {
+    case level < slog.LevelWarn:
+        return LevelInfo
+    case level < slog.LevelError:
+        return LevelWarning
+    case level < slog.LevelError+4:
+        return LevelError
+    }
+    return LevelFatal
+}
+
+// Enabled reports whether the handler handles records at the given level
+func (handler *SlogHandler) Enabled(ctx context.Context, level slog.Level) bool {
+    minLevel := slog.LevelInfo
+    if handler.options.Level != nil {
+        minLevel = handler.options.Level.Level()
+    }
+    return level >= minLevel && IsEnabledFor(SlogLevelToLogLevel(level))
+}
+
+// Handle turns the record into a log entry and logs it
+func (handler *SlogHandler) Handle(ctx context.Context, record slog.Record) error {
+    var args map[string]interface{}
+    for _, ga := range handler.attrs {
+        for _, a := range ga.attrs {
+            args = putSlogAttr(args, ga.groups, a)
+        }
+    }
+
+    record.Attrs(func(a slog.Attr) bool {
+        args = putSlogAttr(args, handler.groups, a)
+        return true
+    })
+
+    if handler.options.AddSource && record.PC != 0 {
+        frames := runtime.CallersFrames([]uintptr{ record.PC })
+        frame, _ := frames.Next()
+        if args == nil {
+            args = make(map[string]interface{})
+        }
+        args["source"] = fmt.Sprintf("%s:%d", frame.File, frame.Line)
+    }
+
+    entry := acquireLogEntry(SlogLevelToLogLevel(record.Level), record.Message, args)
+    if !record.Time.IsZero() {
+        entry.time = record.Time
+    }
+    entry.SetTraceFromContext(ctx)
+
+    Log(entry)
+    return nil
+}
+
+// WithAttrs returns a new handler which adds the given attrs to every record
+func (handler *SlogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+    if len(attrs) == 0 {
+        return handler
+    }
+
+    result := *handler
+    result.attrs = append(handler.attrs[:len(handler.attrs):len(handler.attrs)], slogGroupAttrs{
+        groups: handler.groups,
+        attrs: attrs,
+    })
+    return &result
+}
+
+// WithGroup returns a new handler which puts the following attrs into the given group
+func (handler *SlogHandler) WithGroup(name string) slog.Handler {
+    if name == "" {
+        return handler
+    }
+
+    result := *handler
+    result.groups = append(handler.groups[:len(handler.groups):len(handler.groups)], name)
+    return &result
+}
+
+func putSlogAttr(args map[string]interface{}, groups []string, a slog.Attr) map[string]interface{} {
+    a.Value = a.Value.Resolve()
+    if a.Equal(slog.Attr{}) {
+        return args
+    }
+
+    if a.Value.Kind() == slog.KindGroup {
+        attrs := a.Value.Group()
+        if len(attrs) == 0 {
+            return args
+        }
+        if a.Key != "" {
+            groups = append(groups[:len(groups):len(groups)], a.Key)
+        }
+        for _, ga := range attrs {
+            args = putSlogAttr(args, groups, ga)
+        }
+        return args
+    }
+
+    if args == nil {
+        args = make(map[string]interface{})
+    }
+
+    target := args
+    for _, group := range groups {
+        m, ok := target[group].(map[string]interface{})
+        if !ok {
+            m = make(map[string]interface{})
+            target[group] = m
+        }
+        target = m
+    }
+
+    target[a.Key] = a.Value.Any()
+    return args
+}
@@ -0,0 +1,237 @@
+//	The MIT License (MIT)
+//
+//	Copyright (c) 2016, Cagatay Dogan
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//		The above copyright notice and this permission notice shall be included in
+//		all copies or substantial portions of the Software.
+//
+//		THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//		IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//		FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//		AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//		LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//		OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+//		THE SOFTWARE.
+
+package logmanager
+
+import (
+    "bytes"
+    "context"
+    "encoding/json"
+    "fmt"
+    "log"
+    "log/slog"
+    "reflect"
+    "sync"
+    "testing"
+    "time"
+)
+
+type bridgeLine struct {
+    Level string `json:"level"`
+    Message string `json:"message"`
+    Args map[string]interface{} `json:"args"`
+}
+
+// bridgeLogHandler keeps the JSON formatted entries logged through the bridges
+type bridgeLogHandler struct {
+    sync.Mutex
+    name string
+    lines []bridgeLine
+}
+
+func (handler *bridgeLogHandler) Name() string {
+    return handler.name
+}
+
+func (handler *bridgeLogHandler) Enabled() bool {
+    return true
+}
+
+func (handler *bridgeLogHandler) Enable() {
+}
+
+func (handler *bridgeLogHandler) Disable() {
+}
+
+func (handler *bridgeLogHandler) Level() LogLevel {
+    return AllLogLevels
+}
+
+func (handler *bridgeLogHandler) Format() LogFormat {
+    return JSONFormat
+}
+
+func (handler *bridgeLogHandler) QueueLen() int {
+    return -1
+}
+
+func (handler *bridgeLogHandler) Process(entry interface{}) {
+    if data, ok := entry.([]byte); ok {
+        var line bridgeLine
+        json.Unmarshal(data, &line)
+
+        handler.Lock()
+        defer handler.Unlock()
+        handler.lines = append(handler.lines, line)
+    }
+}
+
+// wait returns the entries after the expected number of entries is processed and unregisters the handler
+func (handler *bridgeLogHandler) wait(t *testing.T, expected int) []bridgeLine {
+    defer UnregisterHandler(handler.name)
+
+    deadline := time.Now().Add(2*time.Second)
+    for {
+        handler.Lock()
+        lines := append([]bridgeLine(nil), handler.lines...)
+        handler.Unlock()
+
+        if len(lines) >= expected {
+            return lines
+        }
+        if time.Now().After(deadline) {
+            t.Fatalf("expected %d entries, got %+v", expected, lines)
+        }
+        time.Sleep(5*time.Millisecond)
+    }
+}
+
+func TestLogWriter(t *testing.T) {
+    fmt.Println("\nTestLogWriter\n~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~")
+
+    handler := &bridgeLogHandler{ name: "bridge-writer" }
+    RegisterHandler(handler)
+
+    w := NewLogWriter(LevelWarning | LevelError, map[string]interface{}{ "source": "writer" })
+    w.Write([]byte("first li"))
+    w.Write([]byte("ne\nsecond\r\nthi"))
+    w.Write([]byte("rd"))
+    w.Flush()
+    w.Write([]byte("\n\r\n"))
+    w.Flush()
+
+    lines := handler.wait(t, 3)
+    if len(lines) != 3 {
+        t.Fatalf("expected 3 entries, got %+v", lines)
+    }
+    for i, expected := range []string{ "first line", "second", "third" } {
+        if line := lines[i]; line.Message != expected || line.Level != "warning" || line.Args["source"] != "writer" {
+            t.Errorf("expected the warning %q, got %+v", expected, line)
+        }
+    }
+}
+
+func TestRedirectStandardLog(t *testing.T) {
+    fmt.Println("\nTestRedirectStandardLog\n~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~")
+
+    handler := &bridgeLogHandler{ name: "bridge-std" }
+    RegisterHandler(handler)
+
+    flags, writer := log.Flags(), log.Writer()
+    defer func() {
+        log.SetFlags(flags)
+        log.SetOutput(writer)
+    }()
+
+    var out bytes.Buffer
+    log.SetOutput(&out)
+    log.SetFlags(log.Lshortfile)
+
+    restore := RedirectStandardLog(LevelError)
+    log.Printf("redirected %d", 1)
+    restore()
+    log.Print("restored")
+
+    if log.Flags() != log.Lshortfile || log.Writer() != &out {
+        t.Error("expected the output and the flags to be restored")
+    }
+    if !bytes.Contains(out.Bytes(), []byte("restored")) || bytes.Contains(out.Bytes(), []byte("redirected")) {
+        t.Errorf("unexpected standard log output %q", out.String())
+    }
+
+    lines := handler.wait(t, 1)
+    if len(lines) != 1 || lines[0].Message != "redirected 1" || lines[0].Level != "error" {
+        t.Errorf("expected the redirected line without flags, got %+v", lines)
+    }
+}
+
+func TestSlogLevelToLogLevel(t *testing.T) {
+    fmt.Println("\nTestSlogLevelToLogLevel\n~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~")
+
+    levels := map[slog.Level]LogLevel{
+        slog.LevelDebug: LevelInfo,
+        slog.LevelInfo: LevelInfo,
+        slog.LevelWarn - 1: LevelInfo,
+        slog.LevelWarn: LevelWarning,
+        slog.LevelError - 1: LevelWarning,
+        slog.LevelError: LevelError,
+        slog.LevelError + 3: LevelError,
+        slog.LevelError + 4: LevelFatal,
+        slog.LevelError + 8: LevelFatal,
+    }
+    for level, expected := range levels {
+        if l := SlogLevelToLogLevel(level); l != expected {
+            t.Errorf("expected %v for %v, got %v", expected, level, l)
+        }
+    }
+}
+
+func TestSlogHandler(t *testing.T) {
+    fmt.Println("\nTestSlogHandler\n~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~")
+
+    capture := &bridgeLogHandler{ name: "bridge-slog" }
+    RegisterHandler(capture)
+
+    handler := NewSlogHandler(&SlogHandlerOptions{ Level: slog.LevelWarn })
+    if handler.Enabled(context.Background(), slog.LevelInfo) || !handler.Enabled(context.Background(), slog.LevelWarn) {
+        t.Error("expected the records below warning to be disabled")
+    }
+    if handler.WithGroup("") != slog.Handler(handler) || handler.WithAttrs(nil) != slog.Handler(handler) {
+        t.Error("expected the same handler for an empty group and no attrs")
+    }
+
+    base := slog.New(handler).With("service", "api")
+    request := base.WithGroup("request").With("id", 7)
+    request.WithGroup("user").Warn("grouped", "name", "ada", 
+        slog.Group("geo", "city", "paris"), slog.Group("empty"))
+    base.Info("filtered")
+    base.Error("plain", "code", 500)
+
+    lines := capture.wait(t, 2)
+    if len(lines) != 2 {
+        t.Fatalf("expected 2 entries, got %+v", lines)
+    }
+
+    byMessage := make(map[string]bridgeLine)
+    for _, line := range lines {
+        byMessage[line.Message] = line
+    }
+
+    expected := map[string]interface{}{
+        "service": "api",
+        "request": map[string]interface{}{
+            "id": float64(7),
+            "user": map[string]interface{}{
+                "name": "ada",
+                "geo": map[string]interface{}{ "city": "paris" },
+            },
+        },
+    }
+    if line := byMessage["grouped"]; line.Level != "warning" || !reflect.DeepEqual(line.Args, expected) {
+        t.Errorf("unexpected grouped entry %+v", line)
+    }
+
+    expected = map[string]interface{}{ "service": "api", "code": float64(500) }
+    if line := byMessage["plain"]; line.Level != "error" || !reflect.DeepEqual(line.Args, expected) {
+        t.Errorf("unexpected plain entry %+v", line)
+    }
+}
@@ -24,8 +24,10 @@ package logmanager
 
 import (
     // "container/list"
+    "context"
     "sync"
     "sync/atomic"
+    "time"
     "github.com/ocdogan/goutils/utils"
 )
 
@@ -36,6 +38,7 @@ const (
 
 var (
     bucketCap = uint32(1024)
+    reservedCap = uint32(0)
 )
 
 // BucketCapacity returns the system wide LogEntry buffering capacity for any log handler
@@ -52,41 +55,130 @@ func SetBucketCapacity(cap uint32) {
     }
     
     atomic.StoreUint32(&bucketCap, cap)
+    
+    bucketMtx.Lock()
+    defer bucketMtx.Unlock()
+    
+    for _, bucket := range buckets {
+        if bucket.handler != nil && bucket.requestedQueueLen() < 0 && !bucket.queue.durable() {
+            bucket.queue.setCapacity(int(cap))
+        }
+    }
+}
+
+// ReservedCapacity returns the part of the bucket capacities reserved for the error and fatal entries
+func ReservedCapacity() uint32 {
+    return atomic.LoadUint32(&reservedCap)
+}
+
+// SetReservedCapacity reserves the given part of the bucket capacities for the error and fatal entries, 
+// the info and warning entries are evicted to keep the reserved capacity free for them. 
+// The entries are processed in the order they are logged and the less severe entries 
+// are evicted first when a bucket is full regardless of the reservation.
+func SetReservedCapacity(reserved uint32) {
+    if reserved > maxBucketCap {
+        reserved = maxBucketCap
+    }
+    atomic.StoreUint32(&reservedCap, reserved)
+    
+    bucketMtx.Lock()
+    defer bucketMtx.Unlock()
+    
+    for _, bucket := range buckets {
+        bucket.queue.setReserved(int(reserved))
+    }
+}
+
+// BucketStats gives the state and the counters of a registered handler bucket
+type BucketStats struct {
+    Name string `json:"name"`
+    State string `json:"state"`
+    Enabled bool `json:"enabled"`
+    Level string `json:"level"`
+    Format string `json:"format"`
+    Queued int `json:"queued"`
+    Capacity int `json:"capacity"`
+    Received uint64 `json:"received"`
+    Processed uint64 `json:"processed"`
+    Dropped uint64 `json:"dropped"`
+    Circuit string `json:"circuit"`
+    Failures int `json:"failures"`
+    LastError string `json:"last_error,omitempty"`
+}
+
+// bucketState is the lifecycle state of a bucket, a bucket moves only forward
+// from idle to running, closing and closed
+type bucketState uint32
+
+const (
+    bucketIdle bucketState = iota
+    bucketRunning
+    bucketClosing
+    bucketClosed
+)
+
+var (
+    bucketStateNames = []string{ "idle", "running", "closing", "closed" }
+)
+
+// String returns the name of the state
+func (state bucketState) String() string {
+    if int(state) < len(bucketStateNames) {
+        return bucketStateNames[state]
+    }
+    return "unknown"
 }
 
 type logBucket struct {
-    sync.Mutex    
-    done chan bool
-    inProc uint32
-    completed uint32
-    readyChan chan bool
-    queueChan chan interface{}
-    queue *logQueue
+    sync.RWMutex
+    state uint32
+    levelOverride uint32
+    received uint64
+    processed uint64
+    ctx context.Context
+    cancel context.CancelFunc
+    wg sync.WaitGroup
+    closeOnce sync.Once
+    wake chan struct{}
+    queue bucketQueue
     handler LogHandler
+    options HandlerOptions
+    breaker circuitBreaker
 }
 
-func newBucket(handler LogHandler) *logBucket {
-    return &logBucket{
+// newBucket creates a bucket for the handler, the options override the settings of the handler
+func newBucket(handler LogHandler, options HandlerOptions) *logBucket {
+    result := &logBucket{
         handler: handler,
-        queue: newLogQueue(handler.QueueLen()),
-        done: make(chan bool),
-        readyChan: make(chan bool),
-        queueChan: make(chan interface{}),
-        completed: falseUint32,
+        options: options,
+        wake: make(chan struct{}, 1),
     }
+    result.ctx, result.cancel = context.WithCancel(context.Background())
+    
+    queue := newLogQueue(result.queueLen())
+    queue.setReserved(int(ReservedCapacity()))
+    queue.setOverflow(options.Overflow)
+    result.queue = queue
+    return result
+}
+
+func (bucket *logBucket) currentState() bucketState {
+    return bucketState(atomic.LoadUint32(&bucket.state))
 }
 
 func (bucket *logBucket) enabled() bool {
-    return atomic.LoadUint32(&bucket.completed) == falseUint32 && 
+    return bucket.currentState() == bucketRunning && 
         bucket.handler != nil && 
         bucket.handler.Enabled()
 }
 
-func (bucket *logBucket) processing() bool {
-    return atomic.LoadUint32(&bucket.inProc) != falseUint32
-}
-
 func (bucket *logBucket) level() LogLevel {
+    if l := LogLevel(atomic.LoadUint32(&bucket.levelOverride)); l != LogLevel(0) {
+        return l
+    }
+    if bucket.options.Level != LogLevel(0) {
+        return bucket.options.Level
+    }
     if bucket.handler != nil { 
         l := bucket.handler.Level()
         if l != LogLevel(0) {
@@ -96,16 +188,62 @@ func (bucket *logBucket) level() LogLevel {
     return AllLogLevels
 }
 
+// setLevel overrides the level of the handler, 0 restores the registration or the handler level
+func (bucket *logBucket) setLevel(level LogLevel) {
+    atomic.StoreUint32(&bucket.levelOverride, uint32(level))
+}
+
+// accepts returns if the entry passes the filter of the registration
+func (bucket *logBucket) accepts(entry *LogEntry) bool {
+    return bucket.options.Filter == nil || bucket.options.Filter(entry)
+}
+
+func (bucket *logBucket) stats(name string) BucketStats {
+    state, failures, err := bucket.breaker.status()
+    var lastErr string
+    if err != nil {
+        lastErr = err.Error()
+    }
+    
+    return BucketStats{
+        Name: name,
+        State: bucket.currentState().String(),
+        Enabled: bucket.enabled(),
+        Level: bucket.level().String(),
+        Format: bucket.format().String(),
+        Queued: bucket.queue.count(),
+        Capacity: bucket.queue.capacity(),
+        Received: atomic.LoadUint64(&bucket.received),
+        Processed: atomic.LoadUint64(&bucket.processed),
+        Dropped: bucket.queue.droppedCount(),
+        Circuit: state.String(),
+        Failures: failures,
+        LastError: lastErr,
+    }
+}
+
 func (bucket *logBucket) format() LogFormat {
+    if bucket.options.OverrideFormat {
+        return bucket.options.Format
+    }
     if bucket.handler != nil { 
         return bucket.handler.Format()
     }
     return CustomFormat
 }
 
+// requestedQueueLen returns the queue length of the registration or the handler, 
+// negative if the bucket capacity is used
+func (bucket *logBucket) requestedQueueLen() int {
+    if bucket.options.QueueLen != 0 {
+        return bucket.options.QueueLen
+    }
+    return bucket.handler.QueueLen()
+}
+
 func (bucket *logBucket) queueLen() int {
     if bucket.handler != nil { 
-        ql := bucket.handler.QueueLen()
+        ql := bucket.requestedQueueLen()
         if ql < 0 {
             return int(BucketCapacity())
         }
@@ -119,86 +257,171 @@ func (bucket *logBucket) queueLen() int {
     return 0
 }
 
+// start runs the goroutine delivering the queued entries to the handler, 
+// a bucket can be started only once
+func (bucket *logBucket) start() {
+    bucket.Lock()
+    defer bucket.Unlock()
+    
+    if bucket.currentState() != bucketIdle {
+        return
+    }
+    atomic.StoreUint32(&bucket.state, uint32(bucketRunning))
+    
+    bucket.wg.Add(1)
+    go bucket.process()
+}
+
+// close stops the bucket and waits for the delivering goroutine to exit. The entries left 
+// in the queue are delivered to the handler before the queue is closed, the entries which 
+// cannot be delivered are dropped or kept in the spool to be replayed. 
+// The concurrent calls return after the bucket is closed.
 func (bucket *logBucket) close() {
-    bucket.done <- true
-    close(bucket.done)
-    close(bucket.readyChan)
-    close(bucket.queueChan)
+    bucket.closeOnce.Do(func() {
+        bucket.Lock()
+        atomic.StoreUint32(&bucket.state, uint32(bucketClosing))
+        bucket.Unlock()
+        
+        bucket.cancel()
+        bucket.wg.Wait()
+        
+        bucket.drain(context.Background())
+        if !bucket.queue.durable() {
+            bucket.queue.discard()
+        }
+        bucket.queue.close()
+        
+        atomic.StoreUint32(&bucket.state, uint32(bucketClosed))
+    })
+}
+
+// enqueue queues the data to be delivered to the handler, the data is released 
+// and false is returned if the bucket is not running
+func (bucket *logBucket) enqueue(data interface{}) bool {
+    bucket.RLock()
+    defer bucket.RUnlock()
+    
+    if bucket.currentState() != bucketRunning {
+        releaseData(data)
+        return false
+    }
+    
+    bucket.push(data)
+    select {
+    case bucket.wake <- struct{}{}:
+    default:
+    }
+    return true
 }
     
 func (bucket *logBucket) push(data interface{}) {
-    switch data.(type) {
+    atomic.AddUint64(&bucket.received, 1)
+    switch d := data.(type) {
     case []byte:
-        bucket.queue.push(data)
+        bucket.queue.push(d)
+    case *logBuffer:
+        bucket.queue.push(d)
     case *LogEntry:
-        bucket.queue.push(data)
+        bucket.queue.push(d)
     case LogEntry:
-        bucket.queue.push(&data)
+        entry := d
+        bucket.queue.push(&entry)
     case string:
-        s := data.(string)
-        if s != "" {
-            bucket.queue.push([]byte(s))
+        if d != "" {
+            bucket.queue.push([]byte(d))
         }
     }
 }
 
 func (bucket *logBucket) process() {
-    for bucket.enabled() {
+    defer bucket.wg.Done()
+    
+    // deliver the entries replayed from a spool
+    bucket.drain(bucket.ctx)
+    
+    for {
+        // wake up to probe the handler while the circuit is open
+        var timer *time.Timer
+        var probe <-chan time.Time
+        if wait := bucket.breaker.wait(); wait > 0 {
+            timer = time.NewTimer(wait)
+            probe = timer.C
+        }
+        
         select {
-        case <-bucket.done:
-            atomic.StoreUint32(&bucket.completed, trueUint32)
-            return
-        case ready, ok := <-bucket.readyChan:
-            if !ok {
-                return
+        case <-bucket.ctx.Done():
+            if timer != nil {
+                timer.Stop()
             }
-            if ready {
-                e := bucket.queue.pop()
-                if !utils.HasValue(e) {
-                    continue
-                }
-                handler := bucket.handler
-                if handler.Enabled() {
-                    switch handler.Format() {
-                    case TextFormat:
-                        fallthrough
-                    case JSONFormat:
-                        b, ok := e.([]byte)
-                        if ok && len(b) > 0 {
-                            handler.Process(b)
-                        }
-                    case CustomFormat:
-                        entry, ok := e.(*LogEntry)
-                        if ok && entry != nil {
-                            handler.Process(entry)
-                        }
-                    }
-                }
+            return
+        case <-bucket.wake:
+            bucket.drain(bucket.ctx)
+        case <-probe:
+            bucket.drain(bucket.ctx)
+        }
+        
+        if timer != nil {
+            timer.Stop()
+        }
+    }
+}
+
+// drain delivers the queued entries to the handler until the queue is empty or the circuit is open.
+// The entries of a durable queue are acknowledged after they are processed without an error, 
+// a failed entry is delivered again with the next drain. Draining stops when the context is done.
+func (bucket *logBucket) drain(ctx context.Context) {
+    options := CurrentCircuitBreakerOptions()
+    for ctx.Err() == nil {
+        if !bucket.breaker.allow(bucket.handler, options) {
+            if options.Policy == CircuitDrop {
+                bucket.queue.discard()
             }
+            return
+        }
+        
+        e := bucket.queue.pop()
+        if !utils.HasValue(e) {
+            return
+        }
+        err := bucket.processData(e)
+        releaseData(e)
+        bucket.breaker.report(err, options)
+        if err != nil && bucket.queue.durable() {
+            return
         }
+        bucket.queue.ack()
+        atomic.AddUint64(&bucket.processed, 1)
     }
 }
 
-func (bucket *logBucket) handle() {
-    if atomic.LoadUint32(&bucket.completed) == trueUint32 ||
-        !atomic.CompareAndSwapUint32(&bucket.inProc, falseUint32, trueUint32) {
-        return
+func (bucket *logBucket) processData(e interface{}) error {
+    handler := bucket.handler
+    if !handler.Enabled() {
+        return nil
     }
-    defer atomic.StoreUint32(&bucket.inProc, falseUint32)
     
-    go bucket.process()
-    
-    for bucket.enabled() {
-        select {
-        case <-bucket.done:
-            atomic.StoreUint32(&bucket.completed, trueUint32)
-            return
-        case entry, ok := <-bucket.queueChan:
-            if !ok {
-                return
+    var data interface{}
+    if bucket.format().encoded() {
+        switch b := e.(type) {
+        case *logBuffer:
+            if len(b.b) > 0 {
+                data = b.data
+            }
+        case []byte:
+            if len(b) > 0 {
+                data = b
             }
-            bucket.push(entry)
-            bucket.readyChan <- true
         }
+    } else if entry, ok := e.(*LogEntry); ok && entry != nil {
+        data = entry
+    }
+    
+    if data == nil {
+        return nil
+    }
+    if fallible, ok := handler.(FallibleLogHandler); ok {
+        return fallible.TryProcess(data)
     }
+    handler.Process(data)
+    return nil
 }
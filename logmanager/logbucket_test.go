@@ -0,0 +1,176 @@
+//	The MIT License (MIT)
+//
+//	Copyright (c) 2016, Cagatay Dogan
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//		The above copyright notice and this permission notice shall be included in
+//		all copies or substantial portions of the Software.
+//
+//		THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//		IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//		FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//		AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//		LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//		OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+//		THE SOFTWARE.
+
+package logmanager
+
+import (
+    "errors"
+    "fmt"
+    "runtime"
+    "strings"
+    "sync"
+    "sync/atomic"
+    "testing"
+    "time"
+)
+
+// bucketGoroutines counts the running bucket goroutines
+func bucketGoroutines() int {
+    stack := make([]byte, 1<<20)
+    for {
+        n := runtime.Stack(stack, true)
+        if n < len(stack) {
+            return strings.Count(string(stack[:n]), "logmanager.(*logBucket).process(")
+        }
+        stack = make([]byte, 2*len(stack))
+    }
+}
+
+// expectBucketGoroutines waits for the bucket goroutines to exit until there are expected many
+func expectBucketGoroutines(t *testing.T, expected int) {
+    deadline := time.Now().Add(2*time.Second)
+    for {
+        count := bucketGoroutines()
+        if count == expected {
+            return
+        }
+        if time.Now().After(deadline) {
+            t.Fatalf("expected %d bucket goroutines, found %d", expected, count)
+        }
+        time.Sleep(10*time.Millisecond)
+    }
+}
+
+func TestBucketLifecycle(t *testing.T) {
+    fmt.Println("\nTestBucketLifecycle\n~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~")
+
+    before := bucketGoroutines()
+
+    handler := &discardLogHandler{ name: "lifecycle", format: CustomFormat }
+    bucket := newBucket(handler, HandlerOptions{})
+    if bucket.currentState() != bucketIdle || bucket.enabled() {
+        t.Fatalf("expected an idle bucket, got %s", bucket.currentState())
+    }
+    if bucket.enqueue(NewInfoLogEntry("lost", nil)) {
+        t.Error("expected an idle bucket to refuse the entries")
+    }
+
+    bucket.start()
+    bucket.start()
+    if bucket.currentState() != bucketRunning {
+        t.Fatalf("expected a running bucket, got %s", bucket.currentState())
+    }
+    expectBucketGoroutines(t, before + 1)
+
+    for i := 0; i < 100; i++ {
+        if !bucket.enqueue(NewInfoLogEntry("message", nil)) {
+            t.Fatal("expected a running bucket to accept the entries")
+        }
+    }
+
+    var wg sync.WaitGroup
+    for i := 0; i < 4; i++ {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            bucket.close()
+            if bucket.currentState() != bucketClosed {
+                t.Errorf("expected close to return after the bucket is closed, got %s", bucket.currentState())
+            }
+        }()
+    }
+    wg.Wait()
+
+    // the queued entries are delivered before the bucket is closed
+    if processed := atomic.LoadInt64(&handler.processed); processed != 100 {
+        t.Errorf("expected 100 processed entries, got %d", processed)
+    }
+    if bucket.enqueue(NewInfoLogEntry("lost", nil)) {
+        t.Error("expected a closed bucket to refuse the entries")
+    }
+    expectBucketGoroutines(t, before)
+
+    // a bucket which never started closes without waiting
+    idle := newBucket(handler, HandlerOptions{})
+    idle.close()
+    if idle.currentState() != bucketClosed {
+        t.Errorf("expected a closed bucket, got %s", idle.currentState())
+    }
+}
+
+func TestBucketLifecycleStress(t *testing.T) {
+    fmt.Println("\nTestBucketLifecycleStress\n~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~")
+
+    before := bucketGoroutines()
+    formats := []LogFormat{ JSONFormat, TextFormat, CustomFormat }
+    names := []string{ "stress-0", "stress-1", "stress-2" }
+
+    stop := make(chan struct{})
+    var loggers sync.WaitGroup
+    for i := 0; i < 8; i++ {
+        loggers.Add(1)
+        go func(i int) {
+            defer loggers.Done()
+            args := map[string]interface{}{ "logger": i }
+            for {
+                select {
+                case <-stop:
+                    return
+                default:
+                }
+                LogMessage("stress message", args)
+                LogError(errors.New("stress error"), args)
+                Infof("stress %d", i)
+            }
+        }(i)
+    }
+
+    var registrars sync.WaitGroup
+    for i := 0; i < 6; i++ {
+        registrars.Add(1)
+        go func(i int) {
+            defer registrars.Done()
+            for j := 0; j < 50; j++ {
+                name := names[(i + j) % len(names)]
+                handler := &discardLogHandler{ name: name, format: formats[j % len(formats)] }
+                if j % 2 == 0 {
+                    RegisterHandler(handler)
+                } else {
+                    RegisterHandlerWithName(name, handler)
+                }
+                SetHandlerLevel(name, AllLogLevels)
+                Stats()
+                IsEnabledFor(LevelInfo)
+                UnregisterHandler(names[j % len(names)])
+            }
+        }(i)
+    }
+
+    registrars.Wait()
+    close(stop)
+    loggers.Wait()
+
+    for _, name := range names {
+        UnregisterHandler(name)
+    }
+    expectBucketGoroutines(t, before)
+}
@@ -0,0 +1,107 @@
+//	The MIT License (MIT)
+//
+//	Copyright (c) 2016, Cagatay Dogan
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//		The above copyright notice and this permission notice shall be included in
+//		all copies or substantial portions of the Software.
+//
+//		THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//		IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//		FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//		AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//		LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//		OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+//		THE SOFTWARE.
+
+package logmanager
+
+import (
+    "sync"
+    "sync/atomic"
+)
+
+const (
+    maxPooledBufferCap = 64*1024
+    maxPooledKeysCap = 256
+)
+
+// logBuffer is a reference counted pooled byte buffer shared by the buckets
+// which accept the same format of an entry
+type logBuffer struct {
+    b []byte
+    data interface{}
+    level LogLevel
+    refs int32
+}
+
+var (
+    bufferPool = sync.Pool{
+        New: func() interface{} {
+            return &logBuffer{ b: make([]byte, 0, 512) }
+        },
+    }
+    entryPool = sync.Pool{
+        New: func() interface{} {
+            return &LogEntry{}
+        },
+    }
+    keysPool = sync.Pool{
+        New: func() interface{} {
+            keys := make([]string, 0, 16)
+            return &keys
+        },
+    }
+)
+
+func acquireBuffer() *logBuffer {
+    buf := bufferPool.Get().(*logBuffer)
+    buf.b = buf.b[:0]
+    buf.refs = 1
+    return buf
+}
+
+// seal boxes the encoded bytes once, so the buckets can pass them to the handlers 
+// without allocating per handler
+func (buf *logBuffer) seal() {
+    buf.data = buf.b
+}
+
+func (buf *logBuffer) retain() {
+    atomic.AddInt32(&buf.refs, 1)
+}
+
+func (buf *logBuffer) release() {
+    if atomic.AddInt32(&buf.refs, -1) == 0 && cap(buf.b) <= maxPooledBufferCap {
+        buf.data = nil
+        bufferPool.Put(buf)
+    }
+}
+
+func acquireKeys() *[]string {
+    return keysPool.Get().(*[]string)
+}
+
+func releaseKeys(keys *[]string) {
+    if cap(*keys) <= maxPooledKeysCap {
+        clear(*keys)
+        *keys = (*keys)[:0]
+        keysPool.Put(keys)
+    }
+}
+
+// releaseData gives back the pooled queue data which will not be processed any more
+func releaseData(data interface{}) {
+    switch d := data.(type) {
+    case *logBuffer:
+        d.release()
+    case *LogEntry:
+        d.release()
+    }
+}
@@ -0,0 +1,105 @@
+//	The MIT License (MIT)
+//
+//	Copyright (c) 2016, Cagatay Dogan
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//		The above copyright notice and this permission notice shall be included in
+//		all copies or substantial portions of the Software.
+//
+//		THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//		IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//		FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//		AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//		LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//		OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+//		THE SOFTWARE.
+
+package logmanager
+
+import (
+    "strconv"
+    "sync/atomic"
+    "time"
+    "github.com/ocdogan/goutils/uuid"
+)
+
+// Clock returns the current time which is used as the creation time of the log entries
+type Clock func() time.Time
+
+// IDGenerator returns the id of a new log entry, an empty id means the entry has no id
+type IDGenerator func() string
+
+var (
+    clock atomic.Value
+    idGenerator atomic.Value
+)
+
+// SetClock sets the clock used to time the log entries, nil restores time.Now
+func SetClock(c func() time.Time) {
+    clock.Store(Clock(c))
+}
+
+// SetIDGenerator sets the generator used to identify the log entries,
+// nil restores the default fast UUID generation
+func SetIDGenerator(generator func() string) {
+    idGenerator.Store(IDGenerator(generator))
+}
+
+func now() time.Time {
+    if c, ok := clock.Load().(Clock); ok && c != nil {
+        return c()
+    }
+    return time.Now()
+}
+
+func currentIDGenerator() IDGenerator {
+    if generator, ok := idGenerator.Load().(IDGenerator); ok {
+        return generator
+    }
+    return nil
+}
+
+// NoIDGenerator leaves the log entries without an id
+func NoIDGenerator() string {
+    return ""
+}
+
+// UUIDv4Generator identifies the log entries with random version 4 UUIDs
+func UUIDv4Generator() string {
+    id, err := uuid.NewV4()
+    if err != nil {
+        return ""
+    }
+    return id.String()
+}
+
+// UUIDv7Generator identifies the log entries with time ordered version 7 UUIDs using the log clock
+func UUIDv7Generator() string {
+    id, err := uuid.NewV7(now())
+    if err != nil {
+        return ""
+    }
+    return id.String()
+}
+
+// NewSequentialIDGenerator creates a generator which identifies the log entries
+// with the given prefix followed by an increasing sequence number starting from 1
+func NewSequentialIDGenerator(prefix string) IDGenerator {
+    seq := uint64(0)
+    return func() string {
+        return prefix + strconv.FormatUint(atomic.AddUint64(&seq, 1), 10)
+    }
+}
+
+// NewFixedClock creates a clock which returns the given time, useful for tests
+func NewFixedClock(t time.Time) Clock {
+    return func() time.Time {
+        return t
+    }
+}
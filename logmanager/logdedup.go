@@ -0,0 +1,310 @@
+//	The MIT License (MIT)
+//
+//	Copyright (c) 2016, Cagatay Dogan
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//		The above copyright notice and this permission notice shall be included in
+//		all copies or substantial portions of the Software.
+//
+//		THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//		IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//		FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//		AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//		LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//		OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+//		THE SOFTWARE.
+
+package logmanager
+
+import (
+    "strconv"
+    "strings"
+    "sync"
+    "sync/atomic"
+    "time"
+    "github.com/ocdogan/goutils/murmur"
+)
+
+const (
+    defaultMaxFingerprints = 10000
+    // maxDedupStackLen limits the stack kept for the summary of an entry
+    maxDedupStackLen = 4096
+    fingerprintSeed1 = uint32(0x9747b28c)
+    fingerprintSeed2 = uint32(0x5bd1e995)
+)
+
+var (
+    dedupState atomic.Value
+)
+
+func init() {
+    dedupState.Store((*deduplicator)(nil))
+}
+
+// DedupOptions configures collapsing the repeats of the same entry
+type DedupOptions struct {
+    // Window is the time the repeats of an entry are suppressed after the entry is logged, 
+    // 0 disables the deduplication
+    Window time.Duration
+    // Keys are the names of the args which are part of the fingerprint 
+    // along with the level, the category and the message of the entry
+    Keys []string
+    // Levels is the set of levels deduplicated, all levels if 0
+    Levels LogLevel
+    // MaxFingerprints limits the number of the entries tracked in a window, 10000 if 0. 
+    // The new entries are not deduplicated while the limit is reached.
+    MaxFingerprints int
+}
+
+type dedupRecord struct {
+    level LogLevel
+    category string
+    message string
+    err error
+    stack string
+    eventID uint32
+    eventCategory string
+    args map[string]interface{}
+    start time.Time
+    last time.Time
+    repeated uint64
+}
+
+type deduplicator struct {
+    sync.Mutex
+    options DedupOptions
+    records map[uint64]*dedupRecord
+    timer *time.Timer
+}
+
+// SetDedupOptions enables collapsing the repeated entries. The first entry is logged and its repeats 
+// within the window are suppressed, a summary entry like "... (repeated 3,412 times between T1 and T2)" 
+// is logged after the window. The summaries of the current window are logged when the options change.
+func SetDedupOptions(options DedupOptions) {
+    var d *deduplicator
+    if options.Window > 0 {
+        if options.Levels == LogLevel(0) {
+            options.Levels = AllLogLevels
+        }
+        if options.MaxFingerprints <= 0 {
+            options.MaxFingerprints = defaultMaxFingerprints
+        }
+        options.Keys = append([]string(nil), options.Keys...)
+        
+        d = &deduplicator{
+            options: options,
+            records: make(map[uint64]*dedupRecord),
+        }
+    }
+    
+    if old := dedupState.Swap(d).(*deduplicator); old != nil {
+        old.Lock()
+        if old.timer != nil {
+            old.timer.Stop()
+            old.timer = nil
+        }
+        summaries := old.sweep(time.Time{}, true)
+        old.Unlock()
+        
+        dispatchAll(summaries)
+    }
+}
+
+// CurrentDedupOptions returns the deduplication options, the window is 0 if deduplication is disabled
+func CurrentDedupOptions() DedupOptions {
+    if d := dedupState.Load().(*deduplicator); d != nil {
+        options := d.options
+        options.Keys = append([]string(nil), options.Keys...)
+        return options
+    }
+    return DedupOptions{}
+}
+
+// deduplicate returns true if the entry is a repeat which should be suppressed
+func deduplicate(entry *LogEntry) bool {
+    d := dedupState.Load().(*deduplicator)
+    if d == nil || !d.options.Levels.Has(entry.level) {
+        return false
+    }
+    
+    // the fingerprint uses the formatted message, the entry is resolved by dispatch
+    fingerprint := d.fingerprint(entry)
+    t := now()
+    
+    d.Lock()
+    record, ok := d.records[fingerprint]
+    if ok && t.Sub(record.start) < d.options.Window {
+        record.repeated++
+        record.last = t
+        if d.timer == nil {
+            d.timer = time.AfterFunc(d.options.Window, d.expire)
+        }
+        d.Unlock()
+        return true
+    }
+    
+    var summaries []*LogEntry
+    if ok {
+        if record.repeated > 0 {
+            summaries = append(summaries, record.summary())
+        }
+        delete(d.records, fingerprint)
+    } else if len(d.records) >= d.options.MaxFingerprints {
+        summaries = d.sweep(t, false)
+    }
+    if len(d.records) < d.options.MaxFingerprints {
+        d.records[fingerprint] = d.record(entry, t)
+    }
+    d.Unlock()
+    
+    dispatchAll(summaries)
+    return false
+}
+
+// fingerprint hashes the level, the category, the message and the key args of the entry
+func (d *deduplicator) fingerprint(entry *LogEntry) uint64 {
+    buf := acquireBuffer()
+    b := append(buf.b, byte(entry.level))
+    b = append(b, entry.category...)
+    b = append(b, 0)
+    b = append(b, entry.message...)
+    for _, key := range d.options.Keys {
+        b = append(b, 0)
+        b = append(b, key...)
+        if value, ok := entry.args[key]; ok {
+            b = append(b, '=')
+            b = appendLogfmtValue(b, value)
+        }
+    }
+    
+    h1 := murmur.MurmurHash3(b, uint32(len(b)), fingerprintSeed1)
+    h2 := murmur.MurmurHash3(b, uint32(len(b)), fingerprintSeed2)
+    buf.b = b
+    buf.release()
+    return uint64(h1) << 32 | uint64(h2)
+}
+
+func (d *deduplicator) record(entry *LogEntry, t time.Time) *dedupRecord {
+    record := &dedupRecord{
+        level: entry.level,
+        category: entry.category,
+        message: entry.message,
+        err: entry.err,
+        stack: truncateStack(entry.stack, maxDedupStackLen),
+        eventID: entry.eventID,
+        eventCategory: entry.eventCategory,
+        start: t,
+    }
+    for _, key := range d.options.Keys {
+        if value, ok := entry.args[key]; ok {
+            if record.args == nil {
+                record.args = make(map[string]interface{}, len(d.options.Keys) + 3)
+            }
+            record.args[key] = value
+        }
+    }
+    return record
+}
+
+// sweep removes the records of the ended windows or all the records, 
+// returns the summaries of the removed records with repeats
+func (d *deduplicator) sweep(t time.Time, all bool) []*LogEntry {
+    var summaries []*LogEntry
+    for fingerprint, record := range d.records {
+        if all || t.Sub(record.start) >= d.options.Window {
+            if record.repeated > 0 {
+                summaries = append(summaries, record.summary())
+            }
+            delete(d.records, fingerprint)
+        }
+    }
+    return summaries
+}
+
+// expire logs the summaries of the ended windows, so the summaries do not wait for the next repeat
+func (d *deduplicator) expire() {
+    d.Lock()
+    d.timer = nil
+    summaries := d.sweep(now(), false)
+    for _, record := range d.records {
+        if record.repeated > 0 {
+            d.timer = time.AfterFunc(d.options.Window, d.expire)
+            break
+        }
+    }
+    d.Unlock()
+    
+    dispatchAll(summaries)
+}
+
+// truncateStack cuts the stack at the last line which fits in max bytes
+func truncateStack(stack string, max int) string {
+    if len(stack) <= max {
+        return stack
+    }
+    stack = stack[:max]
+    if i := strings.LastIndexByte(stack, '\n'); i > 0 {
+        stack = stack[:i]
+    }
+    return stack
+}
+
+// summary creates the entry which tells how many times the entry is repeated since it is first seen,
+// the error and the stack of the first occurrence are kept
+func (record *dedupRecord) summary() *LogEntry {
+    message := make([]byte, 0, len(record.message) + 100)
+    message = append(message, record.message...)
+    message = append(message, " (repeated "...)
+    message = appendGroupedUint(message, record.repeated)
+    if record.repeated == 1 {
+        message = append(message, " time between "...)
+    } else {
+        message = append(message, " times between "...)
+    }
+    message = record.start.AppendFormat(message, time.RFC3339Nano)
+    message = append(message, " and "...)
+    message = record.last.AppendFormat(message, time.RFC3339Nano)
+    message = append(message, ')')
+    
+    args := make(map[string]interface{}, len(record.args) + 3)
+    for k, v := range record.args {
+        args[k] = v
+    }
+    args["repeated"] = record.repeated
+    args["first_seen"] = record.start
+    args["last_seen"] = record.last
+    
+    entry := acquireLogEntry(record.level, string(message), args)
+    entry.category = record.category
+    entry.err = record.err
+    if record.stack != "" {
+        entry.stack = record.stack
+    }
+    entry.eventID = record.eventID
+    entry.eventCategory = record.eventCategory
+    return entry
+}
+
+// appendGroupedUint appends the number with comma separated thousands like 3,412
+func appendGroupedUint(dst []byte, n uint64) []byte {
+    digits := strconv.AppendUint(nil, n, 10)
+    for i, c := range digits {
+        if i > 0 && (len(digits) - i) % 3 == 0 {
+            dst = append(dst, ',')
+        }
+        dst = append(dst, c)
+    }
+    return dst
+}
+
+func dispatchAll(entries []*LogEntry) {
+    for _, entry := range entries {
+        dispatch(entry, false)
+    }
+}
@@ -0,0 +1,147 @@
+//	The MIT License (MIT)
+//
+//	Copyright (c) 2016, Cagatay Dogan
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//		The above copyright notice and this permission notice shall be included in
+//		all copies or substantial portions of the Software.
+//
+//		THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//		IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//		FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//		AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//		LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//		OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+//		THE SOFTWARE.
+
+package logmanager
+
+import (
+    "errors"
+    "fmt"
+    "strings"
+    "testing"
+    "time"
+)
+
+func TestDeduplication(t *testing.T) {
+    fmt.Println("\nTestDeduplication\n~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~")
+
+    handler := &captureLogHandler{ discardLogHandler: discardLogHandler{ name: "dedup", format: CustomFormat } }
+    RegisterHandler(handler)
+    defer UnregisterHandler("dedup")
+
+    SetDedupOptions(DedupOptions{ Window: 200*time.Millisecond, Keys: []string{ "user" } })
+    defer SetDedupOptions(DedupOptions{})
+    if options := CurrentDedupOptions(); options.Levels != AllLogLevels || options.MaxFingerprints != defaultMaxFingerprints {
+        t.Errorf("unexpected options %+v", options)
+    }
+
+    err := errors.New("db down")
+    for i := 0; i < 1500; i++ {
+        LogError(err, map[string]interface{}{ "user": "alice", "attempt": i })
+    }
+    for i := 0; i < 5; i++ {
+        LogError(err, map[string]interface{}{ "user": "bob" })
+    }
+    LogMessage("db down", map[string]interface{}{ "user": "alice" })
+
+    // the summaries are logged after the window without waiting for another repeat
+    var lines []string
+    deadline := time.Now().Add(2*time.Second)
+    for {
+        lines = handler.captured()
+        if len(lines) >= 5 || time.Now().After(deadline) {
+            break
+        }
+        time.Sleep(20*time.Millisecond)
+    }
+
+    var firsts, summaries []string
+    for _, line := range lines {
+        if strings.Contains(line, "(repeated") {
+            summaries = append(summaries, line)
+        } else {
+            firsts = append(firsts, line)
+        }
+    }
+    if len(firsts) != 3 {
+        t.Errorf("expected the first occurrences only, got %q", firsts)
+    }
+    if len(summaries) != 2 {
+        t.Fatalf("expected 2 summaries, got %q", summaries)
+    }
+
+    var alice, bob bool
+    for _, summary := range summaries {
+        alice = alice || strings.HasPrefix(summary, "db down (repeated 1,499 times between ")
+        bob = bob || strings.HasPrefix(summary, "db down (repeated 4 times between ")
+    }
+    if !alice || !bob {
+        t.Errorf("unexpected summaries %q", summaries)
+    }
+
+    // a new window starts after the window ends
+    LogError(err, map[string]interface{}{ "user": "alice" })
+    LogError(err, map[string]interface{}{ "user": "alice" })
+
+    // changing the options logs the summaries of the current window
+    SetDedupOptions(DedupOptions{})
+    LogError(err, map[string]interface{}{ "user": "alice" })
+    UnregisterHandler("dedup")
+
+    lines = handler.captured()[len(lines):]
+    if len(lines) != 3 || lines[0] != "db down" || !strings.HasPrefix(lines[1], "db down (repeated 1 time between") || lines[2] != "db down" {
+        t.Errorf("unexpected entries after the window %q", lines)
+    }
+}
+
+func TestDedupSummaryError(t *testing.T) {
+    err := fmt.Errorf("query failed: %w", errors.New("db down"))
+    entry := NewErrorLogEntry(err, map[string]interface{}{ "user": "alice" })
+    entry.stack = "goroutine 1 [running]"
+
+    d := &deduplicator{ options: DedupOptions{ Keys: []string{ "user" } } }
+    start := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+    record := d.record(entry, start)
+    record.repeated, record.last = 2, start.Add(time.Second)
+
+    summary := record.summary()
+    defer summary.release()
+    if summary.Err() != err || summary.Stack() != entry.stack || summary.Args()["user"] != "alice" {
+        t.Errorf("expected the error and the stack of the first occurrence, got %s", summary.ToJSON())
+    }
+    if summary.Message() != "query failed: db down (repeated 2 times between 2020-01-02T03:04:05Z and 2020-01-02T03:04:06Z)" || 
+        summary.Args()["first_seen"] != start {
+        t.Errorf("expected the time the entry is first seen, got %s", summary.ToJSON())
+    }
+
+    // the stack kept for the summary is limited
+    entry.stack = strings.Repeat("goroutine 1 [running]\n", 1000)
+    if stack := d.record(entry, start).stack; len(stack) > maxDedupStackLen || !strings.HasSuffix(stack, "[running]") {
+        t.Errorf("expected the stack to be cut at a line, got %d bytes", len(stack))
+    }
+    if json := string(summary.ToJSON()); !strings.Contains(json, `"error":{`) || !strings.Contains(json, `db down`) {
+        t.Errorf("expected the error fields in %s", json)
+    }
+}
+
+func TestAppendGroupedUint(t *testing.T) {
+    for n, expected := range map[uint64]string{
+        0: "0",
+        999: "999",
+        1000: "1,000",
+        3412: "3,412",
+        1234567: "1,234,567",
+    } {
+        if s := string(appendGroupedUint(nil, n)); s != expected {
+            t.Errorf("expected %s, got %s", expected, s)
+        }
+    }
+}
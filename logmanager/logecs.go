@@ -0,0 +1,154 @@
+//	The MIT License (MIT)
+//
+//	Copyright (c) 2016, Cagatay Dogan
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//		The above copyright notice and this permission notice shall be included in
+//		all copies or substantial portions of the Software.
+//
+//		THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//		IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//		FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//		AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//		LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//		OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+//		THE SOFTWARE.
+
+package logmanager
+
+import (
+    "sort"
+    "strconv"
+    "time"
+)
+
+const (
+    // ECSVersion is the Elastic Common Schema version the ECS formatted entries conform to
+    ECSVersion = "8.11.0"
+)
+
+var (
+    ecsReservedFields = map[string]bool{
+        "@timestamp": true,
+        "args": true,
+        "ecs": true,
+        "error": true,
+        "event": true,
+        "host": true,
+        "log": true,
+        "message": true,
+        "span": true,
+        "trace": true,
+    }
+)
+
+// AppendECS appends the entry as an Elastic Common Schema document to dst. The entry duration
+// is written as event.duration in nanoseconds, the stack of error and fatal entries or the stack
+// carried by the logged error as error.stack_trace and the type of the logged error as error.type.
+// The event code is written as event.code and the trace context as trace.id and span.id.
+// The args become top level fields, the args whose names collide with the ECS fields
+// written by the entry are placed into the args object.
+func (entry *LogEntry) AppendECS(dst []byte) []byte {
+    dst = append(dst, `{"@timestamp":"`...)
+    dst = entry.time.UTC().AppendFormat(dst, time.RFC3339Nano)
+    dst = append(dst, `","log":{"level":`...)
+    dst = appendJSONString(dst, entry.level.String())
+    if entry.category != "" {
+        dst = append(dst, `,"logger":`...)
+        dst = appendJSONString(dst, entry.category)
+    }
+    dst = append(dst, `},"message":`...)
+    dst = appendJSONString(dst, entry.message)
+    dst = append(dst, `,"ecs":{"version":"`+ECSVersion+`"},"event":{`...)
+    if entry.hasID() {
+        dst = append(dst, `"id":`...)
+        dst = entry.appendJSONID(dst)
+        dst = append(dst, ',')
+    }
+    if entry.eventID != 0 {
+        dst = append(dst, `"code":"`...)
+        dst = strconv.AppendUint(dst, uint64(entry.eventID), 10)
+        dst = append(dst, `",`...)
+    }
+    dst = append(dst, `"duration":`...)
+    dst = strconv.AppendInt(dst, int64(entry.duration), 10)
+    dst = append(dst, `,"severity":`...)
+    dst = strconv.AppendInt(dst, int64(entry.level.syslogSeverity()), 10)
+    dst = append(dst, `},"host":{"hostname":`...)
+    dst = appendJSONString(dst, HostName())
+    dst = append(dst, '}')
+    if entry.trace.IsValid() {
+        dst = append(dst, `,"trace":{"id":"`...)
+        dst = appendHex(dst, entry.trace.TraceID[:])
+        dst = append(dst, `"}`...)
+        if entry.trace.SpanID.IsValid() {
+            dst = append(dst, `,"span":{"id":"`...)
+            dst = appendHex(dst, entry.trace.SpanID[:])
+            dst = append(dst, `"}`...)
+        }
+    }
+
+    if entry.level.Has(LevelError) || entry.level.Has(LevelFatal) || entry.stack != "" || entry.err != nil {
+        dst = append(dst, `,"error":{"message":`...)
+        dst = appendJSONString(dst, entry.message)
+        
+        stack := entry.stack
+        if entry.err != nil {
+            dst = append(dst, `,"type":`...)
+            dst = appendJSONString(dst, errorKind(entry.err))
+            if stack == "" {
+                stack = errorChainStack(entry.err)
+            }
+        }
+        if stack != "" {
+            dst = append(dst, `,"stack_trace":`...)
+            dst = appendJSONString(dst, stack)
+        }
+        dst = append(dst, '}')
+    }
+
+    if len(entry.args) > 0 {
+        keys := acquireKeys()
+        for k := range entry.args {
+            *keys = append(*keys, k)
+        }
+        sort.Strings(*keys)
+
+        reserved := 0
+        for _, k := range *keys {
+            if ecsReservedFields[k] {
+                reserved++
+                continue
+            }
+            dst = append(dst, ',')
+            dst = appendJSONString(dst, k)
+            dst = append(dst, ':')
+            dst = appendJSONValue(dst, entry.args[k])
+        }
+
+        if reserved > 0 {
+            dst = append(dst, `,"args":{`...)
+            first := true
+            for _, k := range *keys {
+                if ecsReservedFields[k] {
+                    if !first {
+                        dst = append(dst, ',')
+                    }
+                    first = false
+                    dst = appendJSONString(dst, k)
+                    dst = append(dst, ':')
+                    dst = appendJSONValue(dst, entry.args[k])
+                }
+            }
+            dst = append(dst, '}')
+        }
+        releaseKeys(keys)
+    }
+    return append(dst, '}')
+}
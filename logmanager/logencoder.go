@@ -0,0 +1,325 @@
+//	The MIT License (MIT)
+//
+//	Copyright (c) 2016, Cagatay Dogan
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//		The above copyright notice and this permission notice shall be included in
+//		all copies or substantial portions of the Software.
+//
+//		THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//		IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//		FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//		AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//		LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//		OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+//		THE SOFTWARE.
+
+package logmanager
+
+import (
+    "encoding/json"
+    "fmt"
+    "math"
+    "sort"
+    "strconv"
+    "time"
+    "unicode/utf8"
+)
+
+const (
+    hexDigits = "0123456789abcdef"
+)
+
+// appendJSONString appends s to dst as a quoted and escaped JSON string
+func appendJSONString(dst []byte, s string) []byte {
+    dst = append(dst, '"')
+    start := 0
+    for i := 0; i < len(s); {
+        c := s[i]
+        if c < utf8.RuneSelf {
+            if c >= 0x20 && c != '"' && c != '\\' {
+                i++
+                continue
+            }
+            dst = append(dst, s[start:i]...)
+            switch c {
+            case '"', '\\':
+                dst = append(dst, '\\', c)
+            case '\n':
+                dst = append(dst, '\\', 'n')
+            case '\r':
+                dst = append(dst, '\\', 'r')
+            case '\t':
+                dst = append(dst, '\\', 't')
+            default:
+                dst = append(dst, '\\', 'u', '0', '0', hexDigits[c>>4], hexDigits[c&0x0f])
+            }
+            i++
+            start = i
+            continue
+        }
+        r, size := utf8.DecodeRuneInString(s[i:])
+        if r == utf8.RuneError && size == 1 {
+            dst = append(dst, s[start:i]...)
+            dst = append(dst, "\ufffd"...)
+            i += size
+            start = i
+            continue
+        }
+        if r == '\u2028' || r == '\u2029' {
+            dst = append(dst, s[start:i]...)
+            dst = append(dst, '\\', 'u', '2', '0', '2', hexDigits[r&0x0f])
+            i += size
+            start = i
+            continue
+        }
+        i += size
+    }
+    dst = append(dst, s[start:]...)
+    return append(dst, '"')
+}
+
+// appendJSONFloat appends f using the same notation encoding/json uses
+func appendJSONFloat(dst []byte, f float64, bits int) []byte {
+    if math.IsNaN(f) || math.IsInf(f, 0) {
+        return appendJSONString(dst, strconv.FormatFloat(f, 'g', -1, bits))
+    }
+
+    abs := math.Abs(f)
+    format := byte('f')
+    if abs != 0 {
+        if bits == 64 && (abs < 1e-6 || abs >= 1e21) ||
+            bits == 32 && (float32(abs) < 1e-6 || float32(abs) >= 1e21) {
+            format = 'e'
+        }
+    }
+
+    dst = strconv.AppendFloat(dst, f, format, -1, bits)
+    if format == 'e' {
+        // clean up e-09 to e-9
+        n := len(dst)
+        if n >= 4 && dst[n-4] == 'e' && dst[n-3] == '-' && dst[n-2] == '0' {
+            dst[n-2] = dst[n-1]
+            dst = dst[:n-1]
+        }
+    }
+    return dst
+}
+
+// appendJSONValue appends value to dst as JSON, using reflection only for the types not handled here
+func appendJSONValue(dst []byte, value interface{}) []byte {
+    switch v := value.(type) {
+    case nil:
+        return append(dst, "null"...)
+    case string:
+        return appendJSONString(dst, v)
+    case LazyValue:
+        return appendJSONValue(dst, v.value())
+    case []byte:
+        return appendJSONString(dst, string(v))
+    case bool:
+        return strconv.AppendBool(dst, v)
+    case int:
+        return strconv.AppendInt(dst, int64(v), 10)
+    case int8:
+        return strconv.AppendInt(dst, int64(v), 10)
+    case int16:
+        return strconv.AppendInt(dst, int64(v), 10)
+    case int32:
+        return strconv.AppendInt(dst, int64(v), 10)
+    case int64:
+        return strconv.AppendInt(dst, v, 10)
+    case uint:
+        return strconv.AppendUint(dst, uint64(v), 10)
+    case uint8:
+        return strconv.AppendUint(dst, uint64(v), 10)
+    case uint16:
+        return strconv.AppendUint(dst, uint64(v), 10)
+    case uint32:
+        return strconv.AppendUint(dst, uint64(v), 10)
+    case uint64:
+        return strconv.AppendUint(dst, v, 10)
+    case float32:
+        return appendJSONFloat(dst, float64(v), 32)
+    case float64:
+        return appendJSONFloat(dst, v, 64)
+    case time.Time:
+        dst = append(dst, '"')
+        dst = v.AppendFormat(dst, time.RFC3339Nano)
+        return append(dst, '"')
+    case time.Duration:
+        return strconv.AppendInt(dst, int64(v), 10)
+    case json.Number:
+        if v == "" {
+            return append(dst, '0')
+        }
+        return append(dst, v...)
+    case error:
+        return appendJSONString(dst, v.Error())
+    case map[string]interface{}:
+        return appendJSONMap(dst, v)
+    case map[string]string:
+        keys := acquireKeys()
+        for k := range v {
+            *keys = append(*keys, k)
+        }
+        sort.Strings(*keys)
+
+        dst = append(dst, '{')
+        for i, k := range *keys {
+            if i > 0 {
+                dst = append(dst, ',')
+            }
+            dst = appendJSONString(dst, k)
+            dst = append(dst, ':')
+            dst = appendJSONString(dst, v[k])
+        }
+        releaseKeys(keys)
+        return append(dst, '}')
+    case []interface{}:
+        dst = append(dst, '[')
+        for i, item := range v {
+            if i > 0 {
+                dst = append(dst, ',')
+            }
+            dst = appendJSONValue(dst, item)
+        }
+        return append(dst, ']')
+    case []string:
+        dst = append(dst, '[')
+        for i, item := range v {
+            if i > 0 {
+                dst = append(dst, ',')
+            }
+            dst = appendJSONString(dst, item)
+        }
+        return append(dst, ']')
+    }
+
+    b, err := json.Marshal(value)
+    if err != nil {
+        return appendJSONString(dst, fmt.Sprint(value))
+    }
+    return append(dst, b...)
+}
+
+// appendJSONMap appends m to dst as a JSON object with sorted keys as encoding/json does
+func appendJSONMap(dst []byte, m map[string]interface{}) []byte {
+    keys := acquireKeys()
+    for k := range m {
+        *keys = append(*keys, k)
+    }
+    sort.Strings(*keys)
+
+    dst = append(dst, '{')
+    for i, k := range *keys {
+        if i > 0 {
+            dst = append(dst, ',')
+        }
+        dst = appendJSONString(dst, k)
+        dst = append(dst, ':')
+        dst = appendJSONValue(dst, m[k])
+    }
+    releaseKeys(keys)
+    return append(dst, '}')
+}
+
+// appendDuration appends d to dst in the format of time.Duration.String
+func appendDuration(dst []byte, d time.Duration) []byte {
+    var buf [32]byte
+    w := len(buf)
+
+    u := uint64(d)
+    neg := d < 0
+    if neg {
+        u = -u
+    }
+
+    if u < uint64(time.Second) {
+        var prec int
+        w--
+        buf[w] = 's'
+        w--
+        switch {
+        case u == 0:
+            buf[w] = '0'
+            return append(dst, buf[w:]...)
+        case u < uint64(time.Microsecond):
+            prec = 0
+            buf[w] = 'n'
+        case u < uint64(time.Millisecond):
+            prec = 3
+            // U+00B5 'µ' micro sign == 0xC2 0xB5
+            w--
+            copy(buf[w:], "µ")
+        default:
+            prec = 6
+            buf[w] = 'm'
+        }
+        w, u = fmtFrac(buf[:w], u, prec)
+        w = fmtInt(buf[:w], u)
+    } else {
+        w--
+        buf[w] = 's'
+        w, u = fmtFrac(buf[:w], u, 9)
+        w = fmtInt(buf[:w], u%60)
+        u /= 60
+        if u > 0 {
+            w--
+            buf[w] = 'm'
+            w = fmtInt(buf[:w], u%60)
+            u /= 60
+            if u > 0 {
+                w--
+                buf[w] = 'h'
+                w = fmtInt(buf[:w], u)
+            }
+        }
+    }
+
+    if neg {
+        w--
+        buf[w] = '-'
+    }
+    return append(dst, buf[w:]...)
+}
+
+func fmtFrac(buf []byte, v uint64, prec int) (int, uint64) {
+    w := len(buf)
+    print := false
+    for i := 0; i < prec; i++ {
+        digit := v % 10
+        print = print || digit != 0
+        if print {
+            w--
+            buf[w] = byte(digit) + '0'
+        }
+        v /= 10
+    }
+    if print {
+        w--
+        buf[w] = '.'
+    }
+    return w, v
+}
+
+func fmtInt(buf []byte, v uint64) int {
+    w := len(buf)
+    if v == 0 {
+        w--
+        buf[w] = '0'
+    } else {
+        for v > 0 {
+            w--
+            buf[w] = byte(v%10) + '0'
+            v /= 10
+        }
+    }
+    return w
+}
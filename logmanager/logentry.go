@@ -25,69 +25,116 @@ package logmanager
 import (
     "bytes"
     "encoding/json"
-    "fmt"
     "runtime"
+    "strconv"
+    "sync/atomic"
     "time"
     "github.com/ocdogan/goutils/uuid"
 )
 
 // LogEntry is used to send the information to handlers
 type LogEntry struct {
+    uid uuid.UUID
     id string
     time time.Time
     duration time.Duration
     message string
     stack string
     level LogLevel
+    category string
+    trace TraceContext
+    eventID uint32
+    eventCategory string
     args map[string]interface{}
+    err error
+    format string
+    formatArgs []interface{}
+    resolved bool
+    pooled bool
+    refs int32
 }
 
-// NewInfoLogEntry creates a new log entry with info level which will be send to handlers
-func NewInfoLogEntry(message string, args map[string]interface{}) *LogEntry {
-    uuid, _ := uuid.NewUUID()
+func newLogEntry(level LogLevel, message string, args map[string]interface{}) *LogEntry {
     result := &LogEntry{
-        id: uuid.String(),
-        time: time.Now(),
         message: message,
         args: args,
-        level: LevelInfo,
+        level: level,
     }
     
+    result.identify()
     result.writeStack()
     return result
 }
 
-// NewWarningLogEntry creates a new log entry with warning level which will be send to handlers
-func NewWarningLogEntry(message string, args map[string]interface{}) *LogEntry {
-    uuid, _ := uuid.NewUUID()
-    result := &LogEntry{
-        id: uuid.String(),
-        time: time.Now(),
-        message: message,
-        args: args,
-        level: LevelWarning,
-    }
+// acquireLogEntry gets an entry from the pool which will be recycled 
+// after all the buckets are done with it
+func acquireLogEntry(level LogLevel, message string, args map[string]interface{}) *LogEntry {
+    result := entryPool.Get().(*LogEntry)
+    result.identify()
+    result.message = message
+    result.args = args
+    result.level = level
+    result.pooled = true
+    result.refs = 1
     
     result.writeStack()
     return result
 }
 
+// identify sets the id and the creation time of the entry using the current log clock and id generator
+func (entry *LogEntry) identify() {
+    entry.time = now()
+    if generator := currentIDGenerator(); generator != nil {
+        entry.id = generator()
+        return
+    }
+    entry.uid = uuid.NewFastUUID()
+}
+
+func (entry *LogEntry) hasID() bool {
+    return entry.id != "" || !entry.uid.IsZero()
+}
+
+func (entry *LogEntry) appendJSONID(dst []byte) []byte {
+    if entry.id != "" {
+        return appendJSONString(dst, entry.id)
+    }
+    dst = append(dst, '"')
+    dst = entry.uid.AppendString(dst)
+    return append(dst, '"')
+}
+
+func (entry *LogEntry) retain() {
+    if entry.pooled {
+        atomic.AddInt32(&entry.refs, 1)
+    }
+}
+
+func (entry *LogEntry) release() {
+    if entry != nil && entry.pooled && atomic.AddInt32(&entry.refs, -1) == 0 {
+        *entry = LogEntry{}
+        entryPool.Put(entry)
+    }
+}
+
+// NewInfoLogEntry creates a new log entry with info level which will be send to handlers
+func NewInfoLogEntry(message string, args map[string]interface{}) *LogEntry {
+    return newLogEntry(LevelInfo, message, args)
+}
+
+// NewWarningLogEntry creates a new log entry with warning level which will be send to handlers
+func NewWarningLogEntry(message string, args map[string]interface{}) *LogEntry {
+    return newLogEntry(LevelWarning, message, args)
+}
+
 // NewErrorLogEntry creates a new log entry with error level which will be send to handlers
 func NewErrorLogEntry(err error, args map[string]interface{}) *LogEntry {
     var message string
     if err != nil {
         message = err.Error()
     }
-    uuid, _ := uuid.NewUUID()
-    result := &LogEntry{
-        id: uuid.String(),
-        time: time.Now(),
-        message: message,
-        args: args,
-        level: LevelError,
-    }
-    
-    result.writeStack()
+    result := newLogEntry(LevelError, message, args)
+    result.err = err
     return result
 }
 
@@ -97,16 +144,8 @@ func NewFatalLogEntry(err error, args map[string]interface{}) *LogEntry {
     if err != nil {
         message = err.Error()
     }
-    uuid, _ := uuid.NewUUID()
-    result := &LogEntry{
-        id: uuid.String(),
-        time: time.Now(),
-        message: message,
-        args: args,
-        level: LevelFatal,
-    }
-    
-    result.writeStack()
+    result := newLogEntry(LevelFatal, message, args)
+    result.err = err
     return result
 }
 
@@ -121,7 +160,10 @@ func (entry *LogEntry) writeStack() {
 
 // ID returns the id of the entry
 func (entry *LogEntry) ID() string {
-    return entry.id
+    if entry.id != "" || entry.uid.IsZero() {
+        return entry.id
+    }
+    return entry.uid.String()
 }
 
 // Time returns the creation time of the entry
@@ -149,100 +191,214 @@ func (entry *LogEntry) Level() LogLevel {
     return entry.level
 }
 
+// Category returns the logger name or the category of the entry
+func (entry *LogEntry) Category() string {
+    return entry.category
+}
+
+// SetCategory sets the logger name or the category of the entry which can be used to route the entry
+func (entry *LogEntry) SetCategory(category string) {
+    entry.category = category
+}
+
 // Args returns the arguments of the entry
 func (entry *LogEntry) Args() map[string]interface{} {
     return entry.args
 }
 
+// Err returns the error the entry is created for, nil for info and warning entries
+func (entry *LogEntry) Err() error {
+    return entry.err
+}
+
 // StartWatch starts timer to measure the time passed
 func (entry *LogEntry) StartWatch() {
-    entry.time = time.Now()
+    entry.time = now()
 }
 
 // StopWatch stops the timer to measure the time passed
 func (entry *LogEntry) StopWatch() {
-    entry.duration = time.Now().Sub(entry.time)
+    entry.duration = now().Sub(entry.time)
 }
 
 // ToJSON returns the JSON formatted entry as byte array
 func (entry *LogEntry) ToJSON() []byte {
-    b, e := json.Marshal(struct{
+    if entry == nil {
+        return nil
+    }
+    return entry.AppendJSON(nil)
+}
+
+// AppendJSON appends the JSON formatted entry to dst and returns the extended buffer
+func (entry *LogEntry) AppendJSON(dst []byte) []byte {
+    dst = append(dst, '{')
+    if entry.hasID() {
+        dst = append(dst, `"id":`...)
+        dst = entry.appendJSONID(dst)
+        dst = append(dst, ',')
+    }
+    dst = append(dst, `"time":"`...)
+    dst = entry.time.AppendFormat(dst, time.RFC3339Nano)
+    dst = append(dst, `","duration":`...)
+    dst = strconv.AppendInt(dst, int64(entry.duration), 10)
+    dst = append(dst, `,"level":`...)
+    dst = appendJSONString(dst, entry.level.String())
+    if entry.category != "" {
+        dst = append(dst, `,"category":`...)
+        dst = appendJSONString(dst, entry.category)
+    }
+    if entry.trace.IsValid() {
+        dst = entry.appendJSONTrace(dst)
+    }
+    if entry.eventID != 0 {
+        dst = append(dst, `,"event_id":`...)
+        dst = strconv.AppendUint(dst, uint64(entry.eventID), 10)
+        if entry.eventCategory != "" {
+            dst = append(dst, `,"event_category":`...)
+            dst = appendJSONString(dst, entry.eventCategory)
+        }
+    }
+    dst = append(dst, `,"message":`...)
+    dst = appendJSONString(dst, entry.message)
+    dst = append(dst, `,"stack":`...)
+    dst = appendJSONString(dst, entry.stack)
+    if entry.err != nil {
+        dst = append(dst, `,"error":`...)
+        dst = appendJSONError(dst, entry.err)
+    }
+    
+    if len(entry.args) > 0 {
+        dst = append(dst, `,"args":`...)
+        dst = appendJSONMap(dst, entry.args)
+    }
+    return append(dst, '}')
+}
+
+// ParseJSON parses a JSON line written by LogEntry.ToJSON back into a log entry,
+// the numbers in the args are kept as json.Number. The error object is parsed as a *LoggedError.
+func ParseJSON(line []byte) (*LogEntry, error) {
+    var data struct{
         ID string `json:"id"`
         Time time.Time `json:"time"`
         Duration time.Duration `json:"duration"`
         Level string `json:"level"`
+        Category string `json:"category"`
+        TraceID string `json:"trace_id"`
+        SpanID string `json:"span_id"`
+        TraceFlags string `json:"trace_flags"`
+        EventID uint32 `json:"event_id"`
+        EventCategory string `json:"event_category"`
         Message string `json:"message"`
         Stack string `json:"stack"`
-        Args map[string]interface{} `json:"args,omitempty"`
-    }{
-        ID: entry.id,
-        Time: entry.time,
-        Duration: entry.duration,
-        Level: entry.level.String(),
-        Message: entry.message,
-        Stack: entry.stack,
-        Args: entry.args,
-    })
-    if e != nil {
-        return nil
+        Error *LoggedError `json:"error"`
+        Args map[string]interface{} `json:"args"`
     }
-    return b
-}
-
-// ToJSON returns the text line formatted entry as byte array
-func (entry *LogEntry) ToText() []byte {
-    if entry == nil {
-        return nil
+    
+    decoder := json.NewDecoder(bytes.NewReader(line))
+    decoder.UseNumber()
+    if err := decoder.Decode(&data); err != nil {
+        return nil, err
     }
     
-    buffer := &bytes.Buffer{}
-
-    writeKvToBuffer(buffer, "id", entry.id)
-    writeKvToBuffer(buffer, "time", entry.time)
-    writeKvToBuffer(buffer, "duration", entry.duration)
-    writeKvToBuffer(buffer, "level", entry.level.String())
-    writeKvToBuffer(buffer, "message", entry.message)
-    writeKvToBuffer(buffer, "stack", entry.stack)
+    level, err := ParseLogLevel(data.Level)
+    if err != nil {
+        return nil, err
+    }
     
-    if entry.args != nil {
-        for k, v := range entry.args {
-            writeKvToBuffer(buffer, k, v)
-        }
+    entry := &LogEntry{
+        id: data.ID,
+        time: data.Time,
+        duration: data.Duration,
+        level: level,
+        category: data.Category,
+        eventID: data.EventID,
+        eventCategory: data.EventCategory,
+        message: data.Message,
+        stack: data.Stack,
+        args: data.Args,
+    }
+    if data.Error != nil {
+        entry.err = data.Error
     }
     
-    return buffer.Bytes()
+    for _, field := range [...][2]string{ 
+        { "trace_id", data.TraceID }, { "span_id", data.SpanID }, { "trace_flags", data.TraceFlags },
+    } {
+        if field[1] != "" {
+            if err = entry.setTraceField(field[0], field[1]); err != nil {
+                return nil, err
+            }
+        }
+    }
+    return entry, nil
+}
+
+// appendFormat appends the entry to dst in the given encoded format
+func (entry *LogEntry) appendFormat(dst []byte, format LogFormat) []byte {
+    switch format {
+    case JSONFormat:
+        return entry.AppendJSON(dst)
+    case TextFormat:
+        return entry.AppendText(dst)
+    case GELFFormat:
+        return entry.AppendGELF(dst)
+    case ECSFormat:
+        return entry.AppendECS(dst)
+    }
+    return dst
 }
 
-func textOrNumber(value string) bool {
-	for _, c := range value {
-		if !((c >= 'a' && c <= 'z') ||
-			(c >= 'A' && c <= 'Z') ||
-			(c >= '0' && c <= '9') ||
-            c == '-' || c == '+' || 
-            c == '.' || c == ',') {
-			return false
-		}
-	}
-	return true
+// ToText returns the logfmt formatted entry as byte array
+func (entry *LogEntry) ToText() []byte {
+    if entry == nil {
+        return nil
+    }
+    return entry.AppendText(nil)
 }
 
-func writeKvToBuffer(buffer *bytes.Buffer, key string, value interface{}) {
-    buffer.WriteString(key)
+// AppendText appends the logfmt formatted entry to dst and returns the extended buffer.
+// The entry fields are followed by the args in sorted key order, 
+// nested maps and slices are flattened into dotted keys.
+// The arg keys which collide with the entry fields are written with the "args." prefix.
+func (entry *LogEntry) AppendText(dst []byte) []byte {
+    start := len(dst)
+    if entry.id != "" {
+        dst = appendLogfmtPair(dst, start, "id", entry.id)
+    } else if !entry.uid.IsZero() {
+        dst = append(dst, "id="...)
+        dst = entry.uid.AppendString(dst)
+    }
     
-    switch value.(type) {
-    case nil:
-        break
-    case string:
-        if !textOrNumber(value.(string)) {
-            fmt.Fprintf(buffer, "=%q ", value)
-        } else {
-            buffer.WriteString("=\"")
-            buffer.WriteString(value.(string))
-            buffer.WriteString("\" ")
+    dst = appendLogfmtSep(dst, start)
+    dst = append(dst, "time="...)
+    dst = entry.time.AppendFormat(dst, time.RFC3339Nano)
+    dst = append(dst, " duration="...)
+    dst = appendDuration(dst, entry.duration)
+    dst = appendLogfmtPair(dst, start, "level", entry.level.String())
+    if entry.category != "" {
+        dst = appendLogfmtPair(dst, start, "category", entry.category)
+    }
+    if entry.trace.IsValid() {
+        dst = append(dst, " trace_id="...)
+        dst = appendHex(dst, entry.trace.TraceID[:])
+        if entry.trace.SpanID.IsValid() {
+            dst = append(dst, " span_id="...)
+            dst = appendHex(dst, entry.trace.SpanID[:])
         }
-    default:
-        buffer.WriteString("=\"")
-        fmt.Fprint(buffer, value)         
-        buffer.WriteString("\" ")
+        dst = append(dst, " trace_flags="...)
+        dst = appendHex(dst, []byte{ entry.trace.Flags })
     }
-}
\ No newline at end of file
+    if entry.eventID != 0 {
+        dst = append(dst, " event_id="...)
+        dst = strconv.AppendUint(dst, uint64(entry.eventID), 10)
+        if entry.eventCategory != "" {
+            dst = appendLogfmtPair(dst, start, "event_category", entry.eventCategory)
+        }
+    }
+    dst = appendLogfmtPair(dst, start, "message", entry.message)
+    if entry.stack != "" {
+        dst = appendLogfmtPair(dst, start, "stack", entry.stack)
+    }
+    
+    return appendLogfmtArgs(dst, start, entry.args, true)
+}
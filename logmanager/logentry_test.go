@@ -0,0 +1,278 @@
+//	The MIT License (MIT)
+//
+//	Copyright (c) 2016, Cagatay Dogan
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//		The above copyright notice and this permission notice shall be included in
+//		all copies or substantial portions of the Software.
+//
+//		THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//		IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//		FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//		AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//		LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//		OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+//		THE SOFTWARE.
+
+package logmanager
+
+import (
+    "bytes"
+    "encoding/json"
+    "errors"
+    "flag"
+    "fmt"
+    "os"
+    "path/filepath"
+    "sync/atomic"
+    "testing"
+    "time"
+)
+
+type discardLogHandler struct {
+    name string
+    format LogFormat
+    processed int64
+}
+
+func (handler *discardLogHandler) Name() string {
+    return handler.name
+}
+
+func (handler *discardLogHandler) Enabled() bool {
+    return true
+}
+
+func (handler *discardLogHandler) Enable() {
+}
+
+func (handler *discardLogHandler) Disable() {
+}
+
+func (handler *discardLogHandler) Level() LogLevel {
+    return AllLogLevels
+}
+
+func (handler *discardLogHandler) Format() LogFormat {
+    return handler.format
+}
+
+func (handler *discardLogHandler) QueueLen() int {
+    return -1
+}
+
+func (handler *discardLogHandler) Process(entry interface{}) {
+    atomic.AddInt64(&handler.processed, 1)
+}
+
+var benchArgs = map[string]interface{}{
+    "str": "value with spaces",
+    "int": 42,
+    "float": 3.25,
+    "bool": true,
+    "time": time.Date(2016, 5, 4, 3, 2, 1, 0, time.UTC),
+    "duration": 1500*time.Millisecond,
+    "err": errors.New("failed"),
+}
+
+func TestLogEntryJSON(t *testing.T) {
+    fmt.Println("\nTestLogEntryJSON\n~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~")
+
+    entry := NewInfoLogEntry("line\n\"quoted\"\t ", benchArgs)
+    entry.duration = 250*time.Millisecond
+
+    var decoded struct{
+        ID string `json:"id"`
+        Time time.Time `json:"time"`
+        Duration time.Duration `json:"duration"`
+        Level string `json:"level"`
+        Message string `json:"message"`
+        Args map[string]interface{} `json:"args"`
+    }
+    if err := json.Unmarshal(entry.ToJSON(), &decoded); err != nil {
+        t.Fatalf("invalid JSON %s: %v", entry.ToJSON(), err)
+    }
+
+    if decoded.ID != entry.ID() || !decoded.Time.Equal(entry.Time()) ||
+        decoded.Duration != entry.Duration() || decoded.Level != "info" ||
+        decoded.Message != entry.Message() {
+        t.Errorf("unexpected decoded entry %+v", decoded)
+    }
+    if decoded.Args["str"] != "value with spaces" || decoded.Args["int"] != float64(42) ||
+        decoded.Args["err"] != "failed" || decoded.Args["time"] != "2016-05-04T03:02:01Z" ||
+        decoded.Args["duration"] != float64(1500*time.Millisecond) {
+        t.Errorf("unexpected decoded args %v", decoded.Args)
+    }
+}
+
+var updateGolden = flag.Bool("update", false, "update the golden files")
+
+func checkGolden(t *testing.T, name string, actual []byte) {
+    path := filepath.Join("testdata", name)
+    if *updateGolden {
+        if err := os.WriteFile(path, actual, 0644); err != nil {
+            t.Fatal(err)
+        }
+    }
+
+    expected, err := os.ReadFile(path)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if !bytes.Equal(expected, actual) {
+        t.Errorf("%s mismatch\nexpected: %s\nactual:   %s", name, expected, actual)
+    }
+}
+
+func TestLogEntryGolden(t *testing.T) {
+    fmt.Println("\nTestLogEntryGolden\n~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~")
+
+    SetClock(NewFixedClock(time.Date(2016, 5, 4, 3, 2, 1, 0, time.UTC)))
+    SetIDGenerator(NewSequentialIDGenerator("entry-"))
+    defer SetClock(nil)
+    defer SetIDGenerator(nil)
+
+    entry := NewWarningLogEntry("disk is \"almost\" full", map[string]interface{}{
+        "free": 0.05,
+    })
+    entry.StartWatch()
+    entry.StopWatch()
+
+    if entry.ID() != "entry-1" {
+        t.Errorf("expected sequential id entry-1, got %s", entry.ID())
+    }
+    checkGolden(t, "entry.json", entry.ToJSON())
+    checkGolden(t, "entry.txt", entry.ToText())
+
+    SetIDGenerator(NoIDGenerator)
+    if entry = NewInfoLogEntry("no id", nil); entry.ID() != "" || bytes.Contains(entry.ToJSON(), []byte(`"id"`)) {
+        t.Errorf("expected an entry without id, got %s", entry.ToJSON())
+    }
+}
+
+func TestLogEntryTextRoundTrip(t *testing.T) {
+    fmt.Println("\nTestLogEntryTextRoundTrip\n~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~")
+
+    entry := NewErrorLogEntry(errors.New("connection \"reset\"\nby peer"), map[string]interface{}{
+        "a key": "x=y",
+        "empty": "",
+        "none": nil,
+        "count": 3,
+        "nested": map[string]interface{}{
+            "list": []interface{}{ "first", 2 },
+            "name": "db",
+        },
+    })
+    entry.duration = 1500*time.Millisecond
+
+    text := entry.ToText()
+    for i := 0; i < 10; i++ {
+        if again := entry.ToText(); !bytes.Equal(text, again) {
+            t.Fatalf("text output is not deterministic:\n%s\n%s", text, again)
+        }
+    }
+
+    expectedArgs := `"a key"="x=y" count=3 empty="" nested.list.0=first nested.list.1=2 nested.name=db none=`
+    if !bytes.HasSuffix(text, []byte(expectedArgs)) {
+        t.Errorf("unexpected args in %s", text)
+    }
+
+    parsed, err := ParseText(text)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if parsed.ID() != entry.ID() || !parsed.Time().Equal(entry.Time()) || parsed.Duration() != entry.Duration() ||
+        parsed.Level() != entry.Level() || parsed.Message() != entry.Message() {
+        t.Errorf("unexpected parsed entry %s", parsed.ToText())
+    }
+    if args := parsed.Args(); args["a key"] != "x=y" || args["empty"] != "" || args["none"] != nil ||
+        args["nested.list.1"] != "2" {
+        t.Errorf("unexpected parsed args %v", args)
+    }
+    if again := parsed.ToText(); !bytes.Equal(text, again) {
+        t.Errorf("round trip mismatch:\n%s\n%s", text, again)
+    }
+}
+
+func TestLogEntryTextReservedArgs(t *testing.T) {
+    fmt.Println("\nTestLogEntryTextReservedArgs\n~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~")
+
+    entry := NewInfoLogEntry("reserved keys", map[string]interface{}{
+        "time": "yesterday",
+        "level": "custom",
+        "message": "shadowed",
+        "args.raw": 1,
+        "plain": "value",
+    })
+
+    text := entry.ToText()
+    expectedArgs := `args.args.raw=1 args.level=custom args.message=shadowed plain=value args.time=yesterday`
+    if !bytes.HasSuffix(text, []byte(expectedArgs)) {
+        t.Errorf("unexpected args in %s", text)
+    }
+
+    parsed, err := ParseText(text)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if !parsed.Time().Equal(entry.Time()) || parsed.Level() != LevelInfo || parsed.Message() != "reserved keys" {
+        t.Errorf("unexpected parsed entry %s", parsed.ToText())
+    }
+    if args := parsed.Args(); len(args) != 5 || args["time"] != "yesterday" || args["level"] != "custom" ||
+        args["message"] != "shadowed" || args["args.raw"] != "1" || args["plain"] != "value" {
+        t.Errorf("unexpected parsed args %v", args)
+    }
+    if again := parsed.ToText(); !bytes.Equal(text, again) {
+        t.Errorf("round trip mismatch:\n%s\n%s", text, again)
+    }
+}
+
+func TestAppendDuration(t *testing.T) {
+    for _, d := range []time.Duration{ 0, 1, 999, 1500, 1500*time.Microsecond,
+        -2*time.Second, 90*time.Minute + 30*time.Millisecond } {
+        if s := string(appendDuration(nil, d)); s != d.String() {
+            t.Errorf("expected %s, got %s", d.String(), s)
+        }
+    }
+}
+
+func BenchmarkLogEntryAppendJSON(b *testing.B) {
+    entry := NewInfoLogEntry("benchmark message", benchArgs)
+    buf := make([]byte, 0, 1024)
+
+    b.ReportAllocs()
+    b.ResetTimer()
+    for i := 0; i < b.N; i++ {
+        buf = entry.AppendJSON(buf[:0])
+    }
+}
+
+func BenchmarkLogEntryAppendText(b *testing.B) {
+    entry := NewInfoLogEntry("benchmark message", benchArgs)
+    buf := make([]byte, 0, 1024)
+
+    b.ReportAllocs()
+    b.ResetTimer()
+    for i := 0; i < b.N; i++ {
+        buf = entry.AppendText(buf[:0])
+    }
+}
+
+func BenchmarkLogMessage(b *testing.B) {
+    RegisterHandler(&discardLogHandler{ name: "bench-json", format: JSONFormat })
+    RegisterHandler(&discardLogHandler{ name: "bench-text", format: TextFormat })
+    defer UnregisterHandler("bench-json")
+    defer UnregisterHandler("bench-text")
+
+    b.ReportAllocs()
+    b.ResetTimer()
+    for i := 0; i < b.N; i++ {
+        LogMessage("benchmark message", benchArgs)
+    }
+}
@@ -0,0 +1,198 @@
+//	The MIT License (MIT)
+//
+//	Copyright (c) 2016, Cagatay Dogan
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//		The above copyright notice and this permission notice shall be included in
+//		all copies or substantial portions of the Software.
+//
+//		THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//		IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//		FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//		AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//		LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//		OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+//		THE SOFTWARE.
+
+package logmanager
+
+import (
+    "fmt"
+    "reflect"
+)
+
+const (
+    maxErrorChain = 32
+)
+
+// ErrorFielder is implemented by the errors which carry structured fields, 
+// the fields are logged with the error object of the entry
+type ErrorFielder interface {
+    ErrorFields() map[string]interface{}
+}
+
+// StackTracer is implemented by the errors which carry the stack trace of the point they are created at
+type StackTracer interface {
+    StackTrace() string
+}
+
+// LoggedError is the error of an entry parsed back from the JSON format, it is written 
+// with the kind, the causes, the stack and the fields it is parsed with
+type LoggedError struct {
+    Kind string `json:"kind"`
+    Message string `json:"message"`
+    Causes []*LoggedError `json:"causes"`
+    Stack string `json:"stack"`
+    Fields map[string]interface{} `json:"fields"`
+}
+
+func (e *LoggedError) Error() string {
+    return e.Message
+}
+
+// Unwrap returns the causes, the causes are flattened in the JSON format
+func (e *LoggedError) Unwrap() []error {
+    if len(e.Causes) == 0 {
+        return nil
+    }
+    causes := make([]error, len(e.Causes))
+    for i, cause := range e.Causes {
+        causes[i] = cause
+    }
+    return causes
+}
+
+func (e *LoggedError) StackTrace() string {
+    return e.Stack
+}
+
+func (e *LoggedError) ErrorFields() map[string]interface{} {
+    return e.Fields
+}
+
+// walkErrorChain calls fn for err and its causes in depth first order, following both 
+// Unwrap() error and Unwrap() []error. The walk is limited to 32 errors.
+func walkErrorChain(err error, fn func(e error, depth int)) {
+    count := 0
+    var walk func(e error, depth int)
+    walk = func(e error, depth int) {
+        if e == nil || count >= maxErrorChain {
+            return
+        }
+        count++
+        fn(e, depth)
+        
+        switch x := e.(type) {
+        case interface{ Unwrap() []error }:
+            for _, cause := range x.Unwrap() {
+                walk(cause, depth+1)
+            }
+        case interface{ Unwrap() error }:
+            walk(x.Unwrap(), depth+1)
+        }
+    }
+    walk(err, 0)
+}
+
+// errorKind returns the type name of the error, or the kind a LoggedError is parsed with
+func errorKind(err error) string {
+    if logged, ok := err.(*LoggedError); ok {
+        return logged.Kind
+    }
+    return reflect.TypeOf(err).String()
+}
+
+// errorStack returns the stack trace carried by the error itself. Besides StackTracer, 
+// Stack() []byte, Stack() string and the StackTrace() methods of github.com/pkg/errors 
+// style errors which return a formattable frame list are supported.
+func errorStack(err error) string {
+    switch x := err.(type) {
+    case StackTracer:
+        return x.StackTrace()
+    case interface{ Stack() []byte }:
+        return string(x.Stack())
+    case interface{ Stack() string }:
+        return x.Stack()
+    }
+    
+    method := reflect.ValueOf(err).MethodByName("StackTrace")
+    if method.IsValid() && method.Type().NumIn() == 0 && method.Type().NumOut() == 1 {
+        return fmt.Sprintf("%+v", method.Call(nil)[0].Interface())
+    }
+    return ""
+}
+
+// errorChainStack returns the stack trace of the innermost error carrying one
+func errorChainStack(err error) string {
+    var stack string
+    walkErrorChain(err, func(e error, depth int) {
+        if s := errorStack(e); s != "" {
+            stack = s
+        }
+    })
+    return stack
+}
+
+// appendJSONError appends the error as a JSON object with its kind, message, causes, 
+// the stack trace of the innermost error carrying one and the fields of the errors 
+// in the chain, the fields of the outer errors win
+func appendJSONError(dst []byte, err error) []byte {
+    dst = append(dst, `{"kind":`...)
+    dst = appendJSONString(dst, errorKind(err))
+    dst = append(dst, `,"message":`...)
+    dst = appendJSONString(dst, err.Error())
+    
+    var stack string
+    var fields map[string]interface{}
+    causes := 0
+    
+    walkErrorChain(err, func(e error, depth int) {
+        if depth > 0 {
+            if causes == 0 {
+                dst = append(dst, `,"causes":[`...)
+            } else {
+                dst = append(dst, ',')
+            }
+            causes++
+            
+            dst = append(dst, `{"kind":`...)
+            dst = appendJSONString(dst, errorKind(e))
+            dst = append(dst, `,"message":`...)
+            dst = appendJSONString(dst, e.Error())
+            dst = append(dst, '}')
+        }
+        
+        if s := errorStack(e); s != "" {
+            stack = s
+        }
+        if fielder, ok := e.(ErrorFielder); ok {
+            for k, v := range fielder.ErrorFields() {
+                if fields == nil {
+                    fields = make(map[string]interface{})
+                }
+                if _, ok := fields[k]; !ok {
+                    fields[k] = v
+                }
+            }
+        }
+    })
+    
+    if causes > 0 {
+        dst = append(dst, ']')
+    }
+    if stack != "" {
+        dst = append(dst, `,"stack":`...)
+        dst = appendJSONString(dst, stack)
+    }
+    if len(fields) > 0 {
+        dst = append(dst, `,"fields":`...)
+        dst = appendJSONMap(dst, fields)
+    }
+    return append(dst, '}')
+}
@@ -0,0 +1,113 @@
+//	The MIT License (MIT)
+//
+//	Copyright (c) 2016, Cagatay Dogan
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//		The above copyright notice and this permission notice shall be included in
+//		all copies or substantial portions of the Software.
+//
+//		THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//		IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//		FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//		AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//		LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//		OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+//		THE SOFTWARE.
+
+package logmanager
+
+import (
+    "encoding/json"
+    "errors"
+    "fmt"
+    "testing"
+)
+
+type queryError struct {
+    query string
+}
+
+func (e *queryError) Error() string {
+    return "query failed"
+}
+
+func (e *queryError) ErrorFields() map[string]interface{} {
+    return map[string]interface{}{ "query": e.query }
+}
+
+func (e *queryError) StackTrace() string {
+    return "main.query()\n\tquery.go:10"
+}
+
+func TestLogEntryErrorChain(t *testing.T) {
+    fmt.Println("\nTestLogEntryErrorChain\n~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~")
+
+    cause := &queryError{ query: "select 1" }
+    err := fmt.Errorf("load user: %w", errors.Join(cause, errors.New("connection reset")))
+
+    var data struct{
+        Message string `json:"message"`
+        Error struct{
+            Kind string `json:"kind"`
+            Message string `json:"message"`
+            Causes []struct{
+                Kind string `json:"kind"`
+                Message string `json:"message"`
+            } `json:"causes"`
+            Stack string `json:"stack"`
+            Fields map[string]interface{} `json:"fields"`
+        } `json:"error"`
+    }
+    if e := json.Unmarshal(NewErrorLogEntry(err, nil).ToJSON(), &data); e != nil {
+        t.Fatal(e)
+    }
+
+    if data.Error.Kind != "*fmt.wrapError" || data.Error.Message != err.Error() || data.Message != err.Error() {
+        t.Errorf("unexpected error %+v", data.Error)
+    }
+
+    kinds := []string{ "*errors.joinError", "*logmanager.queryError", "*errors.errorString" }
+    if len(data.Error.Causes) != len(kinds) {
+        t.Fatalf("expected %d causes, got %+v", len(kinds), data.Error.Causes)
+    }
+    for i, kind := range kinds {
+        if data.Error.Causes[i].Kind != kind {
+            t.Errorf("expected cause %d to be %s, got %s", i, kind, data.Error.Causes[i].Kind)
+        }
+    }
+
+    if data.Error.Stack != cause.StackTrace() {
+        t.Errorf("expected the stack of the cause, got %q", data.Error.Stack)
+    }
+    if data.Error.Fields["query"] != "select 1" {
+        t.Errorf("expected the fields of the cause, got %v", data.Error.Fields)
+    }
+}
+
+func TestParseJSONError(t *testing.T) {
+    cause := &queryError{ query: "select 1" }
+    err := fmt.Errorf("load user: %w", errors.Join(cause, errors.New("connection reset")))
+    json := NewErrorLogEntry(err, map[string]interface{}{ "user": "alice" }).ToJSON()
+
+    parsed, e := ParseJSON(json)
+    if e != nil {
+        t.Fatal(e)
+    }
+    logged, ok := parsed.Err().(*LoggedError)
+    if !ok || logged.Kind != "*fmt.wrapError" || logged.Error() != err.Error() || len(logged.Causes) != 3 {
+        t.Fatalf("unexpected error %+v", parsed.Err())
+    }
+    if logged.StackTrace() != cause.StackTrace() || logged.ErrorFields()["query"] != "select 1" {
+        t.Errorf("expected the stack and the fields of the cause, got %+v", logged)
+    }
+
+    if reencoded := parsed.ToJSON(); string(reencoded) != string(json) {
+        t.Errorf("expected the entry to round trip\n%s\n%s", json, reencoded)
+    }
+}
@@ -0,0 +1,402 @@
+//	The MIT License (MIT)
+//
+//	Copyright (c) 2016, Cagatay Dogan
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//		The above copyright notice and this permission notice shall be included in
+//		all copies or substantial portions of the Software.
+//
+//		THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//		IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//		FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//		AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//		LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//		OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+//		THE SOFTWARE.
+
+package logmanager
+
+import (
+    "encoding/json"
+    "fmt"
+    "os"
+    "sort"
+    "strconv"
+    "strings"
+    "sync/atomic"
+)
+
+var (
+    messageCatalog atomic.Value
+    eventLocale atomic.Value
+)
+
+func init() {
+    messageCatalog.Store((*MessageCatalog)(nil))
+    eventLocale.Store("")
+}
+
+// EventDefinition defines an event of the message catalog. The message templates 
+// reference the args of the entry by name like "Connection to {host} failed", 
+// the literal braces are written as {{ and }}.
+type EventDefinition struct {
+    // ID is the stable code of the event, it can not be 0
+    ID uint32
+    // Category groups the events, like "database" or "security"
+    Category string
+    // Level is the level the event is logged with, info if 0
+    Level LogLevel
+    // Messages are the message templates per locale like "en" or "tr-TR"
+    Messages map[string]string
+}
+
+type templatePart struct {
+    text string
+    arg bool
+}
+
+type messageTemplate []templatePart
+
+type catalogEvent struct {
+    definition EventDefinition
+    templates map[string]messageTemplate
+}
+
+// MessageCatalog maps the event codes to the localized message templates, 
+// the messages of the event entries are rendered from their args when the entries are formatted
+type MessageCatalog struct {
+    defaultLocale string
+    events map[uint32]*catalogEvent
+}
+
+// NewMessageCatalog creates a catalog of the events after validating them. Every event should have 
+// a template for the default locale and the templates of the other locales should reference the same args.
+func NewMessageCatalog(defaultLocale string, events ...EventDefinition) (*MessageCatalog, error) {
+    if defaultLocale == "" {
+        return nil, fmt.Errorf("logmanager: message catalog without default locale")
+    }
+    
+    catalog := &MessageCatalog{
+        defaultLocale: defaultLocale,
+        events: make(map[uint32]*catalogEvent, len(events)),
+    }
+    for _, definition := range events {
+        if err := catalog.add(definition); err != nil {
+            return nil, err
+        }
+    }
+    return catalog, nil
+}
+
+// ParseMessageCatalog parses a JSON catalog like
+//
+//    {"locale": "en", "events": [{"id": 1001, "category": "database", "level": "error", 
+//        "messages": {"en": "Connection to {host} failed", "tr": "{host} bağlantısı kurulamadı"}}]}
+func ParseMessageCatalog(data []byte) (*MessageCatalog, error) {
+    var file struct{
+        Locale string `json:"locale"`
+        Events []struct{
+            ID uint32 `json:"id"`
+            Category string `json:"category"`
+            Level string `json:"level"`
+            Messages map[string]string `json:"messages"`
+        } `json:"events"`
+    }
+    if err := json.Unmarshal(data, &file); err != nil {
+        return nil, fmt.Errorf("logmanager: invalid message catalog: %v", err)
+    }
+    
+    events := make([]EventDefinition, len(file.Events))
+    for i, e := range file.Events {
+        events[i] = EventDefinition{
+            ID: e.ID,
+            Category: e.Category,
+            Messages: e.Messages,
+        }
+        if e.Level != "" {
+            level, err := ParseLogLevel(e.Level)
+            if err != nil {
+                return nil, fmt.Errorf("logmanager: event %d: %v", e.ID, err)
+            }
+            events[i].Level = level
+        }
+    }
+    return NewMessageCatalog(file.Locale, events...)
+}
+
+// LoadMessageCatalog reads the JSON catalog file, see ParseMessageCatalog for the format
+func LoadMessageCatalog(path string) (*MessageCatalog, error) {
+    data, err := os.ReadFile(path)
+    if err != nil {
+        return nil, err
+    }
+    return ParseMessageCatalog(data)
+}
+
+func (catalog *MessageCatalog) add(definition EventDefinition) error {
+    if definition.ID == 0 {
+        return fmt.Errorf("logmanager: event without id")
+    }
+    if _, ok := catalog.events[definition.ID]; ok {
+        return fmt.Errorf("logmanager: duplicate event %d", definition.ID)
+    }
+    switch definition.Level {
+    case LogLevel(0):
+        definition.Level = LevelInfo
+    case LevelInfo, LevelWarning, LevelError, LevelFatal:
+    default:
+        return fmt.Errorf("logmanager: event %d: level %s is not a single level", definition.ID, definition.Level)
+    }
+    if _, ok := definition.Messages[catalog.defaultLocale]; !ok {
+        return fmt.Errorf("logmanager: event %d: no message for the default locale %q", definition.ID, catalog.defaultLocale)
+    }
+    
+    event := &catalogEvent{
+        definition: definition,
+        templates: make(map[string]messageTemplate, len(definition.Messages)),
+    }
+    for locale, message := range definition.Messages {
+        template, err := parseMessageTemplate(message)
+        if err != nil {
+            return fmt.Errorf("logmanager: event %d: %s message: %v", definition.ID, locale, err)
+        }
+        event.templates[locale] = template
+    }
+    
+    // the translations should not lose or invent args
+    expected := event.templates[catalog.defaultLocale].args()
+    for locale, template := range event.templates {
+        if args := template.args(); args != expected {
+            return fmt.Errorf("logmanager: event %d: %s message uses args [%s], the %s message uses [%s]", 
+                definition.ID, locale, args, catalog.defaultLocale, expected)
+        }
+    }
+    
+    catalog.events[definition.ID] = event
+    return nil
+}
+
+// DefaultLocale returns the locale used when the event has no message for the requested locale
+func (catalog *MessageCatalog) DefaultLocale() string {
+    return catalog.defaultLocale
+}
+
+// Event returns the definition of the event with the given code
+func (catalog *MessageCatalog) Event(id uint32) (EventDefinition, bool) {
+    if catalog != nil {
+        if event, ok := catalog.events[id]; ok {
+            return event.definition, true
+        }
+    }
+    return EventDefinition{}, false
+}
+
+// Render renders the message of the event for the locale from the args. The message of the language 
+// is used if there is no message for the locale, like "tr" for "tr-TR", and then the message 
+// of the default locale. The args which are not given are rendered as the placeholders.
+func (catalog *MessageCatalog) Render(id uint32, locale string, args map[string]interface{}) (string, bool) {
+    if catalog == nil {
+        return "", false
+    }
+    event, ok := catalog.events[id]
+    if !ok {
+        return "", false
+    }
+    
+    template, ok := event.templates[locale]
+    if !ok {
+        if i := strings.IndexAny(locale, "-_"); i > 0 {
+            template, ok = event.templates[locale[:i]]
+        }
+        if !ok {
+            template = event.templates[catalog.defaultLocale]
+        }
+    }
+    return template.render(args), true
+}
+
+// parseMessageTemplate splits the template into the literal texts and the arg names
+func parseMessageTemplate(message string) (messageTemplate, error) {
+    var result messageTemplate
+    var text []byte
+    
+    for i := 0; i < len(message); i++ {
+        c := message[i]
+        switch {
+        case c == '{' && i+1 < len(message) && message[i+1] == '{',
+            c == '}' && i+1 < len(message) && message[i+1] == '}':
+            text = append(text, c)
+            i++
+        case c == '{':
+            end := strings.IndexByte(message[i+1:], '}')
+            if end < 0 {
+                return nil, fmt.Errorf("unterminated placeholder at %d", i)
+            }
+            name := strings.TrimSpace(message[i+1:i+1+end])
+            if name == "" || strings.ContainsAny(name, "{") {
+                return nil, fmt.Errorf("invalid placeholder at %d", i)
+            }
+            if len(text) > 0 {
+                result = append(result, templatePart{ text: string(text) })
+                text = text[:0]
+            }
+            result = append(result, templatePart{ text: name, arg: true })
+            i += end + 1
+        case c == '}':
+            return nil, fmt.Errorf("unexpected } at %d", i)
+        default:
+            text = append(text, c)
+        }
+    }
+    if len(text) > 0 {
+        result = append(result, templatePart{ text: string(text) })
+    }
+    return result, nil
+}
+
+// args returns the sorted distinct arg names of the template joined with commas
+func (template messageTemplate) args() string {
+    var names []string
+    for _, part := range template {
+        if part.arg {
+            names = append(names, part.text)
+        }
+    }
+    sort.Strings(names)
+    
+    distinct := names[:0]
+    for i, name := range names {
+        if i == 0 || name != names[i-1] {
+            distinct = append(distinct, name)
+        }
+    }
+    return strings.Join(distinct, ", ")
+}
+
+func (template messageTemplate) render(args map[string]interface{}) string {
+    var b strings.Builder
+    for _, part := range template {
+        if !part.arg {
+            b.WriteString(part.text)
+            continue
+        }
+        
+        value, ok := args[part.text]
+        if lazy, isLazy := value.(LazyValue); isLazy {
+            value = lazy.value()
+        }
+        switch v := value.(type) {
+        case string:
+            b.WriteString(v)
+        case error:
+            b.WriteString(v.Error())
+        default:
+            if ok {
+                fmt.Fprint(&b, v)
+            } else {
+                b.WriteString("{" + part.text + "}")
+            }
+        }
+    }
+    return b.String()
+}
+
+// SetMessageCatalog sets the catalog the messages of the event entries are rendered with, 
+// nil removes the catalog
+func SetMessageCatalog(catalog *MessageCatalog) {
+    messageCatalog.Store(catalog)
+}
+
+// CurrentMessageCatalog returns the catalog the messages of the event entries are rendered with
+func CurrentMessageCatalog() *MessageCatalog {
+    return messageCatalog.Load().(*MessageCatalog)
+}
+
+// SetEventLocale sets the locale the event messages are rendered in, 
+// the default locale of the catalog is used if empty
+func SetEventLocale(locale string) {
+    eventLocale.Store(locale)
+}
+
+// EventLocale returns the locale the event messages are rendered in
+func EventLocale() string {
+    return eventLocale.Load().(string)
+}
+
+// EventID returns the event code of the entry, 0 if the entry is not an event
+func (entry *LogEntry) EventID() uint32 {
+    return entry.eventID
+}
+
+// EventCategory returns the event category of the entry which is not the logger category
+func (entry *LogEntry) EventCategory() string {
+    return entry.eventCategory
+}
+
+// SetEvent sets the event code and the event category of the entry
+func (entry *LogEntry) SetEvent(id uint32, category string) {
+    entry.eventID = id
+    entry.eventCategory = category
+}
+
+// renderEvent sets the message of the event entry rendered from the catalog, 
+// the event code is used as the message if there is no such event in the catalog
+func (entry *LogEntry) renderEvent() {
+    if message, ok := CurrentMessageCatalog().Render(entry.eventID, EventLocale(), entry.args); ok {
+        entry.message = message
+    } else {
+        entry.message = "event " + strconv.FormatUint(uint64(entry.eventID), 10)
+    }
+}
+
+// eventDefinition returns the definition of the event in the catalog, 
+// an info event without a category if there is no such event
+func eventDefinition(id uint32) EventDefinition {
+    definition, ok := CurrentMessageCatalog().Event(id)
+    if !ok {
+        definition = EventDefinition{ ID: id, Level: LevelInfo }
+    }
+    return definition
+}
+
+// entry creates the entry of the event, the message is rendered when the entry is formatted
+func (definition EventDefinition) entry(args map[string]interface{}, pooled bool) *LogEntry {
+    var entry *LogEntry
+    if pooled {
+        entry = acquireLogEntry(definition.Level, "", args)
+    } else {
+        entry = newLogEntry(definition.Level, "", args)
+    }
+    entry.SetEvent(definition.ID, definition.Category)
+    return entry
+}
+
+// NewEventLogEntry creates the entry of the event with the given code using the level and 
+// the category defined in the message catalog, the message is rendered when the entry is formatted
+func NewEventLogEntry(id uint32, args map[string]interface{}) *LogEntry {
+    return eventDefinition(id).entry(args, false)
+}
+
+// LogEvent is used to log the event with the given code using the level, the category 
+// and the message template defined in the message catalog
+func LogEvent(id uint32, args map[string]interface{}) {
+    definition := eventDefinition(id)
+    if IsEnabledFor(definition.Level) {
+        Log(definition.entry(args, true))
+    }
+}
+
+// LogEvent is used to log the event with the given code using the level, the category 
+// and the message template defined in the message catalog
+func (logger *Logger) LogEvent(id uint32, args map[string]interface{}) {
+    definition := eventDefinition(id)
+    if logger.Enabled(definition.Level) {
+        logger.log(definition.entry(args, true))
+    }
+}
@@ -0,0 +1,135 @@
+//	The MIT License (MIT)
+//
+//	Copyright (c) 2016, Cagatay Dogan
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//		The above copyright notice and this permission notice shall be included in
+//		all copies or substantial portions of the Software.
+//
+//		THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//		IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//		FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//		AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//		LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//		OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+//		THE SOFTWARE.
+
+package logmanager
+
+import (
+    "fmt"
+    "path/filepath"
+    "strings"
+    "testing"
+)
+
+func TestMessageCatalog(t *testing.T) {
+    fmt.Println("\nTestMessageCatalog\n~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~")
+
+    catalog, err := LoadMessageCatalog(filepath.Join("testdata", "catalog.json"))
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    definition, ok := catalog.Event(1001)
+    if !ok || definition.Category != "database" || definition.Level != LevelError {
+        t.Errorf("unexpected definition %+v", definition)
+    }
+
+    args := map[string]interface{}{ "host": "db1", "attempts": 3 }
+    for locale, expected := range map[string]string{
+        "": "Connection to db1 failed after 3 attempts",
+        "tr-TR": "db1 bağlantısı 3 denemeden sonra kurulamadı",
+        "de": "Connection to db1 failed after 3 attempts",
+    } {
+        if message, _ := catalog.Render(1001, locale, args); message != expected {
+            t.Errorf("expected %q for %q, got %q", expected, locale, message)
+        }
+    }
+    if message, _ := catalog.Render(2001, "en", nil); message != "User {user} is locked {policy}" {
+        t.Errorf("unexpected message %q", message)
+    }
+    if _, ok = catalog.Render(9999, "en", nil); ok {
+        t.Error("expected no message for an unknown event")
+    }
+
+    for _, invalid := range []string{
+        `{"events": [{"id": 1, "messages": {"en": "m"}}]}`,
+        `{"locale": "en", "events": [{"id": 0, "messages": {"en": "m"}}]}`,
+        `{"locale": "en", "events": [{"id": 1, "messages": {"en": "m"}}, {"id": 1, "messages": {"en": "m"}}]}`,
+        `{"locale": "en", "events": [{"id": 1, "messages": {"tr": "m"}}]}`,
+        `{"locale": "en", "events": [{"id": 1, "level": "error|fatal", "messages": {"en": "m"}}]}`,
+        `{"locale": "en", "events": [{"id": 1, "messages": {"en": "{host"}}]}`,
+        `{"locale": "en", "events": [{"id": 1, "messages": {"en": "host}"}}]}`,
+        `{"locale": "en", "events": [{"id": 1, "messages": {"en": "{}"}}]}`,
+        `{"locale": "en", "events": [{"id": 1, "messages": {"en": "{host}", "tr": "{hots}"}}]}`,
+        `{"locale": "en", "events": [`,
+    } {
+        if _, err := ParseMessageCatalog([]byte(invalid)); err == nil {
+            t.Errorf("expected an error for %s", invalid)
+        }
+    }
+}
+
+func TestLogEvent(t *testing.T) {
+    catalog, err := LoadMessageCatalog(filepath.Join("testdata", "catalog.json"))
+    if err != nil {
+        t.Fatal(err)
+    }
+    SetMessageCatalog(catalog)
+    defer SetMessageCatalog(nil)
+
+    handler := &captureLogHandler{ discardLogHandler: discardLogHandler{ name: "events", format: JSONFormat } }
+    RegisterHandler(handler)
+    text := &captureLogHandler{ discardLogHandler: discardLogHandler{ name: "events-text", format: TextFormat } }
+    RegisterHandler(text)
+
+    GetLogger("events.db").LogEvent(1001, map[string]interface{}{
+        "host": "db1", 
+        "attempts": LazyValue(func() interface{} { return 5 }),
+    })
+
+    UnregisterHandler("events")
+    UnregisterHandler("events-text")
+
+    lines := handler.captured()
+    if len(lines) != 1 {
+        t.Fatalf("expected 1 entry, got %q", lines)
+    }
+    for _, expected := range []string{
+        `"level":"error","category":"events.db","event_id":1001,"event_category":"database"`,
+        `"message":"Connection to db1 failed after 5 attempts"`,
+    } {
+        if !strings.Contains(lines[0], expected) {
+            t.Errorf("expected %s in %s", expected, lines[0])
+        }
+    }
+    entry, err := ParseJSON([]byte(lines[0]))
+    if err != nil {
+        t.Fatal(err)
+    }
+    if entry.EventID() != 1001 || entry.EventCategory() != "database" || entry.Category() != "events.db" {
+        t.Errorf("unexpected parsed entry %d %s %s", entry.EventID(), entry.EventCategory(), entry.Category())
+    }
+
+    lines = text.captured()
+    if len(lines) != 1 || !strings.Contains(lines[0], " event_id=1001 event_category=database ") {
+        t.Fatalf("expected the event in the text entry, got %q", lines)
+    }
+    if entry, err = ParseText([]byte(lines[0])); err != nil || entry.EventID() != 1001 || entry.EventCategory() != "database" {
+        t.Errorf("unexpected parsed text entry %v %v", entry, err)
+    }
+
+    // the code is used as the message of an event not in the catalog
+    unknown := NewEventLogEntry(4242, nil)
+    unknown.resolve()
+    if unknown.Message() != "event 4242" || unknown.Level() != LevelInfo {
+        t.Errorf("unexpected unknown event %s %s", unknown.Message(), unknown.Level())
+    }
+}
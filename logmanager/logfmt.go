@@ -0,0 +1,359 @@
+//	The MIT License (MIT)
+//
+//	Copyright (c) 2016, Cagatay Dogan
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//		The above copyright notice and this permission notice shall be included in
+//		all copies or substantial portions of the Software.
+//
+//		THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//		IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//		FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//		AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//		LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//		OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+//		THE SOFTWARE.
+
+package logmanager
+
+import (
+    "encoding/json"
+    "fmt"
+    "sort"
+    "strconv"
+    "strings"
+    "time"
+    "unicode"
+    "unicode/utf8"
+)
+
+// textArgsPrefix is written before the arg keys which collide with the entry fields in the text format
+const textArgsPrefix = "args."
+
+var (
+    textReservedFields = map[string]bool{
+        "args": true,
+        "category": true,
+        "duration": true,
+        "event_category": true,
+        "event_id": true,
+        "id": true,
+        "level": true,
+        "message": true,
+        "span_id": true,
+        "stack": true,
+        "time": true,
+        "trace_flags": true,
+        "trace_id": true,
+    }
+)
+
+// appendLogfmtSep separates the key value pairs written after the start position
+func appendLogfmtSep(dst []byte, start int) []byte {
+    if len(dst) > start {
+        dst = append(dst, ' ')
+    }
+    return dst
+}
+
+// logfmtNeedsQuote returns if s cannot be written as a bare logfmt key or value
+func logfmtNeedsQuote(s string) bool {
+    if s == "" {
+        return true
+    }
+    for _, r := range s {
+        if r <= ' ' || r == '=' || r == '"' || r == utf8.RuneError || !unicode.IsPrint(r) {
+            return true
+        }
+    }
+    return false
+}
+
+func logfmtNeedsQuoteBytes(b []byte) bool {
+    if len(b) == 0 {
+        return true
+    }
+    for i := 0; i < len(b); {
+        r, size := utf8.DecodeRune(b[i:])
+        if r <= ' ' || r == '=' || r == '"' || r == utf8.RuneError || !unicode.IsPrint(r) {
+            return true
+        }
+        i += size
+    }
+    return false
+}
+
+func appendLogfmtKey(dst []byte, key []byte) []byte {
+    if logfmtNeedsQuoteBytes(key) {
+        return strconv.AppendQuote(dst, string(key))
+    }
+    return append(dst, key...)
+}
+
+func appendLogfmtString(dst []byte, s string) []byte {
+    if logfmtNeedsQuote(s) {
+        return strconv.AppendQuote(dst, s)
+    }
+    return append(dst, s...)
+}
+
+// appendLogfmtPair appends a single key=value pair
+func appendLogfmtPair(dst []byte, start int, key string, value string) []byte {
+    dst = appendLogfmtSep(dst, start)
+    dst = appendLogfmtString(dst, key)
+    dst = append(dst, '=')
+    return appendLogfmtString(dst, value)
+}
+
+// appendLogfmtArg appends the value with the given key, maps and slices
+// are flattened into dotted keys like parent.child and list.0
+func appendLogfmtArg(dst []byte, start int, key []byte, value interface{}) []byte {
+    switch v := value.(type) {
+    case map[string]interface{}:
+        keys := acquireKeys()
+        for k := range v {
+            *keys = append(*keys, k)
+        }
+        sort.Strings(*keys)
+        for _, k := range *keys {
+            dst = appendLogfmtArg(dst, start, appendLogfmtSubKey(key, k), v[k])
+        }
+        releaseKeys(keys)
+        return dst
+    case map[string]string:
+        keys := acquireKeys()
+        for k := range v {
+            *keys = append(*keys, k)
+        }
+        sort.Strings(*keys)
+        for _, k := range *keys {
+            dst = appendLogfmtArg(dst, start, appendLogfmtSubKey(key, k), v[k])
+        }
+        releaseKeys(keys)
+        return dst
+    case []interface{}:
+        for i, item := range v {
+            dst = appendLogfmtArg(dst, start, strconv.AppendInt(append(key, '.'), int64(i), 10), item)
+        }
+        return dst
+    case []string:
+        for i, item := range v {
+            dst = appendLogfmtArg(dst, start, strconv.AppendInt(append(key, '.'), int64(i), 10), item)
+        }
+        return dst
+    }
+
+    dst = appendLogfmtSep(dst, start)
+    dst = appendLogfmtKey(dst, key)
+    dst = append(dst, '=')
+    return appendLogfmtValue(dst, value)
+}
+
+// AppendLogfmt appends the args to dst as logfmt pairs in sorted key order,
+// nested maps and slices are flattened into dotted keys
+func AppendLogfmt(dst []byte, args map[string]interface{}) []byte {
+    return appendLogfmtArgs(dst, len(dst), args, false)
+}
+
+// appendLogfmtArgs appends the args after the start position, the keys which collide 
+// with the entry fields or start with the args prefix are written with the args prefix if escape is set
+func appendLogfmtArgs(dst []byte, start int, args map[string]interface{}, escape bool) []byte {
+    if len(args) > 0 {
+        key := acquireBuffer()
+        keys := acquireKeys()
+        for k := range args {
+            *keys = append(*keys, k)
+        }
+        sort.Strings(*keys)
+        
+        for _, k := range *keys {
+            key.b = key.b[:0]
+            if escape && isTextReservedKey(k) {
+                key.b = append(key.b, textArgsPrefix...)
+            }
+            key.b = append(key.b, k...)
+            dst = appendLogfmtArg(dst, start, key.b, args[k])
+        }
+        
+        releaseKeys(keys)
+        key.release()
+    }
+    return dst
+}
+
+func isTextReservedKey(key string) bool {
+    return textReservedFields[key] || strings.HasPrefix(key, textArgsPrefix)
+}
+
+func appendLogfmtSubKey(key []byte, sub string) []byte {
+    if len(key) > 0 {
+        key = append(key, '.')
+    }
+    return append(key, sub...)
+}
+
+// appendLogfmtValue appends the value without reflection for the common types,
+// the values which contain spaces, quotes or control characters are quoted
+func appendLogfmtValue(dst []byte, value interface{}) []byte {
+    switch v := value.(type) {
+    case nil:
+        return dst
+    case string:
+        return appendLogfmtString(dst, v)
+    case LazyValue:
+        return appendLogfmtValue(dst, v.value())
+    case []byte:
+        return appendLogfmtString(dst, string(v))
+    case bool:
+        return strconv.AppendBool(dst, v)
+    case int:
+        return strconv.AppendInt(dst, int64(v), 10)
+    case int8:
+        return strconv.AppendInt(dst, int64(v), 10)
+    case int16:
+        return strconv.AppendInt(dst, int64(v), 10)
+    case int32:
+        return strconv.AppendInt(dst, int64(v), 10)
+    case int64:
+        return strconv.AppendInt(dst, v, 10)
+    case uint:
+        return strconv.AppendUint(dst, uint64(v), 10)
+    case uint8:
+        return strconv.AppendUint(dst, uint64(v), 10)
+    case uint16:
+        return strconv.AppendUint(dst, uint64(v), 10)
+    case uint32:
+        return strconv.AppendUint(dst, uint64(v), 10)
+    case uint64:
+        return strconv.AppendUint(dst, v, 10)
+    case float32:
+        return strconv.AppendFloat(dst, float64(v), 'g', -1, 32)
+    case float64:
+        return strconv.AppendFloat(dst, v, 'g', -1, 64)
+    case time.Time:
+        return v.AppendFormat(dst, time.RFC3339Nano)
+    case time.Duration:
+        return appendDuration(dst, v)
+    case json.Number:
+        return append(dst, v...)
+    case error:
+        return appendLogfmtString(dst, v.Error())
+    case fmt.Stringer:
+        return appendLogfmtString(dst, v.String())
+    }
+    return appendLogfmtString(dst, fmt.Sprint(value))
+}
+
+// ParseText parses a logfmt line written by LogEntry.ToText back into a log entry.
+// The id, time, duration, level, category, trace, event, message and stack keys fill the entry fields,
+// all the other keys are collected into the args with their flattened keys and string values.
+// The args prefix written before the arg keys which collide with these fields is removed.
+// A key without a value (key=) gives a nil arg, a bare key gives true.
+func ParseText(line []byte) (*LogEntry, error) {
+    entry := &LogEntry{}
+
+    var err error
+    for i := 0; i < len(line); {
+        for i < len(line) && (line[i] == ' ' || line[i] == '\t' || line[i] == '\r' || line[i] == '\n') {
+            i++
+        }
+        if i >= len(line) {
+            break
+        }
+
+        var key string
+        key, i, err = parseLogfmtToken(line, i, true)
+        if err != nil {
+            return nil, err
+        }
+        if key == "" {
+            return nil, fmt.Errorf("logmanager: empty key at %d", i)
+        }
+
+        var value interface{} = true
+        if i < len(line) && line[i] == '=' {
+            i++
+            if i >= len(line) || line[i] == ' ' {
+                value = nil
+            } else {
+                var s string
+                s, i, err = parseLogfmtToken(line, i, false)
+                if err != nil {
+                    return nil, err
+                }
+                value = s
+            }
+        }
+
+        if err = entry.setTextField(key, value); err != nil {
+            return nil, err
+        }
+    }
+    return entry, nil
+}
+
+func parseLogfmtToken(line []byte, i int, isKey bool) (string, int, error) {
+    if line[i] == '"' {
+        j := i + 1
+        for ; j < len(line); j++ {
+            if line[j] == '\\' {
+                j++
+            } else if line[j] == '"' {
+                break
+            }
+        }
+        if j >= len(line) {
+            return "", j, fmt.Errorf("logmanager: unterminated quote at %d", i)
+        }
+        s, err := strconv.Unquote(string(line[i:j+1]))
+        return s, j + 1, err
+    }
+
+    j := i
+    for j < len(line) && line[j] != ' ' && !(isKey && line[j] == '=') {
+        j++
+    }
+    return string(line[i:j]), j, nil
+}
+
+func (entry *LogEntry) setTextField(key string, value interface{}) error {
+    s, _ := value.(string)
+    var err error
+
+    switch key {
+    case "id":
+        entry.id = s
+    case "time":
+        entry.time, err = time.Parse(time.RFC3339Nano, s)
+    case "duration":
+        entry.duration, err = time.ParseDuration(s)
+    case "level":
+        entry.level, err = ParseLogLevel(s)
+    case "category":
+        entry.category = s
+    case "trace_id", "span_id", "trace_flags":
+        err = entry.setTraceField(key, s)
+    case "event_id":
+        var id uint64
+        id, err = strconv.ParseUint(s, 10, 32)
+        entry.eventID = uint32(id)
+    case "event_category":
+        entry.eventCategory = s
+    case "message":
+        entry.message = s
+    case "stack":
+        entry.stack = s
+    default:
+        if entry.args == nil {
+            entry.args = make(map[string]interface{})
+        }
+        entry.args[strings.TrimPrefix(key, textArgsPrefix)] = value
+    }
+    return err
+}
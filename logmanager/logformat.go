@@ -32,4 +32,26 @@ const (
     JSONFormat
     // CustomFormat represents that the hadler will accept the log entry as input and will format the entry by it self
     CustomFormat
-)
\ No newline at end of file
+    // GELFFormat represents that the hadler will accept the log entry as a Graylog GELF 1.1 message as input
+    GELFFormat
+    // ECSFormat represents that the hadler will accept the log entry as an Elastic Common Schema JSON document as input
+    ECSFormat
+    
+    logFormatCount
+)
+
+var (
+    formatNames = [logFormatCount]string{ "text", "json", "custom", "gelf", "ecs" }
+)
+
+func (f LogFormat) String() string {
+    if f < logFormatCount {
+        return formatNames[f]
+    }
+    return "unknown"
+}
+
+// encoded returns if the entries are passed to the handlers as formatted byte arrays 
+func (f LogFormat) encoded() bool {
+    return f != CustomFormat && f < logFormatCount
+}
\ No newline at end of file
@@ -0,0 +1,413 @@
+//	The MIT License (MIT)
+//
+//	Copyright (c) 2016, Cagatay Dogan
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//		The above copyright notice and this permission notice shall be included in
+//		all copies or substantial portions of the Software.
+//
+//		THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//		IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//		FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//		AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//		LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//		OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+//		THE SOFTWARE.
+
+package logmanager
+
+import (
+    "bytes"
+    "compress/gzip"
+    "crypto/rand"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "net"
+    "os"
+    "sort"
+    "strconv"
+    "sync"
+    "sync/atomic"
+    "time"
+)
+
+const (
+    defaultGELFChunkSize = 1420
+    minGELFChunkSize = 128
+    maxGELFChunks = 128
+    gelfChunkHeaderLen = 12
+    // gelfArgsPrefix is written before the arg names which collide with the additional fields of the entry
+    gelfArgsPrefix = "args."
+)
+
+var (
+    hostName atomic.Value
+
+    gelfReservedFields = map[string]bool{
+        "_category": true,
+        "_duration_ns": true,
+        "_entry_id": true,
+        "_event_category": true,
+        "_event_id": true,
+        "_log_level": true,
+        "_span_id": true,
+        "_trace_id": true,
+    }
+
+    // ErrGELFMessageTooLarge is returned when a GELF message needs more than 128 UDP chunks
+    ErrGELFMessageTooLarge = errors.New("logmanager: GELF message too large")
+)
+
+func init() {
+    name, _ := os.Hostname()
+    hostName.Store(name)
+}
+
+// HostName returns the host name written into the GELF and ECS formatted entries
+func HostName() string {
+    return hostName.Load().(string)
+}
+
+// SetHostName overrides the host name written into the GELF and ECS formatted entries
+func SetHostName(name string) {
+    hostName.Store(name)
+}
+
+// syslogSeverity maps the level onto the syslog severity used by GELF
+func (l LogLevel) syslogSeverity() int {
+    switch {
+    case l.Has(LevelFatal):
+        return 2
+    case l.Has(LevelError):
+        return 3
+    case l.Has(LevelWarning):
+        return 4
+    }
+    return 6
+}
+
+// AppendGELF appends the entry as a GELF 1.1 message to dst. The args are written as
+// additional fields with _ prefix, nested maps and slices are flattened into dotted names.
+// The event is written as the _event_id and _event_category fields, 
+// the trace context as the _trace_id and _span_id fields.
+// The arg names which collide with these fields are written with the "args." prefix.
+func (entry *LogEntry) AppendGELF(dst []byte) []byte {
+    dst = append(dst, `{"version":"1.1","host":`...)
+    dst = appendJSONString(dst, HostName())
+    dst = append(dst, `,"short_message":`...)
+    dst = appendJSONString(dst, entry.message)
+    if entry.stack != "" {
+        dst = append(dst, `,"full_message":`...)
+        dst = appendJSONString(dst, entry.stack)
+    }
+    dst = append(dst, `,"timestamp":`...)
+    dst = appendGELFTimestamp(dst, entry)
+    dst = append(dst, `,"level":`...)
+    dst = strconv.AppendInt(dst, int64(entry.level.syslogSeverity()), 10)
+
+    if entry.hasID() {
+        dst = append(dst, `,"_entry_id":`...)
+        dst = entry.appendJSONID(dst)
+    }
+    if entry.duration != 0 {
+        dst = append(dst, `,"_duration_ns":`...)
+        dst = strconv.AppendInt(dst, int64(entry.duration), 10)
+    }
+    dst = append(dst, `,"_log_level":`...)
+    dst = appendJSONString(dst, entry.level.String())
+    if entry.eventID != 0 {
+        dst = append(dst, `,"_event_id":`...)
+        dst = strconv.AppendUint(dst, uint64(entry.eventID), 10)
+        if entry.eventCategory != "" {
+            dst = append(dst, `,"_event_category":`...)
+            dst = appendJSONString(dst, entry.eventCategory)
+        }
+    }
+    if entry.trace.IsValid() {
+        dst = append(dst, `,"_trace_id":"`...)
+        dst = appendHex(dst, entry.trace.TraceID[:])
+        dst = append(dst, '"')
+        if entry.trace.SpanID.IsValid() {
+            dst = append(dst, `,"_span_id":"`...)
+            dst = appendHex(dst, entry.trace.SpanID[:])
+            dst = append(dst, '"')
+        }
+    }
+    if entry.category != "" {
+        dst = append(dst, `,"_category":`...)
+        dst = appendJSONString(dst, entry.category)
+    }
+
+    if len(entry.args) > 0 {
+        key := acquireBuffer()
+        key.b = append(key.b[:0], '_')
+        dst = appendGELFFields(dst, key.b, entry.args)
+        key.release()
+    }
+    return append(dst, '}')
+}
+
+func appendGELFTimestamp(dst []byte, entry *LogEntry) []byte {
+    ms := entry.time.UnixMilli()
+    dst = strconv.AppendInt(dst, ms/1000, 10)
+    frac := ms % 1000
+    if frac < 0 {
+        frac = -frac
+    }
+    dst = append(dst, '.')
+    if frac < 100 {
+        dst = append(dst, '0')
+    }
+    if frac < 10 {
+        dst = append(dst, '0')
+    }
+    return strconv.AppendInt(dst, frac, 10)
+}
+
+func appendGELFFields(dst []byte, key []byte, args map[string]interface{}) []byte {
+    keys := acquireKeys()
+    for k := range args {
+        *keys = append(*keys, k)
+    }
+    sort.Strings(*keys)
+
+    top := len(key) == 1
+    for _, k := range *keys {
+        name := appendGELFKey(key, k)
+        if top && isGELFReservedKey(name) {
+            name = appendGELFKey(append(key, gelfArgsPrefix...), k)
+        }
+        dst = appendGELFField(dst, name, args[k])
+    }
+    releaseKeys(keys)
+    return dst
+}
+
+func isGELFReservedKey(name []byte) bool {
+    return gelfReservedFields[string(name)] || bytes.HasPrefix(name[1:], []byte(gelfArgsPrefix))
+}
+
+func appendGELFField(dst []byte, key []byte, value interface{}) []byte {
+    switch v := value.(type) {
+    case map[string]interface{}:
+        return appendGELFFields(dst, append(key, '.'), v)
+    case []interface{}:
+        for i, item := range v {
+            dst = appendGELFField(dst, strconv.AppendInt(append(key, '.'), int64(i), 10), item)
+        }
+        return dst
+    case LazyValue:
+        return appendGELFField(dst, key, v.value())
+    case nil:
+        return dst
+    }
+
+    dst = append(dst, ',', '"')
+    dst = append(dst, key...)
+    dst = append(dst, '"', ':')
+
+    // GELF allows only strings and numbers as additional field values
+    switch v := value.(type) {
+    case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64, json.Number:
+        return appendJSONValue(dst, v)
+    case string:
+        return appendJSONString(dst, v)
+    case error:
+        return appendJSONString(dst, v.Error())
+    case bool, time.Time, time.Duration:
+        dst = append(dst, '"')
+        dst = appendLogfmtValue(dst, v)
+        return append(dst, '"')
+    }
+    return appendJSONString(dst, fmt.Sprint(value))
+}
+
+// appendGELFKey appends the arg name replacing the characters GELF does not allow in field names
+func appendGELFKey(key []byte, name string) []byte {
+    for i := 0; i < len(name); i++ {
+        c := name[i]
+        if (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') ||
+            c == '_' || c == '.' || c == '-' {
+            key = append(key, c)
+        } else {
+            key = append(key, '_')
+        }
+    }
+    if string(key) == "_id" {
+        // _id is reserved by Graylog
+        key = append(key, '_')
+    }
+    return key
+}
+
+// GELFUDPLogHandler sends the entries to a Graylog GELF UDP input, the messages larger than
+// the chunk size are split into GELF chunks
+type GELFUDPLogHandler struct {
+    sync.Mutex
+    disabled uint32
+    name string
+    address string
+    level LogLevel
+    chunkSize int
+    compress bool
+    conn net.Conn
+    buf bytes.Buffer
+    chunk []byte
+    err error
+}
+
+// NewGELFUDPLogHandler creates a handler which sends to the GELF UDP input at address.
+// chunkSize is the maximum datagram size, 1420 if 0, and compress enables gzip compression.
+func NewGELFUDPLogHandler(name, address string, level LogLevel, chunkSize int, compress bool) *GELFUDPLogHandler {
+    if name == "" {
+        name = "gelf"
+    }
+    if chunkSize <= 0 {
+        chunkSize = defaultGELFChunkSize
+    } else if chunkSize < minGELFChunkSize {
+        chunkSize = minGELFChunkSize
+    }
+
+    return &GELFUDPLogHandler{
+        name: name,
+        address: address,
+        level: level,
+        chunkSize: chunkSize,
+        compress: compress,
+    }
+}
+
+// Name returns the name of the handler used for registration
+func (handler *GELFUDPLogHandler) Name() string {
+    return handler.name
+}
+
+// Enabled returns if the handler is active
+func (handler *GELFUDPLogHandler) Enabled() bool {
+    return atomic.LoadUint32(&handler.disabled) == falseUint32
+}
+
+// Enable activates the handler
+func (handler *GELFUDPLogHandler) Enable() {
+    atomic.StoreUint32(&handler.disabled, falseUint32)
+}
+
+// Disable deactivates the handler
+func (handler *GELFUDPLogHandler) Disable() {
+    atomic.StoreUint32(&handler.disabled, trueUint32)
+}
+
+// Level gives if the pushed entry should be logged by the handler
+func (handler *GELFUDPLogHandler) Level() LogLevel {
+    return handler.level
+}
+
+// Format gives the format that will be used by the handler
+func (handler *GELFUDPLogHandler) Format() LogFormat {
+    return GELFFormat
+}
+
+// QueueLen gives the queue length that will be used when the entry is queued
+func (handler *GELFUDPLogHandler) QueueLen() int {
+    return -1
+}
+
+// Err returns the last error occurred while sending a message
+func (handler *GELFUDPLogHandler) Err() error {
+    handler.Lock()
+    defer handler.Unlock()
+    return handler.err
+}
+
+// Process evaluates the given entry
+func (handler *GELFUDPLogHandler) Process(entry interface{}) {
+    handler.TryProcess(entry)
+}
+
+// TryProcess evaluates the given entry and returns the error occurred
+func (handler *GELFUDPLogHandler) TryProcess(entry interface{}) error {
+    if data, ok := entry.([]byte); ok && len(data) > 0 {
+        handler.Lock()
+        defer handler.Unlock()
+
+        if err := handler.send(data); err != nil {
+            handler.err = err
+            return err
+        }
+    }
+    return nil
+}
+
+// Close closes the UDP socket
+func (handler *GELFUDPLogHandler) Close() error {
+    handler.Lock()
+    defer handler.Unlock()
+
+    if handler.conn == nil {
+        return nil
+    }
+    err := handler.conn.Close()
+    handler.conn = nil
+    return err
+}
+
+func (handler *GELFUDPLogHandler) send(data []byte) error {
+    if handler.conn == nil {
+        conn, err := net.Dial("udp", handler.address)
+        if err != nil {
+            return err
+        }
+        handler.conn = conn
+    }
+
+    if handler.compress {
+        handler.buf.Reset()
+        zw := gzip.NewWriter(&handler.buf)
+        zw.Write(data)
+        if err := zw.Close(); err != nil {
+            return err
+        }
+        data = handler.buf.Bytes()
+    }
+
+    if len(data) <= handler.chunkSize {
+        _, err := handler.conn.Write(data)
+        return err
+    }
+
+    payload := handler.chunkSize - gelfChunkHeaderLen
+    count := (len(data) + payload - 1) / payload
+    if count > maxGELFChunks {
+        return ErrGELFMessageTooLarge
+    }
+
+    var id [8]byte
+    if _, err := rand.Read(id[:]); err != nil {
+        return err
+    }
+
+    for i := 0; i < count; i++ {
+        end := (i + 1)*payload
+        if end > len(data) {
+            end = len(data)
+        }
+
+        chunk := append(handler.chunk[:0], 0x1e, 0x0f)
+        chunk = append(chunk, id[:]...)
+        chunk = append(chunk, byte(i), byte(count))
+        chunk = append(chunk, data[i*payload:end]...)
+        handler.chunk = chunk
+
+        if _, err := handler.conn.Write(chunk); err != nil {
+            return err
+        }
+    }
+    return nil
+}
@@ -0,0 +1,174 @@
+//	The MIT License (MIT)
+//
+//	Copyright (c) 2016, Cagatay Dogan
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//		The above copyright notice and this permission notice shall be included in
+//		all copies or substantial portions of the Software.
+//
+//		THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//		IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//		FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//		AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//		LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//		OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+//		THE SOFTWARE.
+
+package logmanager
+
+import (
+    "bytes"
+    "compress/gzip"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io"
+    "net"
+    "strings"
+    "testing"
+    "time"
+)
+
+func TestGELFUDPLogHandler(t *testing.T) {
+    fmt.Println("\nTestGELFUDPLogHandler\n~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~")
+
+    conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer conn.Close()
+
+    SetHostName("test-host")
+    entry := NewErrorLogEntry(errors.New("disk failure"), map[string]interface{}{
+        "id": 7,
+        "disk": map[string]interface{}{ "name": "sda", "free": 0.05 },
+        "note": strings.Repeat("x", 1000),
+    })
+
+    handler := NewGELFUDPLogHandler("gelf", conn.LocalAddr().String(), AllLogLevels, 200, true)
+    defer handler.Close()
+    handler.Process(entry.AppendGELF(nil))
+    if handler.Err() != nil {
+        t.Fatal(handler.Err())
+    }
+
+    // the compressed message is larger than a datagram, so the chunks are reassembled
+    var chunks [][]byte
+    conn.SetReadDeadline(time.Now().Add(2*time.Second))
+    for count := 1; len(chunks) < count; {
+        buf := make([]byte, 256)
+        n, _, err := conn.ReadFrom(buf)
+        if err != nil {
+            t.Fatal(err)
+        }
+        if n < gelfChunkHeaderLen || buf[0] != 0x1e || buf[1] != 0x0f {
+            t.Fatalf("expected a GELF chunk, got %q", buf[:n])
+        }
+        count = int(buf[11])
+        chunks = append(chunks, buf[:n])
+    }
+
+    message := &bytes.Buffer{}
+    for i, chunk := range chunks {
+        if int(chunk[10]) != i {
+            t.Fatalf("expected chunk %d, got %d", i, chunk[10])
+        }
+        message.Write(chunk[gelfChunkHeaderLen:])
+    }
+
+    zr, err := gzip.NewReader(message)
+    if err != nil {
+        t.Fatal(err)
+    }
+    data, err := io.ReadAll(zr)
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    var m map[string]interface{}
+    if err = json.Unmarshal(data, &m); err != nil {
+        t.Fatal(err, string(data))
+    }
+    for key, expected := range map[string]interface{}{
+        "version": "1.1",
+        "host": "test-host",
+        "short_message": "disk failure",
+        "level": float64(3),
+        "_id_": float64(7),
+        "_disk.name": "sda",
+        "_disk.free": 0.05,
+    } {
+        if m[key] != expected {
+            t.Errorf("expected %s to be %v, got %v", key, expected, m[key])
+        }
+    }
+}
+
+func TestGELFReservedArgs(t *testing.T) {
+    entry := NewInfoLogEntry("reserved", map[string]interface{}{
+        "log_level": "custom",
+        "category": "args",
+        "event_id": 5,
+        "args.note": "prefixed",
+        "db": map[string]interface{}{ "category": "nested" },
+    })
+    entry.category = "gelf"
+    entry.eventID = 7
+
+    data := entry.AppendGELF(nil)
+    var m map[string]interface{}
+    if err := json.Unmarshal(data, &m); err != nil {
+        t.Fatal(err, string(data))
+    }
+    for key, expected := range map[string]interface{}{
+        "_log_level": "info",
+        "_category": "gelf",
+        "_event_id": float64(7),
+        "_args.log_level": "custom",
+        "_args.category": "args",
+        "_args.event_id": float64(5),
+        "_args.args.note": "prefixed",
+        "_db.category": "nested",
+    } {
+        if m[key] != expected {
+            t.Errorf("expected %s to be %v, got %v", key, expected, m[key])
+        }
+    }
+    for _, key := range []string{ `"_log_level":`, `"_category":`, `"_event_id":`, `"_args.log_level":` } {
+        if n := strings.Count(string(data), key); n != 1 {
+            t.Errorf("expected %s once, got %d in %s", key, n, data)
+        }
+    }
+}
+
+func TestLogEntryECS(t *testing.T) {
+    SetHostName("test-host")
+    entry := NewErrorLogEntry(errors.New("disk failure"), map[string]interface{}{
+        "disk": "sda",
+        "message": "duplicate",
+    })
+
+    var m map[string]interface{}
+    if err := json.Unmarshal(entry.AppendECS(nil), &m); err != nil {
+        t.Fatal(err)
+    }
+
+    if m["message"] != "disk failure" || m["disk"] != "sda" {
+        t.Errorf("unexpected document %v", m)
+    }
+    if args, _ := m["args"].(map[string]interface{}); args == nil || args["message"] != "duplicate" {
+        t.Errorf("expected the colliding arg in args, got %v", m["args"])
+    }
+    if log, _ := m["log"].(map[string]interface{}); log == nil || log["level"] != "error" {
+        t.Errorf("expected log.level error, got %v", m["log"])
+    }
+    if e, _ := m["error"].(map[string]interface{}); e == nil || e["message"] != "disk failure" {
+        t.Errorf("expected an error object, got %v", m["error"])
+    }
+}
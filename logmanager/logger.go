@@ -0,0 +1,219 @@
+//	The MIT License (MIT)
+//
+//	Copyright (c) 2016, Cagatay Dogan
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//		The above copyright notice and this permission notice shall be included in
+//		all copies or substantial portions of the Software.
+//
+//		THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//		IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//		FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//		AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//		LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//		OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+//		THE SOFTWARE.
+
+package logmanager
+
+import (
+    "context"
+    "strings"
+    "sync"
+    "sync/atomic"
+)
+
+var (
+    loggerMtx = &sync.RWMutex{}
+    loggers = make(map[string]*Logger)
+    categoryLevels = make(map[string]LogLevel)
+    categoryGen = uint64(1)
+)
+
+// Logger logs the entries with its name as their category. The levels a logger 
+// accepts come from the most specific category level set for its name or 
+// one of its parents, "db.postgres" uses the level of "db" if there is no level 
+// set for "db.postgres" and the root level set for "" if there is none for "db".
+type Logger struct {
+    // cached keeps the generation of the category levels in the high bits and the level 
+    // in the low byte, so that both are loaded and stored together
+    cached uint64
+    name string
+    ctx context.Context
+}
+
+// GetLogger returns the logger with the given dot separated hierarchical name, 
+// the same logger is returned for the same name
+func GetLogger(name string) *Logger {
+    loggerMtx.RLock()
+    logger, ok := loggers[name]
+    loggerMtx.RUnlock()
+    if ok {
+        return logger
+    }
+    
+    loggerMtx.Lock()
+    defer loggerMtx.Unlock()
+    
+    if logger, ok = loggers[name]; !ok {
+        logger = &Logger{ name: name }
+        loggers[name] = logger
+    }
+    return logger
+}
+
+// SetCategoryLevel sets the levels accepted by the loggers with the given name 
+// and its sub categories without a more specific level, "" is the root category
+func SetCategoryLevel(category string, level LogLevel) {
+    loggerMtx.Lock()
+    defer loggerMtx.Unlock()
+    
+    categoryLevels[category] = level
+    atomic.AddUint64(&categoryGen, 1)
+}
+
+// ResetCategoryLevel removes the level of the category, 
+// the category uses the level of its parent afterwards
+func ResetCategoryLevel(category string) {
+    loggerMtx.Lock()
+    defer loggerMtx.Unlock()
+    
+    delete(categoryLevels, category)
+    atomic.AddUint64(&categoryGen, 1)
+}
+
+// CategoryLevels returns the category levels set
+func CategoryLevels() map[string]LogLevel {
+    loggerMtx.RLock()
+    defer loggerMtx.RUnlock()
+    
+    result := make(map[string]LogLevel, len(categoryLevels))
+    for category, level := range categoryLevels {
+        result[category] = level
+    }
+    return result
+}
+
+// CategoryLevel returns the effective levels of the given category
+func CategoryLevel(category string) LogLevel {
+    level, _ := categoryLevel(category)
+    return level
+}
+
+// categoryLevel returns the effective levels of the given category with the generation they belong to
+func categoryLevel(category string) (LogLevel, uint64) {
+    loggerMtx.RLock()
+    defer loggerMtx.RUnlock()
+    
+    return effectiveLevel(category), atomic.LoadUint64(&categoryGen)
+}
+
+// effectiveLevel walks up the category hierarchy to find the most specific level, should be called in lock
+func effectiveLevel(category string) LogLevel {
+    for {
+        if level, ok := categoryLevels[category]; ok {
+            return level
+        }
+        if category == "" {
+            return AllLogLevels
+        }
+        
+        i := strings.LastIndexByte(category, '.')
+        if i < 0 {
+            category = ""
+        } else {
+            category = category[:i]
+        }
+    }
+}
+
+// Name returns the name of the logger
+func (logger *Logger) Name() string {
+    return logger.name
+}
+
+// Level returns the effective levels of the logger
+func (logger *Logger) Level() LogLevel {
+    cached := atomic.LoadUint64(&logger.cached)
+    if cached >> 8 == atomic.LoadUint64(&categoryGen) {
+        return LogLevel(cached)
+    }
+    
+    level, gen := categoryLevel(logger.name)
+    atomic.StoreUint64(&logger.cached, gen << 8 | uint64(level))
+    return level
+}
+
+// WithContext returns a logger with the same name which correlates the entries it logs 
+// with the trace context carried by ctx, the entries which have a trace context are kept as they are
+func (logger *Logger) WithContext(ctx context.Context) *Logger {
+    return &Logger{ name: logger.name, ctx: ctx }
+}
+
+// SetLevel sets the levels of the logger category
+func (logger *Logger) SetLevel(level LogLevel) {
+    SetCategoryLevel(logger.name, level)
+}
+
+// Enabled returns if the logger accepts the given level
+func (logger *Logger) Enabled(level LogLevel) bool {
+    return IsEnabledFor(level) && logger.Level().Has(level)
+}
+
+// LogFatal is used to log the given error as fatal
+func (logger *Logger) LogFatal(e error, args map[string]interface{}) {
+    if e != nil && logger.Enabled(LevelFatal) {
+        entry := acquireLogEntry(LevelFatal, e.Error(), args)
+        entry.err = e
+        logger.log(entry)
+    }
+}
+
+// LogError is used to log the given error
+func (logger *Logger) LogError(e error, args map[string]interface{}) {
+    if e != nil && logger.Enabled(LevelError) {
+        entry := acquireLogEntry(LevelError, e.Error(), args)
+        entry.err = e
+        logger.log(entry)
+    }
+}
+
+// LogWarning is used to log the message as warning
+func (logger *Logger) LogWarning(message string, args map[string]interface{}) {
+    if logger.Enabled(LevelWarning) {
+        logger.log(acquireLogEntry(LevelWarning, message, args))
+    }
+}
+
+// LogMessage is used to log the given message
+func (logger *Logger) LogMessage(message string, args map[string]interface{}) {
+    if logger.Enabled(LevelInfo) {
+        logger.log(acquireLogEntry(LevelInfo, message, args))
+    }
+}
+
+// Log lets the given entry to be processed with the logger name as its category 
+// if the logger accepts the entry level
+func (logger *Logger) Log(entry *LogEntry) {
+    if entry != nil && !logger.Enabled(entry.level) {
+        entry.release()
+        return
+    }
+    logger.log(entry)
+}
+
+func (logger *Logger) log(entry *LogEntry) {
+    if entry != nil {
+        entry.category = logger.name
+        if logger.ctx != nil && !entry.trace.IsValid() {
+            entry.SetTraceFromContext(logger.ctx)
+        }
+    }
+    Log(entry)
+}
@@ -0,0 +1,92 @@
+//	The MIT License (MIT)
+//
+//	Copyright (c) 2016, Cagatay Dogan
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//		The above copyright notice and this permission notice shall be included in
+//		all copies or substantial portions of the Software.
+//
+//		THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//		IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//		FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//		AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//		LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//		OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+//		THE SOFTWARE.
+
+package logmanager
+
+import (
+    "fmt"
+    "sync"
+    "testing"
+)
+
+func TestLoggerLevels(t *testing.T) {
+    fmt.Println("\nTestLoggerLevels\n~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~")
+
+    pool := GetLogger("db.postgres.pool")
+    if GetLogger("db.postgres.pool") != pool || pool.Name() != "db.postgres.pool" {
+        t.Fatal("expected the same logger for the same name")
+    }
+    if pool.Level() != AllLogLevels {
+        t.Errorf("expected all levels without configuration, got %v", pool.Level())
+    }
+
+    SetCategoryLevel("", LevelError | LevelFatal)
+    SetCategoryLevel("db", LevelWarning | LevelError | LevelFatal)
+    defer ResetCategoryLevel("")
+    defer ResetCategoryLevel("db")
+
+    if pool.Level() != LevelWarning | LevelError | LevelFatal || pool.Enabled(LevelInfo) {
+        t.Errorf("expected the level of db, got %v", pool.Level())
+    }
+    if GetLogger("http").Enabled(LevelWarning) || GetLogger("dbx").Enabled(LevelWarning) {
+        t.Error("expected the root level for the other categories")
+    }
+
+    GetLogger("db.postgres").SetLevel(AllLogLevels)
+    if !pool.Enabled(LevelInfo) || GetLogger("db.mysql").Enabled(LevelInfo) {
+        t.Error("expected the level of db.postgres only for its sub categories")
+    }
+
+    ResetCategoryLevel("db.postgres")
+    if pool.Enabled(LevelInfo) {
+        t.Error("expected the level of db after reset")
+    }
+}
+
+func TestLoggerLevelConcurrent(t *testing.T) {
+    fmt.Println("\nTestLoggerLevelConcurrent\n~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~")
+
+    logger := GetLogger("concurrent.level")
+    defer ResetCategoryLevel("concurrent")
+
+    levels := []LogLevel{ LevelError, LevelWarning | LevelError, AllLogLevels }
+    for i := 0; i < 500; i++ {
+        level := levels[i % len(levels)]
+
+        wg := &sync.WaitGroup{}
+        for j := 0; j < 8; j++ {
+            wg.Add(1)
+            go func() {
+                defer wg.Done()
+                for k := 0; k < 20; k++ {
+                    logger.Level()
+                }
+            }()
+        }
+        SetCategoryLevel("concurrent", level)
+        wg.Wait()
+
+        if l := logger.Level(); l != level {
+            t.Fatalf("expected %v after the level change, got %v", level, l)
+        }
+    }
+}
@@ -22,6 +22,49 @@
 
 package logmanager
 
+import (
+    "fmt"
+)
+
+// OverflowPolicy decides which entry is dropped when the queue of a handler is full
+type OverflowPolicy int
+
+const (
+    // OverflowEvict evicts the oldest entry of the least severe level which is not more severe 
+    // than the new entry, the new entry is dropped if there is no such entry
+    OverflowEvict OverflowPolicy = iota
+    // OverflowDropNewest keeps the queued entries and drops the new entry
+    OverflowDropNewest
+)
+
+// HandlerOptions overrides the settings of a handler for a single registration, 
+// so the same handler type can be registered with different behaviour under different names
+type HandlerOptions struct {
+    // Level overrides the level of the handler if not 0
+    Level LogLevel
+    // Format overrides the format of the handler if OverrideFormat is true,
+    // the handler should accept the entries in the given format
+    Format LogFormat
+    OverrideFormat bool
+    // QueueLen overrides the queue length of the handler if not 0, 
+    // a negative length uses the system wide bucket capacity
+    QueueLen int
+    // Overflow decides which entry is dropped when the queue is full
+    Overflow OverflowPolicy
+    // Filter drops the entries it returns false for, the entries are not modified by the filter
+    Filter func(entry *LogEntry) bool
+}
+
+func (options HandlerOptions) validate() error {
+    if options.OverrideFormat && options.Format >= logFormatCount {
+        return fmt.Errorf("logmanager: unknown log format %d", options.Format)
+    }
+    if options.Overflow != OverflowEvict && options.Overflow != OverflowDropNewest {
+        return fmt.Errorf("logmanager: unknown overflow policy %d", options.Overflow)
+    }
+    return nil
+}
+
 // LogHandler is the interface which handles log writes to different destinations
 type LogHandler interface {
     // Name returns the name of the handler used for registration
@@ -38,6 +81,7 @@ type LogHandler interface {
     Format() LogFormat
     // QueueLen gives the queue length that will be used when the entry is queued
     QueueLen() int
-    // Process evaluates the given entry
+    // Process evaluates the given entry. The given byte array or *LogEntry is 
+    // recycled after Process returns, so it should be copied if it is needed later.
     Process(entry interface{})
 }
\ No newline at end of file
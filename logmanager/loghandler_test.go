@@ -0,0 +1,136 @@
+//	The MIT License (MIT)
+//
+//	Copyright (c) 2016, Cagatay Dogan
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//		The above copyright notice and this permission notice shall be included in
+//		all copies or substantial portions of the Software.
+//
+//		THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//		IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//		FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//		AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//		LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//		OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+//		THE SOFTWARE.
+
+package logmanager
+
+import (
+    "errors"
+    "fmt"
+    "strings"
+    "sync"
+    "testing"
+)
+
+type captureLogHandler struct {
+    discardLogHandler
+    sync.Mutex
+    lines []string
+}
+
+func (handler *captureLogHandler) Process(entry interface{}) {
+    handler.Lock()
+    defer handler.Unlock()
+
+    switch e := entry.(type) {
+    case []byte:
+        handler.lines = append(handler.lines, string(e))
+    case *LogEntry:
+        handler.lines = append(handler.lines, e.Message())
+    }
+}
+
+func (handler *captureLogHandler) captured() []string {
+    handler.Lock()
+    defer handler.Unlock()
+    return append([]string(nil), handler.lines...)
+}
+
+func TestRegisterHandlerWithOptions(t *testing.T) {
+    fmt.Println("\nTestRegisterHandlerWithOptions\n~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~")
+
+    // the same handler type registered twice with different behaviour
+    errorsOnly := &captureLogHandler{ discardLogHandler: discardLogHandler{ name: "capture", format: CustomFormat } }
+    database := &captureLogHandler{ discardLogHandler: discardLogHandler{ name: "capture", format: CustomFormat } }
+
+    err := RegisterHandlerWithOptions("options-errors", errorsOnly, HandlerOptions{
+        Level: LevelError,
+        Format: JSONFormat,
+        OverrideFormat: true,
+    })
+    if err != nil {
+        t.Fatal(err)
+    }
+    err = RegisterHandlerWithOptions("options-db", database, HandlerOptions{
+        Format: TextFormat,
+        OverrideFormat: true,
+        QueueLen: 16,
+        Overflow: OverflowDropNewest,
+        Filter: func(entry *LogEntry) bool {
+            return entry.Category() == "options.db"
+        },
+    })
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    for _, stats := range Stats() {
+        switch stats.Name {
+        case "options-errors":
+            if stats.Level != LevelError.String() || stats.Format != "json" {
+                t.Errorf("unexpected stats %+v", stats)
+            }
+        case "options-db":
+            if stats.Capacity != 16 || stats.Format != "text" {
+                t.Errorf("unexpected stats %+v", stats)
+            }
+        }
+    }
+
+    GetLogger("options.db").LogMessage("db message", nil)
+    GetLogger("options.web").LogMessage("web message", nil)
+    GetLogger("options.web").LogError(errors.New("web error"), nil)
+
+    // unregistering delivers the queued entries
+    UnregisterHandler("options-errors")
+    UnregisterHandler("options-db")
+
+    lines := errorsOnly.captured()
+    if len(lines) != 1 || !strings.HasPrefix(lines[0], "{") || !strings.Contains(lines[0], `"message":"web error"`) {
+        t.Errorf("expected the JSON formatted error entry only, got %q", lines)
+    }
+    lines = database.captured()
+    if len(lines) != 1 || !strings.Contains(lines[0], "category=options.db") || !strings.Contains(lines[0], `message="db message"`) {
+        t.Errorf("expected the text formatted db entry only, got %q", lines)
+    }
+
+    if RegisterHandlerWithOptions("options-invalid", errorsOnly, HandlerOptions{ Format: logFormatCount, OverrideFormat: true }) == nil {
+        t.Error("expected an error for an unknown format")
+    }
+    if RegisterHandlerWithOptions("options-invalid", errorsOnly, HandlerOptions{ Overflow: OverflowPolicy(-1) }) == nil {
+        t.Error("expected an error for an unknown overflow policy")
+    }
+}
+
+func TestQueueOverflowDropNewest(t *testing.T) {
+    q := newLogQueue(int(minBucketCap))
+    q.setOverflow(OverflowDropNewest)
+
+    for i := 0; i < 10; i++ {
+        q.push([]byte(fmt.Sprintf("entry %d", i)))
+    }
+    if q.count() != int(minBucketCap) || q.droppedCount() != 2 {
+        t.Fatalf("expected %d queued and 2 dropped entries, got %d and %d", minBucketCap, q.count(), q.droppedCount())
+    }
+    if data := q.pop().([]byte); string(data) != "entry 0" {
+        t.Errorf("expected the oldest entry to be kept, got %s", data)
+    }
+}
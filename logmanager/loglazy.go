@@ -0,0 +1,172 @@
+//	The MIT License (MIT)
+//
+//	Copyright (c) 2016, Cagatay Dogan
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//		The above copyright notice and this permission notice shall be included in
+//		all copies or substantial portions of the Software.
+//
+//		THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//		IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//		FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//		AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//		LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//		OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+//		THE SOFTWARE.
+
+package logmanager
+
+import (
+    "fmt"
+)
+
+// LazyValue is an arg value which is evaluated only if the entry is going to be processed 
+// by a handler, the value is evaluated once for all the handlers and formats
+type LazyValue func() interface{}
+
+// Infof is used to log the message formatted with the given args as information, 
+// the message is formatted only if a handler accepts info entries
+func Infof(format string, args ...interface{}) {
+    if IsEnabledFor(LevelInfo) {
+        Log(acquireFormattedLogEntry(LevelInfo, format, args))
+    }
+}
+
+// Warnf is used to log the message formatted with the given args as warning,
+// the message is formatted only if a handler accepts warning entries
+func Warnf(format string, args ...interface{}) {
+    if IsEnabledFor(LevelWarning) {
+        Log(acquireFormattedLogEntry(LevelWarning, format, args))
+    }
+}
+
+// Errorf is used to log the error created by fmt.Errorf with the given args, 
+// the error is created only if a handler accepts error entries
+func Errorf(format string, args ...interface{}) {
+    if IsEnabledFor(LevelError) {
+        Log(acquireFormattedLogEntry(LevelError, format, args))
+    }
+}
+
+// Infof is used to log the message formatted with the given args as information
+func (logger *Logger) Infof(format string, args ...interface{}) {
+    if logger.Enabled(LevelInfo) {
+        logger.log(acquireFormattedLogEntry(LevelInfo, format, args))
+    }
+}
+
+// Warnf is used to log the message formatted with the given args as warning
+func (logger *Logger) Warnf(format string, args ...interface{}) {
+    if logger.Enabled(LevelWarning) {
+        logger.log(acquireFormattedLogEntry(LevelWarning, format, args))
+    }
+}
+
+// Errorf is used to log the error created by fmt.Errorf with the given args
+func (logger *Logger) Errorf(format string, args ...interface{}) {
+    if logger.Enabled(LevelError) {
+        logger.log(acquireFormattedLogEntry(LevelError, format, args))
+    }
+}
+
+func acquireFormattedLogEntry(level LogLevel, format string, args []interface{}) *LogEntry {
+    entry := acquireLogEntry(level, "", nil)
+    entry.format = format
+    entry.formatArgs = args
+    return entry
+}
+
+// resolve formats the message, evaluates the lazy values and renders the event message once, 
+// before the entry is handed to the first bucket. The args map is copied if it has lazy values, 
+// so the map of the caller is not modified. The lazy values in the nested maps and slices are 
+// evaluated too.
+func (entry *LogEntry) resolve() {
+    if entry.resolved {
+        return
+    }
+    entry.resolved = true
+
+    if entry.format != "" || entry.formatArgs != nil {
+        args := entry.formatArgs
+        copied := false
+        for i, arg := range args {
+            if value, ok := resolveValue(arg); ok {
+                if !copied {
+                    args = append([]interface{}(nil), args...)
+                    copied = true
+                }
+                args[i] = value
+            }
+        }
+
+        if entry.level.Has(LevelError) || entry.level.Has(LevelFatal) {
+            entry.err = fmt.Errorf(entry.format, args...)
+            entry.message = entry.err.Error()
+        } else {
+            entry.message = fmt.Sprintf(entry.format, args...)
+        }
+        entry.format, entry.formatArgs = "", nil
+    }
+
+    if value, ok := resolveValue(entry.args); ok {
+        entry.args = value.(map[string]interface{})
+    }
+    
+    // the event message is rendered from the evaluated args
+    if entry.eventID != 0 && entry.message == "" {
+        entry.renderEvent()
+    }
+}
+
+// resolveValue evaluates the lazy values in the value, its maps and its slices. It returns false 
+// if there is no lazy value, otherwise the maps and the slices having lazy values are copied.
+func resolveValue(v interface{}) (interface{}, bool) {
+    switch t := v.(type) {
+    case LazyValue:
+        value, _ := resolveValue(t.value())
+        return value, true
+    case map[string]interface{}:
+        var m map[string]interface{}
+        for k, e := range t {
+            if value, ok := resolveValue(e); ok {
+                if m == nil {
+                    m = make(map[string]interface{}, len(t))
+                    for k2, e2 := range t {
+                        m[k2] = e2
+                    }
+                }
+                m[k] = value
+            }
+        }
+        if m != nil {
+            return m, true
+        }
+    case []interface{}:
+        var s []interface{}
+        for i, e := range t {
+            if value, ok := resolveValue(e); ok {
+                if s == nil {
+                    s = append([]interface{}(nil), t...)
+                }
+                s[i] = value
+            }
+        }
+        if s != nil {
+            return s, true
+        }
+    }
+    return v, false
+}
+
+func (lazy LazyValue) value() interface{} {
+    if lazy == nil {
+        return nil
+    }
+    return lazy()
+}
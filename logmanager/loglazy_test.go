@@ -0,0 +1,115 @@
+//	The MIT License (MIT)
+//
+//	Copyright (c) 2016, Cagatay Dogan
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//		The above copyright notice and this permission notice shall be included in
+//		all copies or substantial portions of the Software.
+//
+//		THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//		IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//		FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//		AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//		LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//		OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+//		THE SOFTWARE.
+
+package logmanager
+
+import (
+    "fmt"
+    "strings"
+    "sync/atomic"
+    "testing"
+    "time"
+)
+
+func TestLazyFormatting(t *testing.T) {
+    fmt.Println("\nTestLazyFormatting\n~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~")
+
+    isolateBuckets(t)
+
+    RegisterHandler(&discardLogHandler{ name: "lazy-json", format: JSONFormat })
+    RegisterHandler(&discardLogHandler{ name: "lazy-text", format: TextFormat })
+    defer UnregisterHandler("lazy-json")
+    defer UnregisterHandler("lazy-text")
+    names := []string{ "lazy-json", "lazy-text" }
+
+    var calls int32
+    lazy := LazyValue(func() interface{} {
+        atomic.AddInt32(&calls, 1)
+        return "expensive"
+    })
+
+    // no bucket accepts info entries
+    for _, name := range names {
+        SetHandlerLevel(name, LevelFatal)
+    }
+    Infof("value %v", lazy)
+
+    // the entries logged directly are not resolved for the deduplication either
+    SetDedupOptions(DedupOptions{ Window: time.Second })
+    Log(NewInfoLogEntry("value", map[string]interface{}{ "value": lazy }))
+    SetDedupOptions(DedupOptions{})
+
+    for _, name := range names {
+        SetHandlerLevel(name, 0)
+    }
+    if atomic.LoadInt32(&calls) != 0 {
+        t.Fatal("expected the lazy value not to be evaluated")
+    }
+
+    Infof("value %v", lazy)
+    if atomic.LoadInt32(&calls) != 1 {
+        t.Errorf("expected the lazy value to be evaluated once for all the formats, got %d", calls)
+    }
+
+    entry := acquireFormattedLogEntry(LevelError, "query %s: %w", []interface{}{ lazy, fmt.Errorf("timeout") })
+    entry.args = map[string]interface{}{ "result": lazy }
+    entry.resolve()
+    entry.resolve()
+    if entry.Message() != "query expensive: timeout" || entry.Err() == nil || 
+        !strings.Contains(string(entry.ToText()), "result=expensive") {
+        t.Errorf("unexpected entry %s", entry.ToText())
+    }
+    if atomic.LoadInt32(&calls) != 3 {
+        t.Errorf("expected the lazy values to be evaluated once per entry, got %d", calls)
+    }
+    entry.release()
+}
+
+func TestLazyNestedValues(t *testing.T) {
+    var calls int32
+    lazy := LazyValue(func() interface{} {
+        atomic.AddInt32(&calls, 1)
+        return "expensive"
+    })
+
+    nested := map[string]interface{}{ "value": lazy }
+    list := []interface{}{ 1, lazy }
+    entry := NewInfoLogEntry("nested", map[string]interface{}{ "nested": nested, "list": list })
+    entry.resolve()
+
+    for i := 0; i < 2; i++ {
+        if json := string(entry.ToJSON()); !strings.Contains(json, `"nested":{"value":"expensive"}`) || 
+            !strings.Contains(json, `"list":[1,"expensive"]`) {
+            t.Errorf("unexpected entry %s", json)
+        }
+        entry.ToText()
+    }
+    if atomic.LoadInt32(&calls) != 2 {
+        t.Errorf("expected the nested lazy values to be evaluated once, got %d", calls)
+    }
+    if _, ok := nested["value"].(LazyValue); !ok {
+        t.Error("expected the map of the caller not to be modified")
+    }
+    if _, ok := list[1].(LazyValue); !ok {
+        t.Error("expected the slice of the caller not to be modified")
+    }
+}
@@ -24,6 +24,8 @@ package logmanager
 
 import (
     "bytes"
+    "fmt"
+    "strings"
 )
 
 // LogLevel is used to inform the system about the given message type
@@ -44,8 +46,15 @@ const (
 
 var (
     allLogLevels = []LogLevel{ LevelInfo, LevelWarning, LevelError, LevelFatal }
+    levelNames [AllLogLevels + 1]string
 )
 
+func init() {
+    for l := range levelNames {
+        levelNames[l] = LogLevel(l).format()
+    }
+}
+
 // Has checks if lt includes lt2 
 func (l LogLevel) Has(l2 LogLevel) bool {
     return l & l2 == l2
@@ -69,6 +78,16 @@ func (l LogLevel) String() string {
     if l == LogLevel(0) {
         l = AllLogLevels
     }
+    if l <= AllLogLevels {
+        return levelNames[l]
+    }
+    return l.format()
+}
+
+func (l LogLevel) format() string {
+    if l == LogLevel(0) {
+        l = AllLogLevels
+    }
     
     buf := &bytes.Buffer{}
     for _, l2 := range allLogLevels {
@@ -80,4 +99,30 @@ func (l LogLevel) String() string {
         }
     }
     return buf.String()
+}
+
+// ParseLogLevel parses the level names written by LogLevel.String, the names can be
+// combined with | like "error|fatal", "all" or an empty string gives AllLogLevels
+func ParseLogLevel(s string) (LogLevel, error) {
+    s = strings.TrimSpace(s)
+    if s == "" || strings.EqualFold(s, "all") {
+        return AllLogLevels, nil
+    }
+    
+    var result LogLevel
+    for _, name := range strings.Split(s, "|") {
+        switch strings.ToLower(strings.TrimSpace(name)) {
+        case "info":
+            result |= LevelInfo
+        case "warning", "warn":
+            result |= LevelWarning
+        case "error":
+            result |= LevelError
+        case "fatal":
+            result |= LevelFatal
+        default:
+            return LogLevel(0), fmt.Errorf("logmanager: unknown log level %q", name)
+        }
+    }
+    return result, nil
 }
\ No newline at end of file
@@ -23,6 +23,7 @@
 package logmanager
 
 import (
+    "sort"
     "sync"
     "sync/atomic"
 )
@@ -32,16 +33,19 @@ var (
     isStacktraceEnabled = disabled
     bucketMtx = &sync.Mutex{}
     buckets = make(map[string]*logBucket)
+    enabledLevels uint32
 )
 
 // Enable enables the logging manager globally
 func Enable() {
     atomic.StoreUint32(&isEnabled, enabled)
+    UpdateEnabledLevels()
 }
 
 // Disable disables the logging manager globally
 func Disable() {
     atomic.StoreUint32(&isEnabled, disabled)
+    UpdateEnabledLevels()
 }
 
 // Enabled function is used to get if the global logging manager is enabled
@@ -49,6 +53,38 @@ func Enabled() bool {
     return atomic.LoadUint32(&isEnabled) == enabled
 }
 
+// IsEnabledFor returns if any of the given levels would be logged by an enabled handler
+// or streamed to an admin stream, so that the callers can skip building the args otherwise.
+// The answer is kept up to date by the manager functions which change the handlers, 
+// a handler which enables itself or changes its level by itself should call UpdateEnabledLevels.
+func IsEnabledFor(level LogLevel) bool {
+    return LogLevel(atomic.LoadUint32(&enabledLevels)) & level != 0
+}
+
+// UpdateEnabledLevels recomputes the levels answered by IsEnabledFor, it should be called 
+// by the handlers which are enabled, disabled or change their level without the manager functions
+func UpdateEnabledLevels() {
+    bucketMtx.Lock()
+    defer bucketMtx.Unlock()
+    refreshEnabledLevels()
+}
+
+// refreshEnabledLevels recomputes the union of the levels of the enabled buckets, should be called in lock
+func refreshEnabledLevels() {
+    var levels LogLevel
+    if Enabled() {
+        for _, bucket := range buckets {
+            if bucket.enabled() {
+                levels |= bucket.level()
+            }
+        }
+        for _, tap := range currentTaps() {
+            levels |= tap.level
+        }
+    }
+    atomic.StoreUint32(&enabledLevels, uint32(levels))
+}
+
 // EnableStacktrace enables using stacktrace in logging
 func EnableStacktrace() {
     atomic.StoreUint32(&isStacktraceEnabled, enabled)
@@ -67,15 +103,7 @@ func StacktraceEnabled() bool {
 // RegisterHandler adds the handler into the logging chain
 func RegisterHandler(handler LogHandler) {
     if handler != nil {
-        name := handler.Name()
-        
-        UnregisterHandler(name)        
-        bucketMtx.Lock()
-        defer bucketMtx.Unlock()
-        
-        bucket := newBucket(handler)
-        buckets[name] = bucket
-        go bucket.handle()
+        replaceBucket(handler.Name(), newBucket(handler, HandlerOptions{}))
     }
 }
 
@@ -86,80 +114,269 @@ func RegisterHandlerWithName(name string, handler LogHandler) {
             name = handler.Name()
         }
         
-        UnregisterHandler(name)
-        bucketMtx.Lock()
-        defer bucketMtx.Unlock()
-        
-        bucket := newBucket(handler)
-        buckets[name] = bucket
-        go bucket.handle()
+        replaceBucket(name, newBucket(handler, HandlerOptions{}))
     }
 }
 
-// UnregisterHandler removes the handler from the logging chain
+// RegisterHandlerWithOptions adds the handler into the logging chain with the given name, 
+// the options override the level, format, queue length and the overflow policy of the handler 
+// and filter the entries for this registration only
+func RegisterHandlerWithOptions(name string, handler LogHandler, options HandlerOptions) error {
+    if handler == nil {
+        return nil
+    }
+    if err := options.validate(); err != nil {
+        return err
+    }
+    if name == "" {
+        name = handler.Name()
+    }
+    
+    replaceBucket(name, newBucket(handler, options))
+    return nil
+}
+
+// RegisterHandlerWithSpool adds the handler into the logging chain with a disk backed queue,
+// the entries not processed are replayed when the handler is registered with the same 
+// spool directory again. The entries are acknowledged after the handler processes them, 
+// the handlers implementing FallibleLogHandler get the failed entries again.
+func RegisterHandlerWithSpool(handler LogHandler, options SpoolOptions) error {
+    if handler == nil {
+        return nil
+    }
+    
+    // the replaced bucket may use the same spool directory
+    name := handler.Name()
+    UnregisterHandler(name)
+    
+    spool, err := openSpool(options, !handler.Format().encoded())
+    if err != nil {
+        return err
+    }
+    
+    bucket := newBucket(handler, HandlerOptions{})
+    bucket.queue = spool
+    replaceBucket(name, bucket)
+    return nil
+}
+
+// replaceBucket starts the bucket and registers it with the given name, the bucket 
+// previously registered with the name is closed after it stops receiving entries
+func replaceBucket(name string, bucket *logBucket) {
+    bucketMtx.Lock()
+    old := buckets[name]
+    buckets[name] = bucket
+    bucket.start()
+    refreshEnabledLevels()
+    bucketMtx.Unlock()
+    
+    // closing waits for the handler which may log, so the lock is not held
+    if old != nil {
+        old.close()
+    }
+}
+
+// UnregisterHandler removes the handler from the logging chain, the entries already queued 
+// are delivered to the handler before it returns
 func UnregisterHandler(name string) {
+    bucketMtx.Lock()
+    bucket, ok := buckets[name]
+    if ok {
+        delete(buckets, name)
+        refreshEnabledLevels()
+    }
+    bucketMtx.Unlock()
+    
+    // closing waits for the handler which may log, so the lock is not held
+    if ok {
+        bucket.close()
+    }
+}
+
+// EnableHandler enables the handler registered with the given name, returns false if there is no such handler
+func EnableHandler(name string) bool {
+    bucketMtx.Lock()
+    bucket, ok := buckets[name]
+    bucketMtx.Unlock()
+    
+    // the handler is called without the lock, so that it can call UpdateEnabledLevels itself
+    if ok && bucket.handler != nil {
+        bucket.handler.Enable()
+        UpdateEnabledLevels()
+    }
+    return ok
+}
+
+// DisableHandler disables the handler registered with the given name, returns false if there is no such handler
+func DisableHandler(name string) bool {
+    bucketMtx.Lock()
+    bucket, ok := buckets[name]
+    bucketMtx.Unlock()
+    
+    // the handler is called without the lock, so that it can call UpdateEnabledLevels itself
+    if ok && bucket.handler != nil {
+        bucket.handler.Disable()
+        UpdateEnabledLevels()
+    }
+    return ok
+}
+
+// SetHandlerLevel overrides the level of the handler registered with the given name, 
+// 0 restores the level of the registration or the handler. Returns false if there is no such handler.
+func SetHandlerLevel(name string, level LogLevel) bool {
     bucketMtx.Lock()
     defer bucketMtx.Unlock()
     
     bucket, ok := buckets[name]
     if ok {
-        bucket.close()
+        bucket.setLevel(level)
+        refreshEnabledLevels()
     }
-    delete(buckets, name)
+    return ok
+}
+
+// Stats returns the state and the counters of the registered handlers ordered by name
+func Stats() []BucketStats {
+    bucketMtx.Lock()
+    registered := make(map[string]*logBucket, len(buckets))
+    for name, bucket := range buckets {
+        registered[name] = bucket
+    }
+    bucketMtx.Unlock()
+    
+    // the stats wait for the breakers, so they are collected without blocking the logging
+    result := make([]BucketStats, 0, len(registered))
+    for name, bucket := range registered {
+        result = append(result, bucket.stats(name))
+    }
+    sort.Slice(result, func(i, j int) bool {
+        return result[i].Name < result[j].Name
+    })
+    return result
 }
 
 // LogFatal is used to log the given error as fatal by log manager
 func LogFatal(e error, args map[string]interface{}) {
-    if e != nil && Enabled() {
-        Log(NewErrorLogEntry(e, args))
+    if e != nil && IsEnabledFor(LevelFatal) {
+        entry := acquireLogEntry(LevelFatal, e.Error(), args)
+        entry.err = e
+        Log(entry)
     }
 }
 
 // LogError is used to log the given error by log manager
 func LogError(e error, args map[string]interface{}) {
-    if e != nil && Enabled() {
-        Log(NewErrorLogEntry(e, args))
+    if e != nil && IsEnabledFor(LevelError) {
+        entry := acquireLogEntry(LevelError, e.Error(), args)
+        entry.err = e
+        Log(entry)
     }
 }
 
 // LogWarning is used to log the message as warning by log manager
 func LogWarning(message string, args map[string]interface{}) {
-    if Enabled() {
-        Log(NewWarningLogEntry(message, args))
+    if IsEnabledFor(LevelWarning) {
+        Log(acquireLogEntry(LevelWarning, message, args))
     }
 }
 
 // LogMessage is used to log the given message by log manager
 func LogMessage(message string, args map[string]interface{}) {
-    if Enabled() {
-        Log(NewInfoLogEntry(message, args))
+    if IsEnabledFor(LevelInfo) {
+        Log(acquireLogEntry(LevelInfo, message, args))
     }    
 }
 
-// Log lets the given entry to be processes by the handler chain
+// encodeOnce returns the entry encoded in the given format, the entry is encoded once for all the buckets
+func encodeOnce(bufs *[logFormatCount]*logBuffer, entry *LogEntry, format LogFormat) *logBuffer {
+    buf := bufs[format]
+    if buf == nil {
+        buf = acquireBuffer()
+        buf.b = entry.appendFormat(buf.b, format)
+        buf.level = entry.level
+        buf.seal()
+        bufs[format] = buf
+    }
+    return buf
+}
+
+// Log lets the given entry to be processes by the handler chain, 
+// the repeats of an entry are suppressed if deduplication is enabled
 func Log(entry *LogEntry) {
+    dispatch(entry, true)
+}
+
+type bucketTarget struct {
+    name string
+    bucket *logBucket
+}
+
+// dispatch hands the entry to the taps and the buckets accepting it, the repeated entries 
+// are suppressed if dedup is set
+func dispatch(entry *LogEntry, dedup bool) {
+    defer entry.release()
+    
     if entry != nil && Enabled() {
-        bucketMtx.Lock()
-        defer bucketMtx.Unlock()
+        // the buckets are enqueued outside the lock since a spool may write to the disk
+        var targets [8]bucketTarget
+        list := targets[:0]
         
-        var jsonData, textData []byte
-        for _, bucket := range buckets {
+        bucketMtx.Lock()
+        for name, bucket := range buckets {
             if bucket.enabled() && bucket.level().Has(entry.level) {
-                switch bucket.format() {
-                case JSONFormat:
-                    if jsonData == nil {
-                        jsonData = entry.ToJSON()
-                    }
-                    bucket.queueChan <- jsonData
-                case TextFormat:
-                    if textData == nil {
-                        textData = entry.ToText()
-                    }
-                    bucket.queueChan <- textData
-                default:
-                    bucket.queueChan <- entry
+                list = append(list, bucketTarget{ name: name, bucket: bucket })
+            }
+        }
+        bucketMtx.Unlock()
+        
+        taps := currentTaps()
+        if len(list) == 0 && !tapped(taps, entry) {
+            return
+        }
+        
+        // the message, the lazy values and the event message are resolved once, 
+        // only if a bucket or a tap may receive the entry
+        entry.resolve()
+        if dedup && deduplicate(entry) {
+            return
+        }
+        
+        var bufs [logFormatCount]*logBuffer
+        defer func() {
+            for _, buf := range bufs {
+                if buf != nil {
+                    buf.release()
                 }
             }
+        }()
+        
+        if len(taps) > 0 {
+            publish(taps, entry, &bufs)
+        }
+        
+        var matched []bool
+        r := currentRouter()
+        if r != nil && len(list) > 0 {
+            // the rules may match the message and the args
+            matched = r.match(entry)
+        }
+        
+        for _, target := range list {
+            bucket := target.bucket
+            if (r != nil && !r.accepts(target.name, matched)) || !bucket.accepts(entry) {
+                continue
+            }
+            
+            format := bucket.format()
+            if !format.encoded() {
+                entry.retain()
+                bucket.enqueue(entry)
+                continue
+            }
+            
+            buf := encodeOnce(&bufs, entry, format)
+            buf.retain()
+            bucket.enqueue(buf)
         }
     }    
 }
@@ -25,6 +25,7 @@ package logmanager
 import (
     "fmt"
     "runtime"
+    "sync/atomic"
     "testing"
     "time"
 )
@@ -60,3 +61,122 @@ func TestLogManager(t *testing.T) {
         fmt.Printf("Mem allocated: %3.3f MB\n", float64(mem2.Alloc - mem1.Alloc)/(1024*1024))
     }
 }
+
+// isolateBuckets runs the test against an empty handler registry and restores the previous one at the end
+func isolateBuckets(t *testing.T) {
+    bucketMtx.Lock()
+    saved := buckets
+    buckets = make(map[string]*logBucket)
+    refreshEnabledLevels()
+    bucketMtx.Unlock()
+
+    t.Cleanup(func() {
+        bucketMtx.Lock()
+        left := buckets
+        buckets = saved
+        refreshEnabledLevels()
+        bucketMtx.Unlock()
+
+        for _, bucket := range left {
+            bucket.close()
+        }
+    })
+}
+
+// toggleLogHandler is enabled and changes its level by itself, telling the manager about the changes
+type toggleLogHandler struct {
+    discardLogHandler
+    disabled uint32
+    level uint32
+}
+
+func (handler *toggleLogHandler) Enabled() bool {
+    return atomic.LoadUint32(&handler.disabled) == 0
+}
+
+func (handler *toggleLogHandler) Enable() {
+    atomic.StoreUint32(&handler.disabled, 0)
+    UpdateEnabledLevels()
+}
+
+func (handler *toggleLogHandler) Disable() {
+    atomic.StoreUint32(&handler.disabled, 1)
+    UpdateEnabledLevels()
+}
+
+func (handler *toggleLogHandler) setLevel(level LogLevel) {
+    atomic.StoreUint32(&handler.level, uint32(level))
+    UpdateEnabledLevels()
+}
+
+func (handler *toggleLogHandler) Level() LogLevel {
+    return LogLevel(atomic.LoadUint32(&handler.level))
+}
+
+func TestIsEnabledFor(t *testing.T) {
+    fmt.Println("\nTestIsEnabledFor\n~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~")
+
+    isolateBuckets(t)
+
+    RegisterHandler(&discardLogHandler{ name: "enabled-test", format: JSONFormat })
+    defer UnregisterHandler("enabled-test")
+    if !IsEnabledFor(LevelInfo) {
+        t.Fatal("expected info to be enabled by the registered handler")
+    }
+
+    Disable()
+    if IsEnabledFor(LevelFatal) {
+        t.Error("expected nothing to be enabled while the manager is disabled")
+    }
+    Enable()
+
+    SetHandlerLevel("enabled-test", LevelFatal)
+    if IsEnabledFor(LevelInfo) || !IsEnabledFor(LevelFatal) {
+        t.Error("expected only fatal to be enabled by the handler level")
+    }
+
+    SetHandlerLevel("enabled-test", 0)
+    if !IsEnabledFor(LevelInfo) {
+        t.Error("expected info to be enabled again")
+    }
+}
+
+func TestIsEnabledForHandlerChanges(t *testing.T) {
+    fmt.Println("\nTestIsEnabledForHandlerChanges\n~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~")
+
+    isolateBuckets(t)
+
+    handler := &toggleLogHandler{ 
+        discardLogHandler: discardLogHandler{ name: "toggle-test", format: JSONFormat },
+        disabled: 1,
+        level: uint32(LevelError),
+    }
+    RegisterHandler(handler)
+    defer UnregisterHandler("toggle-test")
+
+    if IsEnabledFor(LevelError) {
+        t.Error("expected nothing to be enabled while the handler is disabled")
+    }
+
+    handler.Enable()
+    if !IsEnabledFor(LevelError) || IsEnabledFor(LevelInfo) {
+        t.Error("expected error to be enabled after the handler enabled itself")
+    }
+
+    // the manager does not hold its lock while calling the handler
+    if !DisableHandler("toggle-test") || IsEnabledFor(LevelError) {
+        t.Error("expected nothing to be enabled after the handler is disabled")
+    }
+    EnableHandler("toggle-test")
+
+    handler.setLevel(LevelError | LevelInfo)
+    if !IsEnabledFor(LevelInfo) {
+        t.Error("expected info to be enabled after the handler level changed")
+    }
+
+    LogMessage("enabled by the handler", nil)
+    UnregisterHandler("toggle-test")
+    if atomic.LoadInt64(&handler.processed) != 1 {
+        t.Errorf("expected the entry to be processed once, got %d", atomic.LoadInt64(&handler.processed))
+    }
+}
@@ -0,0 +1,364 @@
+//	The MIT License (MIT)
+//
+//	Copyright (c) 2016, Cagatay Dogan
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//		The above copyright notice and this permission notice shall be included in
+//		all copies or substantial portions of the Software.
+//
+//		THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//		IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//		FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//		AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//		LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//		OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+//		THE SOFTWARE.
+
+package logmanager
+
+import (
+    "net/http"
+    "sort"
+    "strconv"
+    "sync"
+    "sync/atomic"
+)
+
+const (
+    defaultMetricsNamespace = "logmanager"
+    defaultMaxMetricSeries = 1000
+    // otherSeries is the label value the loggers and operations are counted under 
+    // after the number of the series reaches the limit
+    otherSeries = "_other"
+)
+
+var (
+    // DefaultDurationBuckets are the upper bounds of the duration histogram buckets in seconds
+    DefaultDurationBuckets = []float64{ .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10 }
+)
+
+// MetricsOptions is used to create a MetricsLogHandler
+type MetricsOptions struct {
+    // Name is the name of the handler, "metrics" if empty
+    Name string
+    // Namespace prefixes the metric names, "logmanager" if empty
+    Namespace string
+    // Buckets are the upper bounds of the duration histogram buckets in seconds, 
+    // DefaultDurationBuckets if empty
+    Buckets []float64
+    // MaxSeries limits the number of the distinct loggers and operations each, 
+    // the rest are counted under "_other". 1000 if 0.
+    MaxSeries int
+}
+
+type entryMetricKey struct {
+    level LogLevel
+    logger string
+}
+
+type durationHistogram struct {
+    counts []uint64
+    sum float64
+    count uint64
+}
+
+// MetricsLogHandler counts the entries per level and logger and builds histograms 
+// of the entry durations per operation. The operation is the "operation" arg of the entry 
+// or the message of the entry if there is no such arg, only the entries with a duration are measured.
+// The metrics are served in Prometheus text exposition format by ServeHTTP.
+type MetricsLogHandler struct {
+    sync.Mutex
+    disabled uint32
+    name string
+    namespace string
+    buckets []float64
+    maxSeries int
+    loggers map[string]bool
+    entries map[entryMetricKey]uint64
+    durations map[string]*durationHistogram
+}
+
+// NewMetricsLogHandler creates a handler which extracts metrics from the entries
+func NewMetricsLogHandler(options MetricsOptions) *MetricsLogHandler {
+    if options.Name == "" {
+        options.Name = "metrics"
+    }
+    if options.Namespace == "" {
+        options.Namespace = defaultMetricsNamespace
+    }
+    if options.MaxSeries <= 0 {
+        options.MaxSeries = defaultMaxMetricSeries
+    }
+    
+    buckets := options.Buckets
+    if len(buckets) == 0 {
+        buckets = DefaultDurationBuckets
+    }
+    buckets = append([]float64(nil), buckets...)
+    sort.Float64s(buckets)
+    
+    return &MetricsLogHandler{
+        name: options.Name,
+        namespace: options.Namespace,
+        buckets: buckets,
+        maxSeries: options.MaxSeries,
+        loggers: make(map[string]bool),
+        entries: make(map[entryMetricKey]uint64),
+        durations: make(map[string]*durationHistogram),
+    }
+}
+
+// Name returns the name of the handler used for registration
+func (handler *MetricsLogHandler) Name() string {
+    return handler.name
+}
+
+// Enabled returns if the handler is active
+func (handler *MetricsLogHandler) Enabled() bool {
+    return atomic.LoadUint32(&handler.disabled) == falseUint32
+}
+
+// Enable activates the handler
+func (handler *MetricsLogHandler) Enable() {
+    atomic.StoreUint32(&handler.disabled, falseUint32)
+}
+
+// Disable deactivates the handler
+func (handler *MetricsLogHandler) Disable() {
+    atomic.StoreUint32(&handler.disabled, trueUint32)
+}
+
+// Level gives if the pushed entry should be logged by the handler
+func (handler *MetricsLogHandler) Level() LogLevel {
+    return AllLogLevels
+}
+
+// Format gives the format that will be used by the handler
+func (handler *MetricsLogHandler) Format() LogFormat {
+    return CustomFormat
+}
+
+// QueueLen gives the queue length that will be used when the entry is queued
+func (handler *MetricsLogHandler) QueueLen() int {
+    return -1
+}
+
+// Process evaluates the given entry
+func (handler *MetricsLogHandler) Process(entry interface{}) {
+    e, ok := entry.(*LogEntry)
+    if !ok || e == nil {
+        return
+    }
+    
+    handler.Lock()
+    defer handler.Unlock()
+    
+    logger := e.category
+    if !handler.loggers[logger] {
+        if len(handler.loggers) >= handler.maxSeries {
+            logger = otherSeries
+        }
+        handler.loggers[logger] = true
+    }
+    handler.entries[entryMetricKey{ level: e.level, logger: logger }]++
+    
+    if e.duration > 0 {
+        handler.observe(e.operation(), e.duration.Seconds())
+    }
+}
+
+func (handler *MetricsLogHandler) observe(operation string, seconds float64) {
+    histogram, ok := handler.durations[operation]
+    if !ok {
+        if len(handler.durations) >= handler.maxSeries {
+            operation = otherSeries
+            histogram = handler.durations[operation]
+        }
+        if histogram == nil {
+            histogram = &durationHistogram{ counts: make([]uint64, len(handler.buckets)) }
+            handler.durations[operation] = histogram
+        }
+    }
+    
+    for i, bound := range handler.buckets {
+        if seconds <= bound {
+            histogram.counts[i]++
+        }
+    }
+    histogram.sum += seconds
+    histogram.count++
+}
+
+// operation returns the "operation" arg of the entry, the message if there is no such arg
+func (entry *LogEntry) operation() string {
+    if name, ok := entry.args["operation"].(string); ok && name != "" {
+        return name
+    }
+    return entry.message
+}
+
+// ServeHTTP writes the metrics in Prometheus text exposition format
+func (handler *MetricsLogHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodGet && r.Method != http.MethodHead {
+        w.Header().Set("Allow", "GET, HEAD")
+        http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+        return
+    }
+    
+    buf := acquireBuffer()
+    defer buf.release()
+    
+    buf.b = handler.AppendPrometheus(buf.b[:0])
+    w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+    w.Header().Set("Content-Length", strconv.Itoa(len(buf.b)))
+    if r.Method == http.MethodGet {
+        w.Write(buf.b)
+    }
+}
+
+// AppendPrometheus appends the metrics to dst in Prometheus text exposition format
+func (handler *MetricsLogHandler) AppendPrometheus(dst []byte) []byte {
+    handler.Lock()
+    defer handler.Unlock()
+    
+    keys := make([]entryMetricKey, 0, len(handler.entries))
+    for key := range handler.entries {
+        keys = append(keys, key)
+    }
+    sort.Slice(keys, func(i, j int) bool {
+        if keys[i].logger != keys[j].logger {
+            return keys[i].logger < keys[j].logger
+        }
+        return keys[i].level < keys[j].level
+    })
+    
+    totals := make(map[string]uint64, len(handler.loggers))
+    errors := make(map[string]uint64, len(handler.loggers))
+    
+    name := handler.namespace + "_entries_total"
+    dst = appendMetricHeader(dst, name, "counter", "Number of the log entries by level and logger.")
+    for _, key := range keys {
+        count := handler.entries[key]
+        totals[key.logger] += count
+        if key.level.Has(LevelError) || key.level.Has(LevelFatal) {
+            errors[key.logger] += count
+        }
+        
+        dst = append(dst, name...)
+        dst = appendMetricLabel(dst, '{', "level", key.level.String())
+        dst = appendMetricLabel(dst, ',', "logger", key.logger)
+        dst = append(dst, "} "...)
+        dst = strconv.AppendUint(dst, count, 10)
+        dst = append(dst, '\n')
+    }
+    
+    loggers := make([]string, 0, len(totals))
+    for logger := range totals {
+        loggers = append(loggers, logger)
+    }
+    sort.Strings(loggers)
+    
+    name = handler.namespace + "_errors_total"
+    dst = appendMetricHeader(dst, name, "counter", "Number of the error and fatal entries by logger.")
+    for _, logger := range loggers {
+        dst = append(dst, name...)
+        dst = appendMetricLabel(dst, '{', "logger", logger)
+        dst = append(dst, "} "...)
+        dst = strconv.AppendUint(dst, errors[logger], 10)
+        dst = append(dst, '\n')
+    }
+    
+    name = handler.namespace + "_error_ratio"
+    dst = appendMetricHeader(dst, name, "gauge", "Ratio of the error and fatal entries to all the entries by logger.")
+    for _, logger := range loggers {
+        dst = append(dst, name...)
+        dst = appendMetricLabel(dst, '{', "logger", logger)
+        dst = append(dst, "} "...)
+        dst = appendMetricFloat(dst, float64(errors[logger]) / float64(totals[logger]))
+        dst = append(dst, '\n')
+    }
+    
+    operations := make([]string, 0, len(handler.durations))
+    for operation := range handler.durations {
+        operations = append(operations, operation)
+    }
+    sort.Strings(operations)
+    
+    name = handler.namespace + "_operation_duration_seconds"
+    dst = appendMetricHeader(dst, name, "histogram", "Duration of the entries by operation.")
+    for _, operation := range operations {
+        histogram := handler.durations[operation]
+        for i, bound := range handler.buckets {
+            dst = appendHistogramBucket(dst, name, operation, appendMetricFloat(nil, bound), histogram.counts[i])
+        }
+        dst = appendHistogramBucket(dst, name, operation, []byte("+Inf"), histogram.count)
+        
+        dst = append(dst, name...)
+        dst = append(dst, "_sum"...)
+        dst = appendMetricLabel(dst, '{', "operation", operation)
+        dst = append(dst, "} "...)
+        dst = appendMetricFloat(dst, histogram.sum)
+        dst = append(dst, '\n')
+        
+        dst = append(dst, name...)
+        dst = append(dst, "_count"...)
+        dst = appendMetricLabel(dst, '{', "operation", operation)
+        dst = append(dst, "} "...)
+        dst = strconv.AppendUint(dst, histogram.count, 10)
+        dst = append(dst, '\n')
+    }
+    return dst
+}
+
+func appendMetricHeader(dst []byte, name, kind, help string) []byte {
+    dst = append(dst, "# HELP "...)
+    dst = append(dst, name...)
+    dst = append(dst, ' ')
+    dst = append(dst, help...)
+    dst = append(dst, "\n# TYPE "...)
+    dst = append(dst, name...)
+    dst = append(dst, ' ')
+    dst = append(dst, kind...)
+    return append(dst, '\n')
+}
+
+func appendHistogramBucket(dst []byte, name, operation string, bound []byte, count uint64) []byte {
+    dst = append(dst, name...)
+    dst = append(dst, "_bucket"...)
+    dst = appendMetricLabel(dst, '{', "operation", operation)
+    dst = append(dst, `,le="`...)
+    dst = append(dst, bound...)
+    dst = append(dst, `"} `...)
+    dst = strconv.AppendUint(dst, count, 10)
+    return append(dst, '\n')
+}
+
+// appendMetricLabel appends the label escaping the backslashes, double quotes and new lines of the value
+func appendMetricLabel(dst []byte, sep byte, name, value string) []byte {
+    dst = append(dst, sep)
+    dst = append(dst, name...)
+    dst = append(dst, '=', '"')
+    for i := 0; i < len(value); i++ {
+        switch c := value[i]; c {
+        case '\\':
+            dst = append(dst, '\\', '\\')
+        case '"':
+            dst = append(dst, '\\', '"')
+        case '\n':
+            dst = append(dst, '\\', 'n')
+        default:
+            dst = append(dst, c)
+        }
+    }
+    return append(dst, '"')
+}
+
+func appendMetricFloat(dst []byte, value float64) []byte {
+    return strconv.AppendFloat(dst, value, 'g', -1, 64)
+}
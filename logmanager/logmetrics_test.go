@@ -0,0 +1,113 @@
+//	The MIT License (MIT)
+//
+//	Copyright (c) 2016, Cagatay Dogan
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//		The above copyright notice and this permission notice shall be included in
+//		all copies or substantial portions of the Software.
+//
+//		THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//		IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//		FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//		AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//		LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//		OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+//		THE SOFTWARE.
+
+package logmanager
+
+import (
+    "errors"
+    "fmt"
+    "io"
+    "net/http/httptest"
+    "strings"
+    "testing"
+    "time"
+)
+
+func TestMetricsLogHandler(t *testing.T) {
+    fmt.Println("\nTestMetricsLogHandler\n~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~")
+
+    handler := NewMetricsLogHandler(MetricsOptions{ Name: "metrics-test", Buckets: []float64{ 1, 0.1 } })
+    RegisterHandler(handler)
+
+    db := GetLogger("metrics.db")
+    db.LogMessage("connected", nil)
+    db.LogMessage("query", nil)
+    db.LogError(errors.New("timeout"), nil)
+    GetLogger(`metrics."web"`).LogWarning("slow", nil)
+
+    fast := NewInfoLogEntry("fetch", map[string]interface{}{ "operation": "fetch" })
+    fast.duration = 50*time.Millisecond
+    Log(fast)
+    slow := NewInfoLogEntry("fetch completed", map[string]interface{}{ "operation": "fetch" })
+    slow.duration = 500*time.Millisecond
+    Log(slow)
+
+    // unregistering delivers the queued entries
+    UnregisterHandler("metrics-test")
+
+    recorder := httptest.NewRecorder()
+    handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+    if ct := recorder.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+        t.Errorf("unexpected content type %s", ct)
+    }
+    body, _ := io.ReadAll(recorder.Body)
+
+    for _, expected := range []string{
+        "# TYPE logmanager_entries_total counter\n",
+        `logmanager_entries_total{level="info",logger="metrics.db"} 2` + "\n",
+        `logmanager_entries_total{level="error",logger="metrics.db"} 1` + "\n",
+        `logmanager_entries_total{level="warning",logger="metrics.\"web\""} 1` + "\n",
+        `logmanager_errors_total{logger="metrics.db"} 1` + "\n",
+        `logmanager_error_ratio{logger="metrics.db"} 0.3333333333333333` + "\n",
+        "# TYPE logmanager_operation_duration_seconds histogram\n",
+        `logmanager_operation_duration_seconds_bucket{operation="fetch",le="0.1"} 1` + "\n",
+        `logmanager_operation_duration_seconds_bucket{operation="fetch",le="1"} 2` + "\n",
+        `logmanager_operation_duration_seconds_bucket{operation="fetch",le="+Inf"} 2` + "\n",
+        `logmanager_operation_duration_seconds_sum{operation="fetch"} 0.55` + "\n",
+        `logmanager_operation_duration_seconds_count{operation="fetch"} 2` + "\n",
+    } {
+        if !strings.Contains(string(body), expected) {
+            t.Errorf("expected %q in\n%s", expected, body)
+        }
+    }
+
+    recorder = httptest.NewRecorder()
+    handler.ServeHTTP(recorder, httptest.NewRequest("POST", "/metrics", nil))
+    if recorder.Code != 405 {
+        t.Errorf("expected 405 for POST, got %d", recorder.Code)
+    }
+}
+
+func TestMetricsSeriesLimit(t *testing.T) {
+    handler := NewMetricsLogHandler(MetricsOptions{ Namespace: "app", MaxSeries: 2 })
+    for i := 0; i < 4; i++ {
+        entry := NewInfoLogEntry(fmt.Sprintf("operation %d", i), nil)
+        entry.SetCategory(fmt.Sprintf("logger%d", i))
+        entry.duration = time.Millisecond
+        handler.Process(entry)
+    }
+
+    text := string(handler.AppendPrometheus(nil))
+    for _, expected := range []string{
+        `app_entries_total{level="info",logger="logger1"} 1`,
+        `app_entries_total{level="info",logger="_other"} 2`,
+        `app_operation_duration_seconds_count{operation="operation 0"} 1`,
+        `app_operation_duration_seconds_count{operation="_other"} 2`,
+    } {
+        if !strings.Contains(text, expected) {
+            t.Errorf("expected %q in\n%s", expected, text)
+        }
+    }
+    if strings.Contains(text, "logger3") || strings.Contains(text, "operation 3") {
+        t.Errorf("expected the series over the limit to be folded\n%s", text)
+    }
+}
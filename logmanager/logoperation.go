@@ -0,0 +1,206 @@
+//	The MIT License (MIT)
+//
+//	Copyright (c) 2016, Cagatay Dogan
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//		The above copyright notice and this permission notice shall be included in
+//		all copies or substantial portions of the Software.
+//
+//		THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//		IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//		FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//		AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//		LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//		OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+//		THE SOFTWARE.
+
+package logmanager
+
+import (
+    "sync"
+    "sync/atomic"
+    "time"
+)
+
+const (
+    // OutcomeSuccess is the outcome of the operations ended without an error
+    OutcomeSuccess = "success"
+    // OutcomeFailure is the outcome of the operations ended with an error
+    OutcomeFailure = "failure"
+)
+
+var (
+    isOperationStartEnabled = disabled
+    slowOperationWarning int64
+    slowOperationError int64
+)
+
+// EnableOperationStart enables logging an info entry when an operation is started
+func EnableOperationStart() {
+    atomic.StoreUint32(&isOperationStartEnabled, enabled)
+}
+
+// DisableOperationStart disables logging an info entry when an operation is started
+func DisableOperationStart() {
+    atomic.StoreUint32(&isOperationStartEnabled, disabled)
+}
+
+// OperationStartEnabled function is used to get if the operations log their start
+func OperationStartEnabled() bool {
+    return atomic.LoadUint32(&isOperationStartEnabled) == enabled
+}
+
+// SetSlowOperationThresholds sets the durations after which the successful operations 
+// are logged as warning and as error, 0 disables the threshold
+func SetSlowOperationThresholds(warning, err time.Duration) {
+    atomic.StoreInt64(&slowOperationWarning, int64(warning))
+    atomic.StoreInt64(&slowOperationError, int64(err))
+}
+
+// SlowOperationThresholds returns the durations after which the successful operations
+// are logged as warning and as error
+func SlowOperationThresholds() (warning, err time.Duration) {
+    return time.Duration(atomic.LoadInt64(&slowOperationWarning)), time.Duration(atomic.LoadInt64(&slowOperationError))
+}
+
+// Operation times a unit of work and logs a single entry with the duration and the outcome
+// when it is ended. The level of the entry is error if the operation fails, otherwise 
+// it depends on the slowness thresholds.
+type Operation struct {
+    sync.Mutex
+    name string
+    start time.Time
+    fields map[string]interface{}
+    warnAfter time.Duration
+    errorAfter time.Duration
+    ended bool
+}
+
+// StartOperation starts timing the operation with the given name and fields, 
+// the operation is logged when End or Done is called
+//
+//     op := logmanager.StartOperation("db.query", map[string]interface{}{ "table": "users" })
+//     defer op.Done(&err)
+func StartOperation(name string, fields map[string]interface{}) *Operation {
+    warning, err := SlowOperationThresholds()
+    op := &Operation{
+        name: name,
+        fields: fields,
+        warnAfter: warning,
+        errorAfter: err,
+    }
+    
+    if OperationStartEnabled() && IsEnabledFor(LevelInfo) {
+        Log(newLogEntry(LevelInfo, name + " started", op.args("")))
+    }
+    
+    op.start = now()
+    return op
+}
+
+// Name returns the name of the operation
+func (op *Operation) Name() string {
+    return op.name
+}
+
+// Set adds a field which will be logged when the operation ends
+func (op *Operation) Set(key string, value interface{}) {
+    op.Lock()
+    defer op.Unlock()
+    
+    fields := make(map[string]interface{}, len(op.fields) + 1)
+    for k, v := range op.fields {
+        fields[k] = v
+    }
+    fields[key] = value
+    op.fields = fields
+}
+
+// SetThresholds overrides the global slowness thresholds for the operation, 0 disables the threshold
+func (op *Operation) SetThresholds(warning, err time.Duration) {
+    op.Lock()
+    defer op.Unlock()
+    
+    op.warnAfter = warning
+    op.errorAfter = err
+}
+
+// End stops timing the operation, logs it with the given error and returns its duration.
+// Only the first call of End or Done is logged, the entry is created only if a handler accepts its level.
+func (op *Operation) End(err error) time.Duration {
+    op.Lock()
+    if op.ended {
+        op.Unlock()
+        return 0
+    }
+    op.ended = true
+    
+    duration := now().Sub(op.start)
+    level := op.level(duration, err)
+    var entry *LogEntry
+    if IsEnabledFor(level) {
+        entry = op.entry(level, duration, err)
+    }
+    op.Unlock()
+    
+    if entry != nil {
+        Log(entry)
+    }
+    return duration
+}
+
+// Done ends the operation with the error errp points to when it is called, 
+// so that it can be deferred before the error is known
+func (op *Operation) Done(errp *error) {
+    var err error
+    if errp != nil {
+        err = *errp
+    }
+    op.End(err)
+}
+
+// level returns the level the operation is logged with
+func (op *Operation) level(duration time.Duration, err error) LogLevel {
+    switch {
+    case err != nil:
+        return LevelError
+    case op.errorAfter > 0 && duration >= op.errorAfter:
+        return LevelError
+    case op.warnAfter > 0 && duration >= op.warnAfter:
+        return LevelWarning
+    }
+    return LevelInfo
+}
+
+// entry creates the entry of the ended operation
+func (op *Operation) entry(level LogLevel, duration time.Duration, err error) *LogEntry {
+    var entry *LogEntry
+    if err != nil {
+        entry = newLogEntry(level, op.name + " failed: " + err.Error(), op.args(OutcomeFailure))
+        entry.err = err
+    } else {
+        entry = newLogEntry(level, op.name + " completed", op.args(OutcomeSuccess))
+    }
+    entry.time = op.start
+    entry.duration = duration
+    return entry
+}
+
+// args returns a copy of the operation fields with the name and the outcome of the operation
+func (op *Operation) args(outcome string) map[string]interface{} {
+    args := make(map[string]interface{}, len(op.fields) + 2)
+    for k, v := range op.fields {
+        args[k] = v
+    }
+    args["operation"] = op.name
+    if outcome != "" {
+        args["outcome"] = outcome
+    }
+    return args
+}
@@ -0,0 +1,122 @@
+//	The MIT License (MIT)
+//
+//	Copyright (c) 2016, Cagatay Dogan
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//		The above copyright notice and this permission notice shall be included in
+//		all copies or substantial portions of the Software.
+//
+//		THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//		IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//		FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//		AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//		LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//		OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+//		THE SOFTWARE.
+
+package logmanager
+
+import (
+    "errors"
+    "fmt"
+    "sync/atomic"
+    "testing"
+    "time"
+)
+
+func TestOperation(t *testing.T) {
+    fmt.Println("\nTestOperation\n~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~")
+
+    isolateBuckets(t)
+    handler := &captureLogHandler{ discardLogHandler: discardLogHandler{ name: "operation", format: JSONFormat } }
+    RegisterHandler(handler)
+    defer UnregisterHandler("operation")
+
+    current := time.Date(2016, 5, 4, 3, 2, 1, 0, time.UTC)
+    SetClock(func() time.Time { return current })
+    SetSlowOperationThresholds(100*time.Millisecond, time.Second)
+    defer SetClock(nil)
+    defer SetSlowOperationThresholds(0, 0)
+
+    logged := func(count int) *LogEntry {
+        deadline := time.Now().Add(2*time.Second)
+        lines := handler.captured()
+        for len(lines) < count && time.Now().Before(deadline) {
+            time.Sleep(10*time.Millisecond)
+            lines = handler.captured()
+        }
+        if len(lines) != count {
+            t.Fatalf("expected %d entries, got %q", count, lines)
+        }
+        entry, err := ParseJSON([]byte(lines[count - 1]))
+        if err != nil {
+            t.Fatal(err)
+        }
+        return entry
+    }
+
+    tests := []struct{
+        elapsed time.Duration
+        err error
+        level LogLevel
+        outcome string
+        message string
+    }{
+        { 10*time.Millisecond, nil, LevelInfo, OutcomeSuccess, "db.query completed" },
+        { 200*time.Millisecond, nil, LevelWarning, OutcomeSuccess, "db.query completed" },
+        { 2*time.Second, nil, LevelError, OutcomeSuccess, "db.query completed" },
+        { 10*time.Millisecond, errors.New("timeout"), LevelError, OutcomeFailure, "db.query failed: timeout" },
+    }
+
+    for i, test := range tests {
+        op := StartOperation("db.query", map[string]interface{}{ "table": "users" })
+        current = current.Add(test.elapsed)
+
+        if test.err != nil {
+            err := test.err
+            op.Done(&err)
+        } else if duration := op.End(nil); duration != test.elapsed {
+            t.Errorf("expected the duration %v, got %v", test.elapsed, duration)
+        }
+        if op.End(errors.New("again")) != 0 {
+            t.Error("expected the operation to be ended once")
+        }
+
+        entry := logged(i + 1)
+        if entry.Duration() != test.elapsed || entry.Level() != test.level || entry.Message() != test.message {
+            t.Errorf("expected %v %v %q, got %v %v %q", test.elapsed, test.level, test.message,
+                entry.Duration(), entry.Level(), entry.Message())
+        }
+        if (test.err == nil) != (entry.Err() == nil) || entry.Args()["outcome"] != test.outcome || 
+            entry.Args()["operation"] != "db.query" || entry.Args()["table"] != "users" {
+            t.Errorf("unexpected entry %v %v", entry.Err(), entry.Args())
+        }
+    }
+
+    // the entry is not created if no handler accepts its level
+    var created int32
+    SetIDGenerator(func() string {
+        atomic.AddInt32(&created, 1)
+        return "op"
+    })
+    defer SetIDGenerator(nil)
+    SetHandlerLevel("operation", LevelError)
+
+    op := StartOperation("db.query", nil)
+    current = current.Add(10*time.Millisecond)
+    if duration := op.End(nil); duration != 10*time.Millisecond || atomic.LoadInt32(&created) != 0 {
+        t.Errorf("expected the entry not to be created, got %d entries for %v", created, duration)
+    }
+
+    op = StartOperation("db.query", nil)
+    op.End(errors.New("timeout"))
+    if entry := logged(len(tests) + 1); entry.Message() != "db.query failed: timeout" || atomic.LoadInt32(&created) != 1 {
+        t.Errorf("unexpected entry %q", entry.Message())
+    }
+}
@@ -0,0 +1,558 @@
+//	The MIT License (MIT)
+//
+//	Copyright (c) 2016, Cagatay Dogan
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//		The above copyright notice and this permission notice shall be included in
+//		all copies or substantial portions of the Software.
+//
+//		THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//		IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//		FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//		AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//		LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//		OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+//		THE SOFTWARE.
+
+package logmanager
+
+import (
+    "bytes"
+    "encoding/base64"
+    "encoding/json"
+    "fmt"
+    "io"
+    "net/http"
+    "os"
+    "path/filepath"
+    "sort"
+    "strconv"
+    "strings"
+    "sync"
+    "sync/atomic"
+    "time"
+)
+
+const (
+    defaultOTLPEndpoint = "http://localhost:4318/v1/logs"
+    defaultOTLPBatchSize = 100
+    defaultOTLPFlushInterval = time.Second
+    defaultOTLPTimeout = 10*time.Second
+    // otlpArgsPrefix is written before the arg keys which collide with the attributes of the entry
+    otlpArgsPrefix = "args."
+)
+
+var (
+    otlpReservedAttributes = map[string]bool{
+        "duration_ns": true,
+        "exception.message": true,
+        "exception.stacktrace": true,
+        "exception.type": true,
+        "log.record.uid": true,
+    }
+)
+
+// otlpSeverity maps the level onto the OpenTelemetry severity number and text
+func (l LogLevel) otlpSeverity() (int, string) {
+    switch {
+    case l.Has(LevelFatal):
+        return 21, "FATAL"
+    case l.Has(LevelError):
+        return 17, "ERROR"
+    case l.Has(LevelWarning):
+        return 13, "WARN"
+    }
+    return 9, "INFO"
+}
+
+// AppendOTLP appends the entry as an OpenTelemetry log record in OTLP/JSON encoding to dst. 
+// The args become the attributes of the record, the logged error is written as the exception 
+// attributes. The arg keys which collide with these attributes are written with the "args." prefix. 
+// The category of the entry is the instrumentation scope of the record, 
+// so it is written by the handler which groups the records.
+func (entry *LogEntry) AppendOTLP(dst []byte) []byte {
+    severity, text := entry.level.otlpSeverity()
+    
+    dst = append(dst, `{"timeUnixNano":"`...)
+    dst = strconv.AppendInt(dst, entry.time.UnixNano(), 10)
+    dst = append(dst, `","severityNumber":`...)
+    dst = strconv.AppendInt(dst, int64(severity), 10)
+    dst = append(dst, `,"severityText":"`...)
+    dst = append(dst, text...)
+    dst = append(dst, `","body":{"stringValue":`...)
+    dst = appendJSONString(dst, entry.message)
+    dst = append(dst, `},"attributes":[`...)
+    
+    first := true
+    if entry.hasID() {
+        dst = append(dst, `{"key":"log.record.uid","value":{"stringValue":`...)
+        dst = entry.appendJSONID(dst)
+        dst = append(dst, `}}`...)
+        first = false
+    }
+    if entry.duration != 0 {
+        dst = appendOTLPSep(dst, &first)
+        dst = append(dst, `{"key":"duration_ns","value":{"intValue":"`...)
+        dst = strconv.AppendInt(dst, int64(entry.duration), 10)
+        dst = append(dst, `"}}`...)
+    }
+    if entry.err != nil {
+        dst = appendOTLPSep(dst, &first)
+        dst = appendOTLPAttribute(dst, "exception.type", errorKind(entry.err))
+        dst = append(dst, ',')
+        dst = appendOTLPAttribute(dst, "exception.message", entry.err.Error())
+    }
+    stack := entry.stack
+    if stack == "" && entry.err != nil {
+        stack = errorChainStack(entry.err)
+    }
+    if stack != "" {
+        dst = appendOTLPSep(dst, &first)
+        dst = appendOTLPAttribute(dst, "exception.stacktrace", stack)
+    }
+    
+    if len(entry.args) > 0 {
+        keys := acquireKeys()
+        for k := range entry.args {
+            *keys = append(*keys, k)
+        }
+        sort.Strings(*keys)
+        
+        for _, k := range *keys {
+            key := k
+            if otlpReservedAttributes[k] || strings.HasPrefix(k, otlpArgsPrefix) {
+                key = otlpArgsPrefix + k
+            }
+            dst = appendOTLPSep(dst, &first)
+            dst = appendOTLPAttribute(dst, key, entry.args[k])
+        }
+        releaseKeys(keys)
+    }
+    dst = append(dst, ']')
+    
+    if entry.trace.IsValid() {
+        dst = append(dst, `,"traceId":"`...)
+        dst = appendHex(dst, entry.trace.TraceID[:])
+        dst = append(dst, '"')
+        if entry.trace.SpanID.IsValid() {
+            dst = append(dst, `,"spanId":"`...)
+            dst = appendHex(dst, entry.trace.SpanID[:])
+            dst = append(dst, '"')
+        }
+        dst = append(dst, `,"flags":`...)
+        dst = strconv.AppendInt(dst, int64(entry.trace.Flags), 10)
+    }
+    return append(dst, '}')
+}
+
+func appendOTLPSep(dst []byte, first *bool) []byte {
+    if *first {
+        *first = false
+        return dst
+    }
+    return append(dst, ',')
+}
+
+func appendOTLPAttribute(dst []byte, key string, value interface{}) []byte {
+    dst = append(dst, `{"key":`...)
+    dst = appendJSONString(dst, key)
+    dst = append(dst, `,"value":`...)
+    dst = appendOTLPValue(dst, value)
+    return append(dst, '}')
+}
+
+// appendOTLPValue appends the value as an OTLP AnyValue, the 64 bit integers are written 
+// as strings as the protobuf JSON mapping requires
+func appendOTLPValue(dst []byte, value interface{}) []byte {
+    switch v := value.(type) {
+    case nil:
+        return append(dst, "{}"...)
+    case LazyValue:
+        return appendOTLPValue(dst, v.value())
+    case string:
+        dst = append(dst, `{"stringValue":`...)
+        dst = appendJSONString(dst, v)
+    case bool:
+        dst = append(dst, `{"boolValue":`...)
+        dst = strconv.AppendBool(dst, v)
+    case int:
+        return appendOTLPInt(dst, int64(v))
+    case int8:
+        return appendOTLPInt(dst, int64(v))
+    case int16:
+        return appendOTLPInt(dst, int64(v))
+    case int32:
+        return appendOTLPInt(dst, int64(v))
+    case int64:
+        return appendOTLPInt(dst, v)
+    case uint:
+        return appendOTLPUint(dst, uint64(v))
+    case uint8:
+        return appendOTLPUint(dst, uint64(v))
+    case uint16:
+        return appendOTLPUint(dst, uint64(v))
+    case uint32:
+        return appendOTLPUint(dst, uint64(v))
+    case uint64:
+        return appendOTLPUint(dst, v)
+    case float32:
+        dst = append(dst, `{"doubleValue":`...)
+        dst = appendJSONFloat(dst, float64(v), 32)
+    case float64:
+        dst = append(dst, `{"doubleValue":`...)
+        dst = appendJSONFloat(dst, v, 64)
+    case json.Number:
+        if i, err := v.Int64(); err == nil {
+            return appendOTLPInt(dst, i)
+        }
+        dst = append(dst, `{"doubleValue":`...)
+        dst = append(dst, v...)
+    case []byte:
+        dst = append(dst, `{"bytesValue":"`...)
+        dst = base64.StdEncoding.AppendEncode(dst, v)
+        dst = append(dst, '"')
+    case time.Time:
+        dst = append(dst, `{"stringValue":"`...)
+        dst = v.AppendFormat(dst, time.RFC3339Nano)
+        dst = append(dst, '"')
+    case error:
+        dst = append(dst, `{"stringValue":`...)
+        dst = appendJSONString(dst, v.Error())
+    case fmt.Stringer:
+        dst = append(dst, `{"stringValue":`...)
+        dst = appendJSONString(dst, v.String())
+    case map[string]interface{}:
+        keys := make([]string, 0, len(v))
+        for k := range v {
+            keys = append(keys, k)
+        }
+        sort.Strings(keys)
+        
+        dst = append(dst, `{"kvlistValue":{"values":[`...)
+        for i, k := range keys {
+            if i > 0 {
+                dst = append(dst, ',')
+            }
+            dst = appendOTLPAttribute(dst, k, v[k])
+        }
+        dst = append(dst, "]}"...)
+    case []interface{}:
+        dst = append(dst, `{"arrayValue":{"values":[`...)
+        for i, item := range v {
+            if i > 0 {
+                dst = append(dst, ',')
+            }
+            dst = appendOTLPValue(dst, item)
+        }
+        dst = append(dst, "]}"...)
+    default:
+        dst = append(dst, `{"stringValue":`...)
+        dst = appendJSONString(dst, fmt.Sprint(value))
+    }
+    return append(dst, '}')
+}
+
+func appendOTLPInt(dst []byte, i int64) []byte {
+    dst = append(dst, `{"intValue":"`...)
+    dst = strconv.AppendInt(dst, i, 10)
+    return append(dst, `"}`...)
+}
+
+func appendOTLPUint(dst []byte, i uint64) []byte {
+    dst = append(dst, `{"intValue":"`...)
+    dst = strconv.AppendUint(dst, i, 10)
+    return append(dst, `"}`...)
+}
+
+// OTLPOptions is used to create an OTLPLogHandler
+type OTLPOptions struct {
+    // Name is the name of the handler, "otlp" if empty
+    Name string
+    // Endpoint is the OTLP/HTTP logs URL of the collector, http://localhost:4318/v1/logs if empty
+    Endpoint string
+    // Headers are added to the export requests, like the authorization header of the collector
+    Headers map[string]string
+    // Resource are the attributes of the resource the logs are exported for, service.name is 
+    // the executable name and host.name is HostName() if they are not given
+    Resource map[string]string
+    // Level is the set of levels exported, all levels if 0
+    Level LogLevel
+    // BatchSize is the number of the records exported with a request, 100 if 0
+    BatchSize int
+    // FlushInterval limits the time a record waits for its batch, 1s if 0 and disabled if negative
+    FlushInterval time.Duration
+    // Timeout limits the time of an export request, 10s if 0
+    Timeout time.Duration
+    // Client is used to send the requests, a client with the timeout is used if nil
+    Client *http.Client
+}
+
+// OTLPLogHandler exports the entries to an OpenTelemetry collector using OTLP/HTTP with JSON encoding.
+// The records are exported in batches, grouped into a scope per entry category. 
+// A failed batch is dropped and its error is returned by TryProcess and Err, 
+// the error of a batch exported by the flush timer is returned by the next TryProcess.
+type OTLPLogHandler struct {
+    sync.Mutex
+    // exporting serializes the exports so that the batches are sent in order without holding the handler lock
+    exporting sync.Mutex
+    disabled uint32
+    options OTLPOptions
+    client *http.Client
+    resource []byte
+    scopes map[string][]byte
+    order []string
+    pending int
+    timer *time.Timer
+    payload bytes.Buffer
+    err error
+    unreported error
+}
+
+// NewOTLPLogHandler creates a handler which exports the entries to the OTLP/HTTP endpoint
+func NewOTLPLogHandler(options OTLPOptions) *OTLPLogHandler {
+    if options.Name == "" {
+        options.Name = "otlp"
+    }
+    if options.Endpoint == "" {
+        options.Endpoint = defaultOTLPEndpoint
+    }
+    if options.BatchSize <= 0 {
+        options.BatchSize = defaultOTLPBatchSize
+    }
+    if options.FlushInterval == 0 {
+        options.FlushInterval = defaultOTLPFlushInterval
+    }
+    if options.Timeout <= 0 {
+        options.Timeout = defaultOTLPTimeout
+    }
+    
+    client := options.Client
+    if client == nil {
+        client = &http.Client{ Timeout: options.Timeout }
+    }
+    
+    resource := map[string]string{
+        "service.name": filepath.Base(os.Args[0]),
+        "host.name": HostName(),
+    }
+    for k, v := range options.Resource {
+        resource[k] = v
+    }
+    
+    keys := make([]string, 0, len(resource))
+    for k := range resource {
+        keys = append(keys, k)
+    }
+    sort.Strings(keys)
+    
+    var attributes []byte
+    for i, k := range keys {
+        if i > 0 {
+            attributes = append(attributes, ',')
+        }
+        attributes = appendOTLPAttribute(attributes, k, resource[k])
+    }
+    
+    return &OTLPLogHandler{
+        options: options,
+        client: client,
+        resource: attributes,
+        scopes: make(map[string][]byte),
+    }
+}
+
+// Name returns the name of the handler used for registration
+func (handler *OTLPLogHandler) Name() string {
+    return handler.options.Name
+}
+
+// Enabled returns if the handler is active
+func (handler *OTLPLogHandler) Enabled() bool {
+    return atomic.LoadUint32(&handler.disabled) == falseUint32
+}
+
+// Enable activates the handler
+func (handler *OTLPLogHandler) Enable() {
+    atomic.StoreUint32(&handler.disabled, falseUint32)
+}
+
+// Disable deactivates the handler
+func (handler *OTLPLogHandler) Disable() {
+    atomic.StoreUint32(&handler.disabled, trueUint32)
+}
+
+// Level gives if the pushed entry should be logged by the handler
+func (handler *OTLPLogHandler) Level() LogLevel {
+    if handler.options.Level == LogLevel(0) {
+        return AllLogLevels
+    }
+    return handler.options.Level
+}
+
+// Format gives the format that will be used by the handler
+func (handler *OTLPLogHandler) Format() LogFormat {
+    return CustomFormat
+}
+
+// QueueLen gives the queue length that will be used when the entry is queued
+func (handler *OTLPLogHandler) QueueLen() int {
+    return -1
+}
+
+// Err returns the error of the last exported batch
+func (handler *OTLPLogHandler) Err() error {
+    handler.Lock()
+    defer handler.Unlock()
+    return handler.err
+}
+
+// Process evaluates the given entry
+func (handler *OTLPLogHandler) Process(entry interface{}) {
+    handler.TryProcess(entry)
+}
+
+// TryProcess adds the entry to the batch and exports the batch when it is full,
+// the error of the export or the error of the last batch exported by the flush timer is returned
+func (handler *OTLPLogHandler) TryProcess(entry interface{}) error {
+    e, ok := entry.(*LogEntry)
+    if !ok || e == nil {
+        return nil
+    }
+    
+    full, err := handler.add(e)
+    if full {
+        if ferr := handler.Flush(); ferr != nil {
+            return ferr
+        }
+    }
+    return err
+}
+
+// add appends the record to the scope of the entry category, returns if the batch is full 
+// and the error of the timer export which is not reported yet
+func (handler *OTLPLogHandler) add(e *LogEntry) (bool, error) {
+    handler.Lock()
+    defer handler.Unlock()
+    
+    records, ok := handler.scopes[e.category]
+    if ok {
+        records = append(records, ',')
+    } else {
+        handler.order = append(handler.order, e.category)
+    }
+    handler.scopes[e.category] = e.AppendOTLP(records)
+    handler.pending++
+    
+    err := handler.unreported
+    handler.unreported = nil
+    
+    if handler.pending >= handler.options.BatchSize {
+        return true, err
+    }
+    if handler.timer == nil && handler.options.FlushInterval > 0 {
+        handler.timer = time.AfterFunc(handler.options.FlushInterval, handler.timedFlush)
+    }
+    return false, err
+}
+
+// Flush exports the records waiting for their batch, the request is sent without holding 
+// the handler lock so that the entries can be added while the batch is exported
+func (handler *OTLPLogHandler) Flush() error {
+    handler.exporting.Lock()
+    defer handler.exporting.Unlock()
+    
+    handler.Lock()
+    payload := handler.batch()
+    handler.Unlock()
+    
+    if payload == nil {
+        return nil
+    }
+    err := handler.export(payload)
+    
+    handler.Lock()
+    handler.err = err
+    handler.Unlock()
+    return err
+}
+
+// Close exports the records waiting for their batch
+func (handler *OTLPLogHandler) Close() error {
+    return handler.Flush()
+}
+
+func (handler *OTLPLogHandler) timedFlush() {
+    handler.Lock()
+    handler.timer = nil
+    handler.Unlock()
+    
+    if err := handler.Flush(); err != nil {
+        handler.Lock()
+        handler.unreported = err
+        handler.Unlock()
+    }
+}
+
+// batch builds the export request of the pending records, should be called in both locks.
+// The returned payload is valid until the next batch.
+func (handler *OTLPLogHandler) batch() []byte {
+    if handler.timer != nil {
+        handler.timer.Stop()
+        handler.timer = nil
+    }
+    if handler.pending == 0 {
+        return nil
+    }
+    
+    payload := &handler.payload
+    payload.Reset()
+    payload.WriteString(`{"resourceLogs":[{"resource":{"attributes":[`)
+    payload.Write(handler.resource)
+    payload.WriteString(`]},"scopeLogs":[`)
+    for i, category := range handler.order {
+        if i > 0 {
+            payload.WriteByte(',')
+        }
+        payload.WriteString(`{"scope":{"name":`)
+        payload.Write(appendJSONString(nil, category))
+        payload.WriteString(`},"logRecords":[`)
+        payload.Write(handler.scopes[category])
+        payload.WriteString(`]}`)
+    }
+    payload.WriteString(`]}]}`)
+    
+    clear(handler.scopes)
+    handler.order = handler.order[:0]
+    handler.pending = 0
+    
+    return payload.Bytes()
+}
+
+func (handler *OTLPLogHandler) export(payload []byte) error {
+    request, err := http.NewRequest(http.MethodPost, handler.options.Endpoint, bytes.NewReader(payload))
+    if err != nil {
+        return err
+    }
+    request.Header.Set("Content-Type", "application/json")
+    for k, v := range handler.options.Headers {
+        request.Header.Set(k, v)
+    }
+    
+    response, err := handler.client.Do(request)
+    if err != nil {
+        return err
+    }
+    defer response.Body.Close()
+    io.Copy(io.Discard, response.Body)
+    
+    if response.StatusCode < 200 || response.StatusCode > 299 {
+        return fmt.Errorf("logmanager: OTLP export failed with status %d", response.StatusCode)
+    }
+    return nil
+}
@@ -0,0 +1,294 @@
+//	The MIT License (MIT)
+//
+//	Copyright (c) 2016, Cagatay Dogan
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//		The above copyright notice and this permission notice shall be included in
+//		all copies or substantial portions of the Software.
+//
+//		THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//		IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//		FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//		AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//		LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//		OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+//		THE SOFTWARE.
+
+package logmanager
+
+import (
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io"
+    "net/http"
+    "net/http/httptest"
+    "sync"
+    "sync/atomic"
+    "testing"
+    "time"
+)
+
+type otlpReceiver struct {
+    sync.Mutex
+    status int
+    requests []map[string]interface{}
+    headers []http.Header
+}
+
+func (receiver *otlpReceiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+    body, _ := io.ReadAll(r.Body)
+    var payload map[string]interface{}
+    if err := json.Unmarshal(body, &payload); err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+
+    receiver.Lock()
+    defer receiver.Unlock()
+    receiver.requests = append(receiver.requests, payload)
+    receiver.headers = append(receiver.headers, r.Header)
+    if receiver.status != 0 {
+        w.WriteHeader(receiver.status)
+    }
+}
+
+// path walks the decoded JSON with map keys and slice indexes
+func path(value interface{}, steps ...interface{}) interface{} {
+    for _, step := range steps {
+        switch s := step.(type) {
+        case string:
+            m, _ := value.(map[string]interface{})
+            value = m[s]
+        case int:
+            a, _ := value.([]interface{})
+            if s >= len(a) {
+                return nil
+            }
+            value = a[s]
+        }
+    }
+    return value
+}
+
+func TestOTLPLogHandler(t *testing.T) {
+    fmt.Println("\nTestOTLPLogHandler\n~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~")
+
+    receiver := &otlpReceiver{}
+    server := httptest.NewServer(receiver)
+    defer server.Close()
+
+    handler := NewOTLPLogHandler(OTLPOptions{
+        Name: "otlp-test",
+        Endpoint: server.URL + "/v1/logs",
+        Headers: map[string]string{ "Authorization": "Bearer token" },
+        Resource: map[string]string{ "service.name": "checkout" },
+        BatchSize: 3,
+        FlushInterval: -1,
+    })
+    RegisterHandler(handler)
+
+    info := acquireLogEntry(LevelInfo, "order placed", map[string]interface{}{ 
+        "order": 42, "items": []interface{}{ "book", 1.5 }, "paid": true,
+    })
+    info.SetTrace(testTrace)
+    info.SetCategory("otlp.orders")
+    Log(info)
+    GetLogger("otlp.orders").LogWarning("stock low", nil)
+    GetLogger("otlp.payments").LogError(errors.New("card declined"), nil)
+    GetLogger("otlp.payments").LogMessage("retrying", nil)
+
+    UnregisterHandler("otlp-test")
+    if err := handler.Flush(); err != nil {
+        t.Fatal(err)
+    }
+
+    receiver.Lock()
+    defer receiver.Unlock()
+    if len(receiver.requests) != 2 {
+        t.Fatalf("expected 2 batches, got %d", len(receiver.requests))
+    }
+    if auth := receiver.headers[0].Get("Authorization"); auth != "Bearer token" {
+        t.Errorf("expected the authorization header, got %q", auth)
+    }
+
+    resource := path(receiver.requests[0], "resourceLogs", 0, "resource", "attributes")
+    if path(resource, 1, "key") != "service.name" || path(resource, 1, "value", "stringValue") != "checkout" {
+        t.Errorf("unexpected resource %v", resource)
+    }
+
+    // the records are found by their body
+    records := map[string]interface{}{}
+    scopes := map[string]string{}
+    for _, batch := range receiver.requests {
+        for _, scope := range path(batch, "resourceLogs", 0, "scopeLogs").([]interface{}) {
+            for _, record := range path(scope, "logRecords").([]interface{}) {
+                body := path(record, "body", "stringValue").(string)
+                records[body] = record
+                scopes[body] = path(scope, "scope", "name").(string)
+            }
+        }
+    }
+    if len(records) != 4 {
+        t.Fatalf("expected 4 records, got %v", records)
+    }
+
+    record := records["order placed"]
+    if scopes["order placed"] != "otlp.orders" || path(record, "severityNumber") != float64(9) || 
+        path(record, "severityText") != "INFO" {
+        t.Errorf("unexpected record %v", record)
+    }
+    if path(record, "traceId") != "4bf92f3577b34da6a3ce929d0e0e4736" || path(record, "spanId") != "00f067aa0ba902b7" || 
+        path(record, "flags") != float64(1) {
+        t.Errorf("unexpected trace fields %v", record)
+    }
+
+    attributes := map[string]interface{}{}
+    for _, a := range path(record, "attributes").([]interface{}) {
+        attributes[path(a, "key").(string)] = path(a, "value")
+    }
+    if path(attributes["order"], "intValue") != "42" || path(attributes["paid"], "boolValue") != true ||
+        path(attributes["items"], "arrayValue", "values", 1, "doubleValue") != 1.5 {
+        t.Errorf("unexpected attributes %v", attributes)
+    }
+
+    if scopes["stock low"] != "otlp.orders" || path(records["stock low"], "severityNumber") != float64(13) {
+        t.Errorf("unexpected warning record %v", records["stock low"])
+    }
+    record = records["card declined"]
+    if scopes["card declined"] != "otlp.payments" || path(record, "severityNumber") != float64(17) ||
+        path(record, "attributes", 2, "key") != "exception.message" {
+        t.Errorf("unexpected error record %v", record)
+    }
+    if scopes["retrying"] != "otlp.payments" || path(records["retrying"], "severityText") != "INFO" {
+        t.Errorf("unexpected info record %v", records["retrying"])
+    }
+}
+
+func TestOTLPReservedArgs(t *testing.T) {
+    entry := NewErrorLogEntry(errors.New("db down"), map[string]interface{}{
+        "log.record.uid": "custom",
+        "duration_ns": 5,
+        "exception.type": "custom",
+        "args.note": "prefixed",
+        "user": "alice",
+    })
+    entry.duration = time.Second
+
+    var record map[string]interface{}
+    if err := json.Unmarshal(entry.AppendOTLP(nil), &record); err != nil {
+        t.Fatal(err)
+    }
+    attributes := map[string]int{}
+    for _, attribute := range path(record, "attributes").([]interface{}) {
+        attributes[path(attribute, "key").(string)]++
+    }
+    for _, key := range []string{ "log.record.uid", "duration_ns", "exception.type", "exception.message", 
+        "args.log.record.uid", "args.duration_ns", "args.exception.type", "args.args.note", "user" } {
+        if attributes[key] != 1 {
+            t.Errorf("expected the %s attribute once, got %v", key, attributes)
+        }
+    }
+}
+
+func TestOTLPLogHandlerFailure(t *testing.T) {
+    receiver := &otlpReceiver{ status: http.StatusServiceUnavailable }
+    server := httptest.NewServer(receiver)
+    defer server.Close()
+
+    handler := NewOTLPLogHandler(OTLPOptions{ Endpoint: server.URL, BatchSize: 1 })
+    if err := handler.TryProcess(NewInfoLogEntry("lost", nil)); err == nil || handler.Err() == nil {
+        t.Error("expected the export error")
+    }
+
+    // the records waiting for their batch are exported after the flush interval
+    receiver.Lock()
+    receiver.status = 0
+    receiver.Unlock()
+
+    handler = NewOTLPLogHandler(OTLPOptions{ Endpoint: server.URL, FlushInterval: 10*time.Millisecond })
+    defer handler.Close()
+    if err := handler.TryProcess(NewInfoLogEntry("delayed", nil)); err != nil {
+        t.Fatal(err)
+    }
+
+    deadline := time.Now().Add(2*time.Second)
+    for {
+        receiver.Lock()
+        count := len(receiver.requests)
+        receiver.Unlock()
+        if count == 2 {
+            break
+        }
+        if time.Now().After(deadline) {
+            t.Fatalf("expected the timed export, got %d requests", count)
+        }
+        time.Sleep(10*time.Millisecond)
+    }
+}
+
+func TestOTLPLogHandlerExportOutsideLock(t *testing.T) {
+    started := make(chan struct{}, 1)
+    release := make(chan struct{})
+    status := int32(http.StatusServiceUnavailable)
+    server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        io.Copy(io.Discard, r.Body)
+        select {
+        case started <- struct{}{}:
+        default:
+        }
+        <-release
+        w.WriteHeader(int(atomic.LoadInt32(&status)))
+    }))
+    defer server.Close()
+
+    handler := NewOTLPLogHandler(OTLPOptions{ Endpoint: server.URL, BatchSize: 10, FlushInterval: 10*time.Millisecond })
+    defer handler.Close()
+    defer close(release)
+    if err := handler.TryProcess(NewInfoLogEntry("timed", nil)); err != nil {
+        t.Fatal(err)
+    }
+
+    // the handler is not blocked while the timer exports the batch
+    select {
+    case <-started:
+    case <-time.After(2*time.Second):
+        t.Fatal("expected the timed export")
+    }
+    done := make(chan error, 1)
+    go func() {
+        handler.Err()
+        done <- handler.TryProcess(NewInfoLogEntry("while exporting", nil))
+    }()
+    select {
+    case err := <-done:
+        if err != nil {
+            t.Errorf("expected no error before the export completes, got %v", err)
+        }
+    case <-time.After(time.Second):
+        t.Fatal("expected Err and TryProcess not to wait for the export")
+    }
+    release <- struct{}{}
+
+    // the failure of the timed export is reported once by the next TryProcess
+    deadline := time.Now().Add(2*time.Second)
+    for handler.Err() == nil {
+        if time.Now().After(deadline) {
+            t.Fatal("expected the export error")
+        }
+        time.Sleep(5*time.Millisecond)
+    }
+    atomic.StoreInt32(&status, http.StatusOK)
+    if err := handler.TryProcess(NewInfoLogEntry("after failure", nil)); err == nil {
+        t.Error("expected the error of the timed export")
+    }
+    if err := handler.TryProcess(NewInfoLogEntry("reported", nil)); err != nil {
+        t.Errorf("expected the error to be reported once, got %v", err)
+    }
+}
@@ -35,16 +35,48 @@ const (
 
 type logQueueItem struct {
     data interface{}
+    seq uint64
     next *logQueueItem
 }
 
+var (
+    queueItemPool = sync.Pool{
+        New: func() interface{} {
+            return &logQueueItem{}
+        },
+    }
+)
+
+// the lanes of the queue in the severity order, the info lane is evicted first
+const (
+    fatalLane = iota
+    errorLane
+    warningLane
+    infoLane
+    laneCount
+)
+
+type logQueueLane struct {
+    cnt int32
+    head *logQueueItem
+    tail *logQueueItem
+}
+
+// logQueue keeps the items in a lane per level. The items are popped in the order they are pushed, 
+// when the queue is full the oldest item of the lowest severity lane is evicted and 
+// the reserved capacity can be used only by the error and fatal items. The new item is dropped 
+// instead of evicting the queued items if the overflow policy is OverflowDropNewest.
 type logQueue struct {
     sync.Mutex
     cnt int32
     cap int32
+    dropped uint64
+    // seq orders the items across the lanes
+    seq uint64
     realCap int32
-    head *logQueueItem
-    tail *logQueueItem
+    reserved int32
+    overflow OverflowPolicy
+    lanes [laneCount]logQueueLane
 }
 
 func newLogQueue(cap int) *logQueue {
@@ -53,10 +85,38 @@ func newLogQueue(cap int) *logQueue {
     return result
 }
 
+func laneOf(level LogLevel) int {
+    switch {
+    case level.Has(LevelFatal):
+        return fatalLane
+    case level.Has(LevelError):
+        return errorLane
+    case level.Has(LevelWarning):
+        return warningLane
+    }
+    return infoLane
+}
+
+// dataLevel returns the level of the queued data, the raw byte arrays are treated as info
+func dataLevel(data interface{}) LogLevel {
+    switch d := data.(type) {
+    case *logBuffer:
+        return d.level
+    case *LogEntry:
+        return d.level
+    }
+    return LevelInfo
+}
+
 func (q *logQueue) count() int {
     return int(atomic.LoadInt32(&q.cnt))
 }
 
+// droppedCount returns the number of the items evicted since the queue was full
+func (q *logQueue) droppedCount() uint64 {
+    return atomic.LoadUint64(&q.dropped)
+}
+
 func (q *logQueue) capacity() int {
     return int(atomic.LoadInt32(&q.cap))
 }
@@ -68,54 +128,146 @@ func (q *logQueue) setCapacity(cap int) {
         cap = maxQueueLen
     }
     q.Lock()
-    q.cap = int32(cap)
+    atomic.StoreInt32(&q.cap, int32(cap))
     q.realCap = q.cap
     q.Unlock()
 }
 
+// setReserved sets the capacity which cannot be used by the info and warning items
+func (q *logQueue) setReserved(reserved int) {
+    if reserved < 0 {
+        reserved = 0
+    } else if reserved > maxQueueLen {
+        reserved = maxQueueLen
+    }
+    q.Lock()
+    q.reserved = int32(reserved)
+    q.Unlock()
+}
+
+func (q *logQueue) setOverflow(policy OverflowPolicy) {
+    q.Lock()
+    q.overflow = policy
+    q.Unlock()
+}
+
 func (q *logQueue) push(data interface{}) {
     if utils.HasValue(data) {
-        item := &logQueueItem{
-            data: data,
-        }
+        lane := laneOf(dataLevel(data))
 
         q.Lock()
         defer q.Unlock()
         
-        if q.cnt == q.realCap {
-            q.head, q.head.next = q.head.next, nil
-            if q.head == nil {
-                q.tail = nil
+        if q.realCap > 0 {
+            // the capacity can be shrunk at runtime, so evict until there is room for the item
+            if lane >= warningLane && q.reserved > 0 {
+                limit := q.realCap - q.reserved
+                if limit < 1 {
+                    limit = 1
+                }
+                for q.lanes[warningLane].cnt + q.lanes[infoLane].cnt >= limit {
+                    if !q.evict(lane) {
+                        q.drop(data)
+                        return
+                    }
+                }
+            }
+            for q.cnt >= q.realCap {
+                if !q.evict(lane) {
+                    q.drop(data)
+                    return
+                }
             }
-            q.cnt--
-        } 
+        }
         
-        if q.tail == nil {
-            q.head = item
-            q.tail = item
-            return
+        item := queueItemPool.Get().(*logQueueItem)
+        item.data = data
+        item.seq = q.seq
+        q.seq++
+
+        l := &q.lanes[lane]
+        if l.tail == nil {
+            l.head = item
+        } else {
+            l.tail.next = item
         }
-        q.tail.next = item
-        q.tail = item
-        q.cnt++
+        l.tail = item
+        l.cnt++
+        atomic.AddInt32(&q.cnt, 1)
+    }
+}
+
+// evict removes the oldest item of the lowest severity lane not more severe than the given lane,
+// returns false if there is no such item
+func (q *logQueue) evict(lane int) bool {
+    if q.overflow == OverflowDropNewest {
+        return false
+    }
+    for i := laneCount - 1; i >= lane; i-- {
+        if q.lanes[i].cnt > 0 {
+            q.drop(q.remove(i))
+            return true
+        }
+    }
+    return false
+}
+
+func (q *logQueue) drop(data interface{}) {
+    atomic.AddUint64(&q.dropped, 1)
+    releaseData(data)
+}
+
+// remove removes the head of the lane
+func (q *logQueue) remove(lane int) (data interface{}) {
+    l := &q.lanes[lane]
+    item := l.head
+    l.head, item.next = item.next, nil
+    if l.head == nil {
+        l.tail = nil
+    }
+    l.cnt--
+    atomic.AddInt32(&q.cnt, -1)
+
+    data = item.data
+    item.data = nil
+    queueItemPool.Put(item)
+    return
+}
+
+// discard drops all the queued items
+func (q *logQueue) discard() {
+    for data := q.pop(); utils.HasValue(data); data = q.pop() {
+        atomic.AddUint64(&q.dropped, 1)
+        releaseData(data)
     }
 }
 
+// ack is a no op since the popped items are removed from the memory queue
+func (q *logQueue) ack() {
+}
+
+func (q *logQueue) durable() bool {
+    return false
+}
+
+func (q *logQueue) close() error {
+    return nil
+}
+
 func (q *logQueue) pop() (data interface{}) {
     q.Lock()
     defer q.Unlock()
     
-    if q.cnt > 0 {
-        item := q.head
-        q.head = q.head.next
-        item.next = nil
-        if q.head == nil {
-            q.tail = nil
+    // the oldest item is the head of a lane with the lowest sequence
+    oldest := -1
+    for lane := range q.lanes {
+        if head := q.lanes[lane].head; head != nil && 
+            (oldest < 0 || head.seq < q.lanes[oldest].head.seq) {
+            oldest = lane
         }
-        q.cnt--
-        
-        data = item.data
-        item.data = nil
-    }    
-    return
-}
\ No newline at end of file
+    }
+    if oldest < 0 {
+        return nil
+    }
+    return q.remove(oldest)
+}
@@ -0,0 +1,79 @@
+//	The MIT License (MIT)
+//
+//	Copyright (c) 2016, Cagatay Dogan
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//		The above copyright notice and this permission notice shall be included in
+//		all copies or substantial portions of the Software.
+//
+//		THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//		IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//		FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//		AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//		LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//		OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+//		THE SOFTWARE.
+
+package logmanager
+
+import (
+    "errors"
+    "fmt"
+    "testing"
+)
+
+func TestLogQueueOrder(t *testing.T) {
+    q := newLogQueue(-1)
+    q.push(NewInfoLogEntry("i1", nil))
+    q.push(NewWarningLogEntry("w1", nil))
+    q.push(NewErrorLogEntry(errors.New("e1"), nil))
+    q.push(NewInfoLogEntry("i2", nil))
+    q.push(NewFatalLogEntry(errors.New("f1"), nil))
+    q.push(NewWarningLogEntry("w2", nil))
+
+    // the entries are popped in the order they are pushed regardless of their level
+    for _, expected := range []string{ "i1", "w1", "e1", "i2", "f1", "w2" } {
+        entry, _ := q.pop().(*LogEntry)
+        if entry == nil || entry.Message() != expected {
+            t.Fatalf("expected %s, got %v", expected, entry)
+        }
+    }
+    if q.pop() != nil {
+        t.Error("expected an empty queue")
+    }
+}
+
+func TestLogQueuePriority(t *testing.T) {
+    fmt.Println("\nTestLogQueuePriority\n~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~")
+
+    q := newLogQueue(4)
+    q.setReserved(2)
+
+    for i := 1; i <= 3; i++ {
+        q.push(NewInfoLogEntry(fmt.Sprintf("i%d", i), nil))
+    }
+    if q.count() != 2 {
+        t.Fatalf("expected the info entries to leave the reserved capacity free, got %d", q.count())
+    }
+    for i := 1; i <= 3; i++ {
+        q.push(NewErrorLogEntry(fmt.Errorf("e%d", i), nil))
+    }
+    q.push(NewFatalLogEntry(errors.New("f1"), nil))
+    q.push(NewInfoLogEntry("i4", nil))
+
+    for _, expected := range []string{ "e1", "e2", "e3", "f1" } {
+        entry, _ := q.pop().(*LogEntry)
+        if entry == nil || entry.Message() != expected {
+            t.Fatalf("expected %s, got %v", expected, entry)
+        }
+    }
+    if q.pop() != nil || q.droppedCount() != 4 {
+        t.Errorf("expected the info entries to be dropped, got %d dropped", q.droppedCount())
+    }
+}
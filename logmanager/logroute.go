@@ -0,0 +1,184 @@
+//	The MIT License (MIT)
+//
+//	Copyright (c) 2016, Cagatay Dogan
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//		The above copyright notice and this permission notice shall be included in
+//		all copies or substantial portions of the Software.
+//
+//		THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//		IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//		FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//		AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//		LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//		OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+//		THE SOFTWARE.
+
+package logmanager
+
+import (
+    "errors"
+    "fmt"
+    "regexp"
+    "strings"
+    "sync/atomic"
+)
+
+const (
+    // RouteAllBuckets can be used as a rule target to send the entry to the buckets 
+    // which are not named by any rule, as if it is not routed
+    RouteAllBuckets = "*"
+)
+
+var (
+    routes atomic.Value
+
+    // ErrNoRouteTarget is returned when a routing rule does not name any bucket
+    ErrNoRouteTarget = errors.New("logmanager: routing rule without buckets")
+)
+
+// RouteRule sends the matching entries to the named buckets. All the conditions 
+// which are set should match, a rule without conditions matches every entry.
+type RouteRule struct {
+    // Levels is the set of the levels matched, all levels if 0
+    Levels LogLevel
+    // Message is a regular expression the message should match
+    Message string
+    // Category matches the entries with the category or a sub category of it, 
+    // "payment" matches "payment" and "payment.card"
+    Category string
+    // Args are the args the entry should have, a nil value matches any value and
+    // the other values are compared in their printed form
+    Args map[string]interface{}
+    // Buckets are the names of the buckets the entry is sent to
+    Buckets []string
+    // Stop ends the evaluation of the rules coming after a matching rule
+    Stop bool
+}
+
+type router struct {
+    rules []RouteRule
+    messages []*regexp.Regexp
+    targets []map[string]bool
+    routed map[string]bool
+}
+
+// SetRoutes replaces the routing rules which are evaluated in the given order. 
+// An entry matching none of the rules is sent to every bucket accepting its level,
+// an entry matching some rules is sent only to the buckets named by them. 
+// A bucket named by any rule receives only the entries routed to it.
+// Calling SetRoutes without rules restores the default routing.
+func SetRoutes(rules ...RouteRule) error {
+    if len(rules) == 0 {
+        routes.Store((*router)(nil))
+        return nil
+    }
+
+    r := &router{
+        rules: make([]RouteRule, len(rules)),
+        messages: make([]*regexp.Regexp, len(rules)),
+        targets: make([]map[string]bool, len(rules)),
+        routed: make(map[string]bool),
+    }
+    copy(r.rules, rules)
+
+    for i, rule := range r.rules {
+        if len(rule.Buckets) == 0 {
+            return ErrNoRouteTarget
+        }
+        if rule.Message != "" {
+            re, err := regexp.Compile(rule.Message)
+            if err != nil {
+                return fmt.Errorf("logmanager: invalid message pattern of rule %d: %v", i, err)
+            }
+            r.messages[i] = re
+        }
+
+        r.targets[i] = make(map[string]bool, len(rule.Buckets))
+        for _, name := range rule.Buckets {
+            r.targets[i][name] = true
+            if name != RouteAllBuckets {
+                r.routed[name] = true
+            }
+        }
+    }
+
+    routes.Store(r)
+    return nil
+}
+
+// Routes returns the routing rules in use
+func Routes() []RouteRule {
+    r := currentRouter()
+    if r == nil {
+        return nil
+    }
+    return append([]RouteRule(nil), r.rules...)
+}
+
+func currentRouter() *router {
+    r, _ := routes.Load().(*router)
+    return r
+}
+
+// match returns the rules matching the entry, nil if there is no match
+func (r *router) match(entry *LogEntry) []bool {
+    var matched []bool
+    for i := range r.rules {
+        if r.matchRule(i, entry) {
+            if matched == nil {
+                matched = make([]bool, len(r.rules))
+            }
+            matched[i] = true
+            if r.rules[i].Stop {
+                break
+            }
+        }
+    }
+    return matched
+}
+
+func (r *router) matchRule(i int, entry *LogEntry) bool {
+    rule := &r.rules[i]
+    if rule.Levels != 0 && !rule.Levels.Has(entry.level) {
+        return false
+    }
+    if rule.Category != "" && !categoryMatches(rule.Category, entry.category) {
+        return false
+    }
+    if r.messages[i] != nil && !r.messages[i].MatchString(entry.message) {
+        return false
+    }
+    for k, expected := range rule.Args {
+        value, ok := entry.args[k]
+        if !ok || (expected != nil && fmt.Sprint(value) != fmt.Sprint(expected)) {
+            return false
+        }
+    }
+    return true
+}
+
+// accepts returns if the bucket with the given name should receive the entry matching the given rules
+func (r *router) accepts(name string, matched []bool) bool {
+    if matched == nil {
+        return !r.routed[name]
+    }
+    for i, ok := range matched {
+        if ok && (r.targets[i][name] || (r.targets[i][RouteAllBuckets] && !r.routed[name])) {
+            return true
+        }
+    }
+    return false
+}
+
+// categoryMatches returns if the category is the parent category or one of its sub categories
+func categoryMatches(parent, category string) bool {
+    return category == parent || 
+        (strings.HasPrefix(category, parent) && category[len(parent)] == '.')
+}
@@ -0,0 +1,89 @@
+//	The MIT License (MIT)
+//
+//	Copyright (c) 2016, Cagatay Dogan
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//		The above copyright notice and this permission notice shall be included in
+//		all copies or substantial portions of the Software.
+//
+//		THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//		IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//		FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//		AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//		LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//		OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+//		THE SOFTWARE.
+
+package logmanager
+
+import (
+    "errors"
+    "fmt"
+    "testing"
+)
+
+func TestRoutes(t *testing.T) {
+    fmt.Println("\nTestRoutes\n~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~")
+
+    err := SetRoutes(
+        RouteRule{
+            Levels: LevelError | LevelFatal,
+            Category: "payment",
+            Buckets: []string{ "audit", "shipper" },
+        },
+        RouteRule{
+            Levels: LevelInfo,
+            Message: "^cache (hit|miss)",
+            Buckets: []string{ "memory" },
+            Stop: true,
+        },
+        RouteRule{
+            Args: map[string]interface{}{ "tenant": 42 },
+            Buckets: []string{ RouteAllBuckets, "shipper" },
+        },
+    )
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer SetRoutes()
+
+    payment := NewErrorLogEntry(errors.New("card declined"), nil)
+    payment.SetCategory("payment.card")
+
+    tenant := NewInfoLogEntry("login", map[string]interface{}{ "tenant": "42" })
+
+    noise := NewInfoLogEntry("cache miss", map[string]interface{}{ "tenant": 42 })
+
+    tests := []struct{
+        entry *LogEntry
+        buckets map[string]bool
+    }{
+        { payment, map[string]bool{ "audit": true, "shipper": true } },
+        { tenant, map[string]bool{ "console": true, "shipper": true } },
+        { noise, map[string]bool{ "memory": true } },
+        { NewWarningLogEntry("disk full", nil), map[string]bool{ "console": true } },
+    }
+
+    r := currentRouter()
+    for _, test := range tests {
+        matched := r.match(test.entry)
+        for _, name := range []string{ "audit", "shipper", "memory", "console" } {
+            if r.accepts(name, matched) != test.buckets[name] {
+                t.Errorf("expected %q routed to %s to be %v", test.entry.Message(), name, test.buckets[name])
+            }
+        }
+    }
+
+    if SetRoutes(RouteRule{ Message: "(" , Buckets: []string{ "memory" } }) == nil {
+        t.Error("expected an invalid pattern error")
+    }
+    if SetRoutes(RouteRule{ Message: "x" }) != ErrNoRouteTarget {
+        t.Error("expected ErrNoRouteTarget")
+    }
+}
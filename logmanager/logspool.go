@@ -0,0 +1,545 @@
+//	The MIT License (MIT)
+//
+//	Copyright (c) 2016, Cagatay Dogan
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//		The above copyright notice and this permission notice shall be included in
+//		all copies or substantial portions of the Software.
+//
+//		THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//		IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//		FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//		AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//		LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//		OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+//		THE SOFTWARE.
+
+package logmanager
+
+import (
+    "encoding/binary"
+    "errors"
+    "fmt"
+    "hash/crc32"
+    "io"
+    "os"
+    "path/filepath"
+    "sort"
+    "strconv"
+    "strings"
+    "sync"
+    "sync/atomic"
+)
+
+const (
+    defaultSpoolSegmentSize = int64(16*1024*1024)
+    defaultSpoolMaxSize = int64(1024*1024*1024)
+    spoolRecordHeaderLen = 8
+    spoolSegmentExt = ".seg"
+    spoolAckFile = "ack"
+    spoolAckLen = 20
+)
+
+var (
+    spoolTable = crc32.MakeTable(crc32.Castagnoli)
+
+    // ErrSpoolClosed is returned when a closed spool is used
+    ErrSpoolClosed = errors.New("logmanager: spool closed")
+    errSpoolRecord = errors.New("logmanager: invalid spool record")
+)
+
+// SpoolOptions configure the disk backed queue of a bucket
+type SpoolOptions struct {
+    // Dir is the directory of the segment files, a directory should be used by a single bucket
+    Dir string
+    // SegmentSize is the size after which a new segment file is started, 16MB by default
+    SegmentSize int64
+    // MaxSize limits the total size of the segments, the oldest segments are dropped 
+    // when it is exceeded, 1GB by default
+    MaxSize int64
+    // Sync flushes every record and acknowledgement to the disk
+    Sync bool
+}
+
+// bucketQueue is the queue of the entries waiting to be processed by a bucket
+type bucketQueue interface {
+    push(data interface{})
+    // pop returns the next entry, the entry is removed from a durable queue by ack
+    pop() interface{}
+    // ack removes the last popped entry from a durable queue
+    ack()
+    // discard drops all the queued entries
+    discard()
+    // durable returns if the entries popped but not acknowledged are delivered again
+    durable() bool
+    count() int
+    capacity() int
+    setCapacity(cap int)
+    setReserved(reserved int)
+    setOverflow(policy OverflowPolicy)
+    droppedCount() uint64
+    close() error
+}
+
+// logSpool is a write ahead queue of segment files. Every record is a 4 byte length and 
+// a 4 byte CRC-32C checksum followed by the entry. The position of the first record not 
+// acknowledged is kept in the ack file, the records after it are replayed when the spool 
+// is opened again, so the delivery is at least once.
+type logSpool struct {
+    sync.Mutex
+    options SpoolOptions
+    custom bool
+    segments []uint64
+    // counts keeps the number of the records not acknowledged in each segment
+    counts []int
+    writer *os.File
+    writeOff int64
+    reader *os.File
+    readSeg uint64
+    readOff int64
+    pendingOff int64
+    pending bool
+    ackFile *os.File
+    cnt int
+    size int64
+    dropped uint64
+    header [spoolRecordHeaderLen]byte
+    buf []byte
+    closed bool
+}
+
+// openSpool opens or creates the spool in the options directory and finds the records
+// to be replayed. custom spools keep the entries as JSON and pop them as *LogEntry.
+func openSpool(options SpoolOptions, custom bool) (*logSpool, error) {
+    if options.Dir == "" {
+        return nil, errors.New("logmanager: spool directory required")
+    }
+    if options.SegmentSize <= 0 {
+        options.SegmentSize = defaultSpoolSegmentSize
+    }
+    if options.MaxSize <= 0 {
+        options.MaxSize = defaultSpoolMaxSize
+    }
+    if options.MaxSize < options.SegmentSize {
+        options.MaxSize = options.SegmentSize
+    }
+    if err := os.MkdirAll(options.Dir, 0700); err != nil {
+        return nil, err
+    }
+
+    spool := &logSpool{
+        options: options,
+        custom: custom,
+    }
+    if err := spool.open(); err != nil {
+        spool.close()
+        return nil, err
+    }
+    return spool, nil
+}
+
+func (spool *logSpool) segmentPath(id uint64) string {
+    return filepath.Join(spool.options.Dir, fmt.Sprintf("%020d%s", id, spoolSegmentExt))
+}
+
+func (spool *logSpool) open() error {
+    names, err := filepath.Glob(filepath.Join(spool.options.Dir, "*" + spoolSegmentExt))
+    if err != nil {
+        return err
+    }
+    for _, name := range names {
+        id, err := strconv.ParseUint(strings.TrimSuffix(filepath.Base(name), spoolSegmentExt), 10, 64)
+        if err == nil {
+            spool.segments = append(spool.segments, id)
+        }
+    }
+    sort.Slice(spool.segments, func(i, j int) bool {
+        return spool.segments[i] < spool.segments[j]
+    })
+
+    if spool.ackFile, err = os.OpenFile(filepath.Join(spool.options.Dir, spoolAckFile), os.O_RDWR|os.O_CREATE, 0600); err != nil {
+        return err
+    }
+    spool.readSeg, spool.readOff = spool.readAck()
+
+    // the acknowledged segments are not needed any more
+    for len(spool.segments) > 0 && spool.segments[0] < spool.readSeg {
+        os.Remove(spool.segmentPath(spool.segments[0]))
+        spool.segments = spool.segments[1:]
+    }
+    if len(spool.segments) == 0 || spool.segments[0] != spool.readSeg {
+        if len(spool.segments) > 0 {
+            spool.readSeg = spool.segments[0]
+        } else if spool.readSeg == 0 {
+            spool.readSeg = 1
+        }
+        spool.readOff = 0
+    }
+
+    for i, id := range spool.segments {
+        last := i == len(spool.segments) - 1
+        start := int64(0)
+        if id == spool.readSeg {
+            start = spool.readOff
+        }
+
+        cnt, end, size, err := spool.scan(id, start)
+        if err != nil {
+            return err
+        }
+        if last && end < size {
+            // a record torn by a crash, it has never been acknowledged to the producer
+            if err = os.Truncate(spool.segmentPath(id), end); err != nil {
+                return err
+            }
+            size = end
+        }
+        spool.counts = append(spool.counts, cnt)
+        spool.cnt += cnt
+        spool.size += size
+    }
+
+    if len(spool.segments) == 0 {
+        spool.segments = append(spool.segments, spool.readSeg)
+        spool.counts = append(spool.counts, 0)
+    }
+    last := spool.segments[len(spool.segments) - 1]
+    if spool.writer, err = os.OpenFile(spool.segmentPath(last), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600); err != nil {
+        return err
+    }
+    info, err := spool.writer.Stat()
+    if err != nil {
+        return err
+    }
+    spool.writeOff = info.Size()
+    return nil
+}
+
+// scan counts the valid records of the segment from start and returns the end of the last valid record
+func (spool *logSpool) scan(id uint64, start int64) (cnt int, end int64, size int64, err error) {
+    f, err := os.Open(spool.segmentPath(id))
+    if err != nil {
+        return 0, 0, 0, err
+    }
+    defer f.Close()
+
+    info, err := f.Stat()
+    if err != nil {
+        return 0, 0, 0, err
+    }
+    size = info.Size()
+
+    end = start
+    for {
+        n, _, err := spool.readRecord(f, end)
+        if err != nil {
+            return cnt, end, size, nil
+        }
+        cnt++
+        end += n
+    }
+}
+
+// readRecord reads the record at off and returns its length on the disk and its payload
+func (spool *logSpool) readRecord(f *os.File, off int64) (int64, []byte, error) {
+    if _, err := f.ReadAt(spool.header[:], off); err != nil {
+        return 0, nil, err
+    }
+    length := binary.BigEndian.Uint32(spool.header[:4])
+    sum := binary.BigEndian.Uint32(spool.header[4:])
+    if int64(length) > spool.options.SegmentSize {
+        return 0, nil, errSpoolRecord
+    }
+
+    if cap(spool.buf) < int(length) {
+        spool.buf = make([]byte, length)
+    }
+    data := spool.buf[:length]
+    if _, err := f.ReadAt(data, off + spoolRecordHeaderLen); err != nil {
+        return 0, nil, err
+    }
+    if crc32.Checksum(data, spoolTable) != sum {
+        return 0, nil, errSpoolRecord
+    }
+    return spoolRecordHeaderLen + int64(length), data, nil
+}
+
+func (spool *logSpool) readAck() (uint64, int64) {
+    var data [spoolAckLen]byte
+    if n, _ := spool.ackFile.ReadAt(data[:], 0); n != spoolAckLen ||
+        crc32.Checksum(data[:16], spoolTable) != binary.BigEndian.Uint32(data[16:]) {
+        return 0, 0
+    }
+    return binary.BigEndian.Uint64(data[:8]), int64(binary.BigEndian.Uint64(data[8:16]))
+}
+
+func (spool *logSpool) writeAck() {
+    var data [spoolAckLen]byte
+    binary.BigEndian.PutUint64(data[:8], spool.readSeg)
+    binary.BigEndian.PutUint64(data[8:16], uint64(spool.readOff))
+    binary.BigEndian.PutUint32(data[16:], crc32.Checksum(data[:16], spoolTable))
+
+    spool.ackFile.WriteAt(data[:], 0)
+    if spool.options.Sync {
+        spool.ackFile.Sync()
+    }
+}
+
+func (spool *logSpool) push(data interface{}) {
+    defer releaseData(data)
+
+    var b []byte
+    switch d := data.(type) {
+    case *logBuffer:
+        b = d.b
+    case []byte:
+        b = d
+    case *LogEntry:
+        buf := acquireBuffer()
+        defer buf.release()
+        buf.b = d.AppendJSON(buf.b)
+        b = buf.b
+    }
+    if len(b) == 0 {
+        return
+    }
+
+    spool.Lock()
+    defer spool.Unlock()
+
+    if spool.closed {
+        return
+    }
+    if err := spool.append(b); err != nil {
+        atomic.AddUint64(&spool.dropped, 1)
+    }
+}
+
+func (spool *logSpool) append(b []byte) error {
+    length := spoolRecordHeaderLen + int64(len(b))
+    if length > spool.options.SegmentSize {
+        return errSpoolRecord
+    }
+    if spool.writeOff > 0 && spool.writeOff + length > spool.options.SegmentSize {
+        if err := spool.rotate(); err != nil {
+            return err
+        }
+    }
+
+    var header [spoolRecordHeaderLen]byte
+    binary.BigEndian.PutUint32(header[:4], uint32(len(b)))
+    binary.BigEndian.PutUint32(header[4:], crc32.Checksum(b, spoolTable))
+
+    if _, err := spool.writer.Write(header[:]); err != nil {
+        return err
+    }
+    if _, err := spool.writer.Write(b); err != nil {
+        return err
+    }
+    if spool.options.Sync {
+        spool.writer.Sync()
+    }
+
+    spool.writeOff += length
+    spool.size += length
+    spool.counts[len(spool.counts) - 1]++
+    spool.cnt++
+
+    for spool.size > spool.options.MaxSize && len(spool.segments) > 1 {
+        spool.dropOldest()
+    }
+    return nil
+}
+
+func (spool *logSpool) rotate() error {
+    id := spool.segments[len(spool.segments) - 1] + 1
+    writer, err := os.OpenFile(spool.segmentPath(id), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+    if err != nil {
+        return err
+    }
+    spool.writer.Close()
+    spool.writer = writer
+    spool.writeOff = 0
+    spool.segments = append(spool.segments, id)
+    spool.counts = append(spool.counts, 0)
+    return nil
+}
+
+// dropOldest removes the oldest segment with its records which are not acknowledged
+func (spool *logSpool) dropOldest() {
+    id := spool.segments[0]
+    if id == spool.readSeg {
+        spool.skipSegment()
+        spool.writeAck()
+        return
+    }
+
+    spool.removeSegment()
+}
+
+// skipSegment drops the records of the read segment which are not acknowledged and moves to the next segment
+func (spool *logSpool) skipSegment() {
+    cnt := spool.counts[0]
+    spool.cnt -= cnt
+    atomic.AddUint64(&spool.dropped, uint64(cnt))
+    spool.nextSegment()
+}
+
+func (spool *logSpool) removeSegment() {
+    path := spool.segmentPath(spool.segments[0])
+    if info, err := os.Stat(path); err == nil {
+        spool.size -= info.Size()
+    }
+    os.Remove(path)
+    spool.segments = spool.segments[1:]
+    spool.counts = spool.counts[1:]
+}
+
+// nextSegment moves the read position to the start of the next segment and removes the read segment
+func (spool *logSpool) nextSegment() {
+    if spool.reader != nil {
+        spool.reader.Close()
+        spool.reader = nil
+    }
+    spool.removeSegment()
+    spool.readSeg = spool.segments[0]
+    spool.readOff = 0
+    spool.pending = false
+}
+
+func (spool *logSpool) pop() interface{} {
+    spool.Lock()
+    defer spool.Unlock()
+
+    for !spool.closed && spool.cnt > 0 {
+        if spool.reader == nil {
+            reader, err := os.Open(spool.segmentPath(spool.readSeg))
+            if err != nil {
+                return nil
+            }
+            spool.reader = reader
+        }
+
+        n, data, err := spool.readRecord(spool.reader, spool.readOff)
+        if err != nil {
+            if spool.readSeg == spool.segments[len(spool.segments) - 1] {
+                if err != io.EOF {
+                    // the rest of the write segment cannot be read
+                    atomic.AddUint64(&spool.dropped, uint64(spool.cnt))
+                    spool.counts[0] = 0
+                    spool.cnt = 0
+                }
+                return nil
+            }
+            // the records after a bad record cannot be read
+            spool.skipSegment()
+            spool.writeAck()
+            continue
+        }
+
+        spool.pendingOff = spool.readOff + n
+        spool.pending = true
+        if spool.custom {
+            entry, err := ParseJSON(data)
+            if err != nil {
+                spool.ackPending()
+                atomic.AddUint64(&spool.dropped, 1)
+                continue
+            }
+            return entry
+        }
+        return append([]byte(nil), data...)
+    }
+    return nil
+}
+
+func (spool *logSpool) ack() {
+    spool.Lock()
+    defer spool.Unlock()
+
+    if !spool.closed && spool.pending {
+        spool.ackPending()
+    }
+}
+
+func (spool *logSpool) ackPending() {
+    spool.readOff = spool.pendingOff
+    spool.pending = false
+    spool.counts[0]--
+    spool.cnt--
+    spool.writeAck()
+}
+
+func (spool *logSpool) discard() {
+    spool.Lock()
+    defer spool.Unlock()
+
+    if spool.closed || spool.cnt == 0 {
+        return
+    }
+    for len(spool.segments) > 1 {
+        spool.nextSegment()
+    }
+    if spool.reader != nil {
+        spool.reader.Close()
+        spool.reader = nil
+    }
+    atomic.AddUint64(&spool.dropped, uint64(spool.cnt))
+    spool.counts[0] = 0
+    spool.cnt = 0
+    spool.readOff = spool.writeOff
+    spool.pending = false
+    spool.writeAck()
+}
+
+func (spool *logSpool) durable() bool {
+    return true
+}
+
+func (spool *logSpool) count() int {
+    spool.Lock()
+    defer spool.Unlock()
+    return spool.cnt
+}
+
+func (spool *logSpool) capacity() int {
+    return -1
+}
+
+func (spool *logSpool) setCapacity(cap int) {
+}
+
+// setReserved is a no op since the spool keeps the entries in their order
+func (spool *logSpool) setReserved(reserved int) {
+}
+
+// setOverflow is a no op since the spool drops the oldest segments when it grows over the maximum size
+func (spool *logSpool) setOverflow(policy OverflowPolicy) {
+}
+
+func (spool *logSpool) droppedCount() uint64 {
+    return atomic.LoadUint64(&spool.dropped)
+}
+
+func (spool *logSpool) close() error {
+    spool.Lock()
+    defer spool.Unlock()
+
+    spool.closed = true
+    var err error
+    for _, f := range []*os.File{ spool.writer, spool.reader, spool.ackFile } {
+        if f != nil {
+            if e := f.Close(); e != nil && err == nil {
+                err = e
+            }
+        }
+    }
+    spool.writer, spool.reader, spool.ackFile = nil, nil, nil
+    return err
+}
@@ -0,0 +1,147 @@
+//	The MIT License (MIT)
+//
+//	Copyright (c) 2016, Cagatay Dogan
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//		The above copyright notice and this permission notice shall be included in
+//		all copies or substantial portions of the Software.
+//
+//		THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//		IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//		FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//		AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//		LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//		OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+//		THE SOFTWARE.
+
+package logmanager
+
+import (
+    "fmt"
+    "os"
+    "path/filepath"
+    "testing"
+)
+
+func TestLogSpoolReplay(t *testing.T) {
+    fmt.Println("\nTestLogSpoolReplay\n~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~")
+
+    options := SpoolOptions{ Dir: t.TempDir(), SegmentSize: 64 }
+    spool, err := openSpool(options, false)
+    if err != nil {
+        t.Fatal(err)
+    }
+    for i := 0; i < 10; i++ {
+        spool.push([]byte(fmt.Sprintf(`{"n":%d}`, i)))
+    }
+    for i := 0; i < 3; i++ {
+        spool.pop()
+        spool.ack()
+    }
+    if data := spool.pop(); string(data.([]byte)) != `{"n":3}` {
+        t.Fatalf("unexpected record %s", data)
+    }
+    spool.close()
+
+    // a record torn by a crash is truncated
+    segments, _ := filepath.Glob(filepath.Join(options.Dir, "*" + spoolSegmentExt))
+    f, err := os.OpenFile(segments[len(segments) - 1], os.O_WRONLY|os.O_APPEND, 0600)
+    if err != nil {
+        t.Fatal(err)
+    }
+    f.Write([]byte{ 0, 0, 0, 9, 1, 2 })
+    f.Close()
+
+    if spool, err = openSpool(options, false); err != nil {
+        t.Fatal(err)
+    }
+    defer spool.close()
+
+    if spool.count() != 7 {
+        t.Fatalf("expected 7 records to replay, got %d", spool.count())
+    }
+    for i := 3; i < 10; i++ {
+        data := spool.pop()
+        if expected := fmt.Sprintf(`{"n":%d}`, i); data == nil || string(data.([]byte)) != expected {
+            t.Fatalf("expected %s, got %s", expected, data)
+        }
+        spool.ack()
+    }
+    if spool.pop() != nil || spool.count() != 0 {
+        t.Error("expected an empty spool")
+    }
+
+    if segments, _ = filepath.Glob(filepath.Join(options.Dir, "*" + spoolSegmentExt)); len(segments) != 1 {
+        t.Errorf("expected the acknowledged segments to be removed, got %v", segments)
+    }
+}
+
+func TestLogSpoolBadRecord(t *testing.T) {
+    options := SpoolOptions{ Dir: t.TempDir(), SegmentSize: 64 }
+    spool, err := openSpool(options, false)
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer spool.close()
+
+    // 4 records of 15 bytes fit in a segment
+    for i := 0; i < 10; i++ {
+        spool.push([]byte(fmt.Sprintf(`{"n":%d}`, i)))
+    }
+
+    segments, _ := filepath.Glob(filepath.Join(options.Dir, "*" + spoolSegmentExt))
+    f, err := os.OpenFile(segments[0], os.O_WRONLY, 0600)
+    if err != nil {
+        t.Fatal(err)
+    }
+    f.WriteAt([]byte("x"), 15 + spoolRecordHeaderLen)
+    f.Close()
+
+    if data := spool.pop(); data == nil || string(data.([]byte)) != `{"n":0}` {
+        t.Fatalf("unexpected record %s", data)
+    }
+    spool.ack()
+
+    // the rest of the first segment is skipped
+    if data := spool.pop(); data == nil || string(data.([]byte)) != `{"n":4}` {
+        t.Fatalf("unexpected record %s", data)
+    }
+    if spool.count() != 6 || spool.droppedCount() != 3 {
+        t.Fatalf("expected 6 queued and 3 dropped, got %d queued %d dropped", spool.count(), spool.droppedCount())
+    }
+    spool.ack()
+
+    for i := 5; i < 10; i++ {
+        spool.pop()
+        spool.ack()
+    }
+    if spool.pop() != nil || spool.count() != 0 {
+        t.Errorf("expected an empty spool, got %d queued", spool.count())
+    }
+}
+
+func TestLogSpoolLimits(t *testing.T) {
+    spool, err := openSpool(SpoolOptions{ Dir: t.TempDir(), SegmentSize: 512, MaxSize: 1024 }, true)
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer spool.close()
+
+    for i := 0; i < 40; i++ {
+        spool.push(NewInfoLogEntry(fmt.Sprint(i), nil))
+    }
+    if spool.droppedCount() == 0 || spool.count() + int(spool.droppedCount()) != 40 {
+        t.Fatalf("expected the oldest records to be dropped, got %d queued %d dropped", spool.count(), spool.droppedCount())
+    }
+
+    entry, ok := spool.pop().(*LogEntry)
+    if !ok || entry.Message() != fmt.Sprint(spool.droppedCount()) {
+        t.Errorf("expected the first record kept as an entry, got %v", entry)
+    }
+}
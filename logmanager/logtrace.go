@@ -0,0 +1,211 @@
+//	The MIT License (MIT)
+//
+//	Copyright (c) 2016, Cagatay Dogan
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//		The above copyright notice and this permission notice shall be included in
+//		all copies or substantial portions of the Software.
+//
+//		THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//		IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//		FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//		AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//		LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//		OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+//		THE SOFTWARE.
+
+package logmanager
+
+import (
+    "context"
+    "encoding/hex"
+    "fmt"
+    "sync/atomic"
+)
+
+const (
+    // TraceFlagsSampled is the W3C trace flag set when the trace is sampled
+    TraceFlagsSampled = byte(0x01)
+)
+
+var (
+    traceExtractor atomic.Value
+)
+
+type traceContextKey struct{}
+
+// TraceID is the W3C trace id an entry is correlated with
+type TraceID [16]byte
+
+// SpanID is the W3C span id an entry is correlated with
+type SpanID [8]byte
+
+// IsValid returns if the id is not all zeros
+func (id TraceID) IsValid() bool {
+    return id != TraceID{}
+}
+
+// String returns the id as 32 lower case hex digits
+func (id TraceID) String() string {
+    return hex.EncodeToString(id[:])
+}
+
+// IsValid returns if the id is not all zeros
+func (id SpanID) IsValid() bool {
+    return id != SpanID{}
+}
+
+// String returns the id as 16 lower case hex digits
+func (id SpanID) String() string {
+    return hex.EncodeToString(id[:])
+}
+
+// TraceContext identifies the trace and the span an entry is logged in
+type TraceContext struct {
+    TraceID TraceID
+    SpanID SpanID
+    Flags byte
+}
+
+// IsValid returns if the trace id is set, the span id is optional
+func (tc TraceContext) IsValid() bool {
+    return tc.TraceID.IsValid()
+}
+
+// Sampled returns if the sampled flag is set
+func (tc TraceContext) Sampled() bool {
+    return tc.Flags & TraceFlagsSampled != 0
+}
+
+// TraceParent returns the trace context as a W3C traceparent header value
+func (tc TraceContext) TraceParent() string {
+    return fmt.Sprintf("00-%s-%s-%02x", tc.TraceID, tc.SpanID, tc.Flags)
+}
+
+// ParseTraceParent parses a W3C traceparent header value like 
+// 00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01
+func ParseTraceParent(header string) (TraceContext, error) {
+    var tc TraceContext
+    if len(header) < 55 || header[2] != '-' || header[35] != '-' || header[52] != '-' ||
+        (len(header) > 55 && header[55] != '-') || header[:2] == "ff" {
+        return tc, fmt.Errorf("logmanager: invalid traceparent %q", header)
+    }
+    
+    var flags [1]byte
+    if _, err := hex.Decode(tc.TraceID[:], []byte(header[3:35])); err != nil {
+        return tc, fmt.Errorf("logmanager: invalid trace id in traceparent %q", header)
+    }
+    if _, err := hex.Decode(tc.SpanID[:], []byte(header[36:52])); err != nil {
+        return tc, fmt.Errorf("logmanager: invalid span id in traceparent %q", header)
+    }
+    if _, err := hex.Decode(flags[:], []byte(header[53:55])); err != nil {
+        return tc, fmt.Errorf("logmanager: invalid trace flags in traceparent %q", header)
+    }
+    tc.Flags = flags[0]
+    
+    if !tc.TraceID.IsValid() || !tc.SpanID.IsValid() {
+        return tc, fmt.Errorf("logmanager: invalid traceparent %q", header)
+    }
+    return tc, nil
+}
+
+// ContextWithTrace returns a copy of the context carrying the trace context
+func ContextWithTrace(ctx context.Context, tc TraceContext) context.Context {
+    return context.WithValue(ctx, traceContextKey{}, tc)
+}
+
+// SetTraceExtractor sets the function which gets the trace context from a context,
+// so the spans of a tracing library can be used without carrying them with ContextWithTrace.
+// The trace context added by ContextWithTrace is used if the extractor finds nothing, nil removes the extractor.
+func SetTraceExtractor(extractor func(ctx context.Context) (TraceContext, bool)) {
+    traceExtractor.Store(extractor)
+}
+
+// TraceFromContext returns the trace context carried by the context
+func TraceFromContext(ctx context.Context) (TraceContext, bool) {
+    if ctx == nil {
+        return TraceContext{}, false
+    }
+    if extractor, _ := traceExtractor.Load().(func(ctx context.Context) (TraceContext, bool)); extractor != nil {
+        if tc, ok := extractor(ctx); ok && tc.IsValid() {
+            return tc, true
+        }
+    }
+    tc, ok := ctx.Value(traceContextKey{}).(TraceContext)
+    return tc, ok && tc.IsValid()
+}
+
+// Trace returns the trace context the entry is correlated with
+func (entry *LogEntry) Trace() TraceContext {
+    return entry.trace
+}
+
+// SetTrace correlates the entry with the given trace context
+func (entry *LogEntry) SetTrace(tc TraceContext) {
+    entry.trace = tc
+}
+
+// SetTraceFromContext correlates the entry with the trace context carried by the context,
+// returns false if there is no trace context
+func (entry *LogEntry) SetTraceFromContext(ctx context.Context) bool {
+    tc, ok := TraceFromContext(ctx)
+    if ok {
+        entry.trace = tc
+    }
+    return ok
+}
+
+// appendHex appends the bytes as lower case hex digits
+func appendHex(dst []byte, b []byte) []byte {
+    for _, c := range b {
+        dst = append(dst, hexDigits[c >> 4], hexDigits[c & 0x0f])
+    }
+    return dst
+}
+
+// appendJSONTrace appends the trace fields of the entry to a JSON object
+func (entry *LogEntry) appendJSONTrace(dst []byte) []byte {
+    dst = append(dst, `,"trace_id":"`...)
+    dst = appendHex(dst, entry.trace.TraceID[:])
+    if entry.trace.SpanID.IsValid() {
+        dst = append(dst, `","span_id":"`...)
+        dst = appendHex(dst, entry.trace.SpanID[:])
+    }
+    dst = append(dst, `","trace_flags":"`...)
+    dst = appendHex(dst, []byte{ entry.trace.Flags })
+    return append(dst, '"')
+}
+
+// setTraceField sets the trace field of the entry parsed from a JSON or a text line
+func (entry *LogEntry) setTraceField(name, value string) error {
+    var err error
+    switch name {
+    case "trace_id":
+        err = decodeHexID(entry.trace.TraceID[:], value)
+    case "span_id":
+        err = decodeHexID(entry.trace.SpanID[:], value)
+    case "trace_flags":
+        var flags [1]byte
+        if err = decodeHexID(flags[:], value); err == nil {
+            entry.trace.Flags = flags[0]
+        }
+    }
+    if err != nil {
+        return fmt.Errorf("logmanager: invalid %s %q", name, value)
+    }
+    return nil
+}
+
+func decodeHexID(dst []byte, value string) error {
+    if len(value) != 2*len(dst) {
+        return hex.ErrLength
+    }
+    _, err := hex.Decode(dst, []byte(value))
+    return err
+}
@@ -0,0 +1,163 @@
+//	The MIT License (MIT)
+//
+//	Copyright (c) 2016, Cagatay Dogan
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//		The above copyright notice and this permission notice shall be included in
+//		all copies or substantial portions of the Software.
+//
+//		THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//		IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//		FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//		AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//		LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//		OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+//		THE SOFTWARE.
+
+package logmanager
+
+import (
+    "context"
+    "fmt"
+    "log/slog"
+    "strings"
+    "testing"
+)
+
+var testTrace = TraceContext{
+    TraceID: TraceID{ 0x4b, 0xf9, 0x2f, 0x35, 0x77, 0xb3, 0x4d, 0xa6, 0xa3, 0xce, 0x92, 0x9d, 0x0e, 0x0e, 0x47, 0x36 },
+    SpanID: SpanID{ 0x00, 0xf0, 0x67, 0xaa, 0x0b, 0xa9, 0x02, 0xb7 },
+    Flags: TraceFlagsSampled,
+}
+
+func TestParseTraceParent(t *testing.T) {
+    fmt.Println("\nTestParseTraceParent\n~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~")
+
+    header := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+    tc, err := ParseTraceParent(header)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if tc != testTrace || !tc.Sampled() || tc.TraceParent() != header {
+        t.Errorf("unexpected trace context %+v", tc)
+    }
+
+    for _, invalid := range []string{
+        "",
+        "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+        "00-4bf92f3577b34da6a3ce929d0e0e473x-00f067aa0ba902b7-01",
+        "00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+        "00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+        "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+        "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01x",
+    } {
+        if _, err := ParseTraceParent(invalid); err == nil {
+            t.Errorf("expected an error for %q", invalid)
+        }
+    }
+}
+
+func TestTraceFromContext(t *testing.T) {
+    if _, ok := TraceFromContext(context.Background()); ok {
+        t.Error("expected no trace context")
+    }
+
+    ctx := ContextWithTrace(context.Background(), testTrace)
+    entry := NewInfoLogEntry("traced", nil)
+    if !entry.SetTraceFromContext(ctx) || entry.Trace() != testTrace {
+        t.Errorf("expected the trace context of the context, got %+v", entry.Trace())
+    }
+
+    // the extractor of a tracing library is asked first
+    other := testTrace
+    other.Flags = 0
+    SetTraceExtractor(func(ctx context.Context) (TraceContext, bool) {
+        return other, true
+    })
+    defer SetTraceExtractor(nil)
+
+    if tc, ok := TraceFromContext(ctx); !ok || tc != other {
+        t.Errorf("expected the extracted trace context, got %+v", tc)
+    }
+}
+
+func TestTraceEncoding(t *testing.T) {
+    entry := NewInfoLogEntry("traced", map[string]interface{}{ "key": "value" })
+    entry.SetTrace(testTrace)
+
+    json := string(entry.ToJSON())
+    if !strings.Contains(json, `"trace_id":"4bf92f3577b34da6a3ce929d0e0e4736","span_id":"00f067aa0ba902b7","trace_flags":"01"`) {
+        t.Errorf("expected the trace fields in %s", json)
+    }
+    parsed, err := ParseJSON([]byte(json))
+    if err != nil {
+        t.Fatal(err)
+    }
+    if parsed.Trace() != testTrace {
+        t.Errorf("expected the trace context to survive JSON, got %+v", parsed.Trace())
+    }
+
+    text := string(entry.ToText())
+    if !strings.Contains(text, " trace_id=4bf92f3577b34da6a3ce929d0e0e4736 span_id=00f067aa0ba902b7 trace_flags=01 ") {
+        t.Errorf("expected the trace fields in %s", text)
+    }
+    parsed, err = ParseText([]byte(text))
+    if err != nil {
+        t.Fatal(err)
+    }
+    if parsed.Trace() != testTrace {
+        t.Errorf("expected the trace context to survive text, got %+v", parsed.Trace())
+    }
+
+    if gelf := string(entry.AppendGELF(nil)); !strings.Contains(gelf, `"_trace_id":"4bf92f3577b34da6a3ce929d0e0e4736","_span_id":"00f067aa0ba902b7"`) {
+        t.Errorf("expected the trace fields in %s", gelf)
+    }
+    if ecs := string(entry.AppendECS(nil)); !strings.Contains(ecs, `"trace":{"id":"4bf92f3577b34da6a3ce929d0e0e4736"},"span":{"id":"00f067aa0ba902b7"}`) {
+        t.Errorf("expected the trace fields in %s", ecs)
+    }
+
+    if _, err = ParseJSON([]byte(`{"level":"info","trace_id":"4bf9"}`)); err == nil {
+        t.Error("expected an error for a short trace id")
+    }
+}
+
+func TestSlogHandlerTrace(t *testing.T) {
+    handler := &captureLogHandler{ discardLogHandler: discardLogHandler{ name: "slog-trace", format: JSONFormat } }
+    RegisterHandler(handler)
+
+    logger := slog.New(NewSlogHandler(nil))
+    logger.InfoContext(ContextWithTrace(context.Background(), testTrace), "slog traced")
+
+    UnregisterHandler("slog-trace")
+    lines := handler.captured()
+    if len(lines) != 1 || !strings.Contains(lines[0], `"trace_id":"4bf92f3577b34da6a3ce929d0e0e4736"`) {
+        t.Errorf("expected the traced slog record, got %q", lines)
+    }
+}
+
+func TestLoggerWithContext(t *testing.T) {
+    handler := &captureLogHandler{ discardLogHandler: discardLogHandler{ name: "logger-trace", format: JSONFormat } }
+    RegisterHandler(handler)
+
+    logger := GetLogger("trace.orders")
+    logger.WithContext(ContextWithTrace(context.Background(), testTrace)).LogMessage("context traced", nil)
+    logger.LogMessage("not traced", nil)
+
+    UnregisterHandler("logger-trace")
+    lines := handler.captured()
+    if len(lines) != 2 {
+        t.Fatalf("expected 2 entries, got %q", lines)
+    }
+    for _, line := range lines {
+        traced := strings.Contains(line, `"trace_id":"4bf92f3577b34da6a3ce929d0e0e4736"`)
+        if !strings.Contains(line, `"category":"trace.orders"`) || traced != strings.Contains(line, "context traced") {
+            t.Errorf("unexpected entry %s", line)
+        }
+    }
+}
@@ -0,0 +1,380 @@
+//	The MIT License (MIT)
+//
+//	Copyright (c) 2016, Cagatay Dogan
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//		The above copyright notice and this permission notice shall be included in
+//		all copies or substantial portions of the Software.
+//
+//		THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//		IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//		FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//		AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//		LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//		OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+//		THE SOFTWARE.
+
+package logmanager
+
+import (
+    "crypto/tls"
+    "encoding/binary"
+    "errors"
+    "net"
+    "sync"
+    "sync/atomic"
+    "time"
+)
+
+// Framing defines how the records are delimited on a stream
+type Framing byte
+
+const (
+    // NewlineFraming terminates every record with a new line, as expected by the NDJSON inputs
+    NewlineFraming Framing = iota
+    // LengthPrefixFraming prefixes every record with its length as a 4 byte big endian integer
+    LengthPrefixFraming
+)
+
+const (
+    defaultNetDialTimeout = 5*time.Second
+    defaultNetWriteTimeout = 5*time.Second
+    defaultNetMinBackoff = 100*time.Millisecond
+    defaultNetMaxBackoff = 30*time.Second
+    defaultNetSpillSize = 4*1024*1024
+)
+
+var (
+    // ErrNotConnected is returned when a record cannot be sent since the destination is not reachable
+    ErrNotConnected = errors.New("logmanager: not connected")
+)
+
+// NetLogHandlerOptions are used to configure a NetLogHandler
+type NetLogHandlerOptions struct {
+    // Name is the name of the handler used for registration, "net" if empty
+    Name string
+    // Network is one of tcp, tcp4, tcp6, udp, udp4, udp6, unix or unixgram
+    Network string
+    // Address is the address of the collector, the socket path for unix networks
+    Address string
+    // TLSConfig enables TLS for the stream networks if set
+    TLSConfig *tls.Config
+    // Framing defines how the records are delimited
+    Framing Framing
+    // Format is JSONFormat or TextFormat, JSONFormat by default
+    Format LogFormat
+    // Level is the set of levels sent, all levels if 0
+    Level LogLevel
+    // DialTimeout limits the connection time
+    DialTimeout time.Duration
+    // WriteTimeout limits the time of writing a record
+    WriteTimeout time.Duration
+    // MinBackoff is the first wait after a failed connection, doubled on every failure
+    MinBackoff time.Duration
+    // MaxBackoff limits the wait between the connection attempts
+    MaxBackoff time.Duration
+    // SpillSize limits the bytes of the records buffered while disconnected,
+    // the oldest records are dropped when it is exceeded
+    SpillSize int
+}
+
+// NetLogHandler streams the entries to a collector like Fluent Bit or Vector over
+// TCP, UDP or a unix socket. The connection is reestablished with an exponential backoff
+// and the records are kept in a bounded spill buffer while disconnected.
+type NetLogHandler struct {
+    sync.Mutex
+    disabled uint32
+    options NetLogHandlerOptions
+    conn net.Conn
+    backoff time.Duration
+    nextDial time.Time
+    spill [][]byte
+    spillSize int
+    dropped uint64
+    frame []byte
+    err error
+}
+
+// NewNetLogHandler creates a network handler, the connection is made with the first entry
+func NewNetLogHandler(options NetLogHandlerOptions) *NetLogHandler {
+    if options.Name == "" {
+        options.Name = "net"
+    }
+    if options.Network == "" {
+        options.Network = "tcp"
+    }
+    if options.Format != TextFormat {
+        options.Format = JSONFormat
+    }
+    if options.DialTimeout <= 0 {
+        options.DialTimeout = defaultNetDialTimeout
+    }
+    if options.WriteTimeout <= 0 {
+        options.WriteTimeout = defaultNetWriteTimeout
+    }
+    if options.MinBackoff <= 0 {
+        options.MinBackoff = defaultNetMinBackoff
+    }
+    if options.MaxBackoff < options.MinBackoff {
+        options.MaxBackoff = defaultNetMaxBackoff
+        if options.MaxBackoff < options.MinBackoff {
+            options.MaxBackoff = options.MinBackoff
+        }
+    }
+    if options.SpillSize <= 0 {
+        options.SpillSize = defaultNetSpillSize
+    }
+
+    return &NetLogHandler{
+        options: options,
+    }
+}
+
+// Name returns the name of the handler used for registration
+func (handler *NetLogHandler) Name() string {
+    return handler.options.Name
+}
+
+// Enabled returns if the handler is active
+func (handler *NetLogHandler) Enabled() bool {
+    return atomic.LoadUint32(&handler.disabled) == falseUint32
+}
+
+// Enable activates the handler
+func (handler *NetLogHandler) Enable() {
+    atomic.StoreUint32(&handler.disabled, falseUint32)
+}
+
+// Disable deactivates the handler
+func (handler *NetLogHandler) Disable() {
+    atomic.StoreUint32(&handler.disabled, trueUint32)
+}
+
+// Level gives if the pushed entry should be logged by the handler
+func (handler *NetLogHandler) Level() LogLevel {
+    return handler.options.Level
+}
+
+// Format gives the format that will be used by the handler
+func (handler *NetLogHandler) Format() LogFormat {
+    return handler.options.Format
+}
+
+// QueueLen gives the queue length that will be used when the entry is queued
+func (handler *NetLogHandler) QueueLen() int {
+    return -1
+}
+
+// Connected returns if the handler has a live connection
+func (handler *NetLogHandler) Connected() bool {
+    handler.Lock()
+    defer handler.Unlock()
+    return handler.conn != nil
+}
+
+// Dropped returns the number of the records dropped since the spill buffer was full
+func (handler *NetLogHandler) Dropped() uint64 {
+    return atomic.LoadUint64(&handler.dropped)
+}
+
+// Err returns the last connection or write error
+func (handler *NetLogHandler) Err() error {
+    handler.Lock()
+    defer handler.Unlock()
+    return handler.err
+}
+
+// Healthy reconnects without waiting for the backoff if the handler is not connected 
+// and sends the spilled records, the connection or write error is returned if it fails
+func (handler *NetLogHandler) Healthy() error {
+    handler.Lock()
+    defer handler.Unlock()
+    
+    if handler.conn == nil {
+        handler.nextDial = time.Time{}
+    }
+    if handler.connect() && handler.flushSpill() {
+        return nil
+    }
+    if handler.err == nil {
+        return ErrNotConnected
+    }
+    return handler.err
+}
+
+// Process evaluates the given entry
+func (handler *NetLogHandler) Process(entry interface{}) {
+    handler.TryProcess(entry)
+}
+
+// TryProcess evaluates the given entry like Process and returns the connection or write error, 
+// the record is kept in the spill buffer when it cannot be sent
+func (handler *NetLogHandler) TryProcess(entry interface{}) error {
+    if data, ok := entry.([]byte); ok && len(data) > 0 {
+        handler.Lock()
+        defer handler.Unlock()
+
+        return handler.send(data)
+    }
+    return nil
+}
+
+// Flush tries to send the records waiting in the spill buffer
+func (handler *NetLogHandler) Flush() error {
+    handler.Lock()
+    defer handler.Unlock()
+
+    if handler.connect() {
+        handler.flushSpill()
+    }
+    if len(handler.spill) > 0 {
+        return ErrNotConnected
+    }
+    return nil
+}
+
+// Close closes the connection, the records in the spill buffer are discarded
+func (handler *NetLogHandler) Close() error {
+    handler.Lock()
+    defer handler.Unlock()
+
+    handler.spill, handler.spillSize = nil, 0
+    if handler.conn == nil {
+        return nil
+    }
+    err := handler.conn.Close()
+    handler.conn = nil
+    return err
+}
+
+func (handler *NetLogHandler) send(data []byte) error {
+    if handler.connect() && handler.flushSpill() {
+        if handler.write(data) == nil {
+            return nil
+        }
+    }
+    handler.pushSpill(data)
+    
+    if handler.err == nil {
+        return ErrNotConnected
+    }
+    return handler.err
+}
+
+func (handler *NetLogHandler) connect() bool {
+    if handler.conn != nil {
+        return true
+    }
+
+    now := time.Now()
+    if now.Before(handler.nextDial) {
+        return false
+    }
+
+    dialer := &net.Dialer{ Timeout: handler.options.DialTimeout }
+
+    var conn net.Conn
+    var err error
+    if handler.options.TLSConfig != nil && !handler.datagram() {
+        conn, err = tls.DialWithDialer(dialer, handler.options.Network, handler.options.Address, handler.options.TLSConfig)
+    } else {
+        conn, err = dialer.Dial(handler.options.Network, handler.options.Address)
+    }
+
+    if err != nil {
+        handler.fail(err)
+        return false
+    }
+
+    handler.conn = conn
+    handler.backoff = 0
+    handler.err = nil
+    return true
+}
+
+func (handler *NetLogHandler) datagram() bool {
+    switch handler.options.Network {
+    case "udp", "udp4", "udp6", "unixgram":
+        return true
+    }
+    return false
+}
+
+// fail drops the connection and schedules the next connection attempt
+func (handler *NetLogHandler) fail(err error) {
+    handler.err = err
+    if handler.conn != nil {
+        handler.conn.Close()
+        handler.conn = nil
+    }
+
+    if handler.backoff == 0 {
+        handler.backoff = handler.options.MinBackoff
+    } else {
+        handler.backoff *= 2
+        if handler.backoff > handler.options.MaxBackoff {
+            handler.backoff = handler.options.MaxBackoff
+        }
+    }
+    handler.nextDial = time.Now().Add(handler.backoff)
+}
+
+func (handler *NetLogHandler) write(data []byte) error {
+    frame := handler.frame[:0]
+    switch handler.options.Framing {
+    case LengthPrefixFraming:
+        frame = binary.BigEndian.AppendUint32(frame, uint32(len(data)))
+        frame = append(frame, data...)
+    default:
+        frame = append(frame, data...)
+        if data[len(data)-1] != '\n' {
+            frame = append(frame, '\n')
+        }
+    }
+    handler.frame = frame
+
+    handler.conn.SetWriteDeadline(time.Now().Add(handler.options.WriteTimeout))
+    if _, err := handler.conn.Write(frame); err != nil {
+        handler.fail(err)
+        return err
+    }
+    return nil
+}
+
+// flushSpill sends the spilled records in order and returns if all of them are sent
+func (handler *NetLogHandler) flushSpill() bool {
+    for len(handler.spill) > 0 {
+        data := handler.spill[0]
+        if handler.write(data) != nil {
+            return false
+        }
+        handler.spill[0] = nil
+        handler.spill = handler.spill[1:]
+        handler.spillSize -= len(data)
+    }
+    handler.spill = nil
+    return true
+}
+
+func (handler *NetLogHandler) pushSpill(data []byte) {
+    if len(data) > handler.options.SpillSize {
+        atomic.AddUint64(&handler.dropped, 1)
+        return
+    }
+
+    for len(handler.spill) > 0 && handler.spillSize + len(data) > handler.options.SpillSize {
+        handler.spillSize -= len(handler.spill[0])
+        handler.spill[0] = nil
+        handler.spill = handler.spill[1:]
+        atomic.AddUint64(&handler.dropped, 1)
+    }
+
+    handler.spill = append(handler.spill, append([]byte(nil), data...))
+    handler.spillSize += len(data)
+}
@@ -0,0 +1,244 @@
+//	The MIT License (MIT)
+//
+//	Copyright (c) 2016, Cagatay Dogan
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//		The above copyright notice and this permission notice shall be included in
+//		all copies or substantial portions of the Software.
+//
+//		THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//		IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//		FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//		AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//		LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//		OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+//		THE SOFTWARE.
+
+package logmanager
+
+import (
+    "bufio"
+    "context"
+    "encoding/binary"
+    "errors"
+    "fmt"
+    "io"
+    "net"
+    "path/filepath"
+    "testing"
+    "time"
+)
+
+func TestNetLogHandlerReconnect(t *testing.T) {
+    fmt.Println("\nTestNetLogHandlerReconnect\n~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~")
+
+    path := filepath.Join(t.TempDir(), "collector.sock")
+    handler := NewNetLogHandler(NetLogHandlerOptions{
+        Network: "unix",
+        Address: path,
+        MinBackoff: time.Millisecond,
+        MaxBackoff: 10*time.Millisecond,
+        SpillSize: 20,
+    })
+    defer handler.Close()
+
+    // nothing listens yet, the records are spilled and the oldest one is dropped
+    handler.Process([]byte(`{"n":1}`))
+    handler.Process([]byte(`{"n":2}`))
+    handler.Process([]byte(`{"n":3}`))
+    if handler.Connected() || handler.Dropped() != 1 {
+        t.Fatalf("expected a disconnected handler with 1 dropped record, got %v %d", handler.Connected(), handler.Dropped())
+    }
+
+    listener, err := net.Listen("unix", path)
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer listener.Close()
+
+    lines := make(chan string, 10)
+    go func() {
+        conn, err := listener.Accept()
+        if err != nil {
+            return
+        }
+        defer conn.Close()
+        scanner := bufio.NewScanner(conn)
+        for scanner.Scan() {
+            lines <- scanner.Text()
+        }
+    }()
+
+    time.Sleep(20*time.Millisecond)
+    handler.Process([]byte(`{"n":4}`))
+
+    for _, expected := range []string{ `{"n":2}`, `{"n":3}`, `{"n":4}` } {
+        select {
+        case line := <-lines:
+            if line != expected {
+                t.Errorf("expected %s, got %s", expected, line)
+            }
+        case <-time.After(2*time.Second):
+            t.Fatalf("timeout waiting for %s", expected)
+        }
+    }
+}
+
+func TestNetLogHandlerLengthPrefix(t *testing.T) {
+    listener, err := net.Listen("tcp", "127.0.0.1:0")
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer listener.Close()
+
+    handler := NewNetLogHandler(NetLogHandlerOptions{
+        Network: "tcp",
+        Address: listener.Addr().String(),
+        Framing: LengthPrefixFraming,
+    })
+    defer handler.Close()
+
+    handler.Process([]byte("line\nwith new line"))
+
+    conn, err := listener.Accept()
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer conn.Close()
+    conn.SetReadDeadline(time.Now().Add(2*time.Second))
+
+    var size uint32
+    if err = binary.Read(conn, binary.BigEndian, &size); err != nil {
+        t.Fatal(err)
+    }
+    data := make([]byte, size)
+    if _, err = io.ReadFull(conn, data); err != nil {
+        t.Fatal(err)
+    }
+    if string(data) != "line\nwith new line" {
+        t.Errorf("unexpected record %q", data)
+    }
+}
+
+// listenLines accepts the connections on the listener and sends the received lines to the channel
+func listenLines(listener net.Listener, lines chan<- string, conns chan<- net.Conn) {
+    for {
+        conn, err := listener.Accept()
+        if err != nil {
+            return
+        }
+        conns <- conn
+        go func() {
+            scanner := bufio.NewScanner(conn)
+            for scanner.Scan() {
+                lines <- scanner.Text()
+            }
+        }()
+    }
+}
+
+func expectLines(t *testing.T, lines <-chan string, expected ...string) {
+    for _, e := range expected {
+        select {
+        case line := <-lines:
+            if line != e {
+                t.Errorf("expected %s, got %s", e, line)
+            }
+        case <-time.After(2*time.Second):
+            t.Fatalf("timeout waiting for %s", e)
+        }
+    }
+}
+
+func TestNetLogHandlerCircuit(t *testing.T) {
+    fmt.Println("\nTestNetLogHandlerCircuit\n~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~")
+
+    SetCircuitBreakerOptions(CircuitBreakerOptions{ Threshold: 2, ProbeInterval: 20*time.Millisecond })
+    defer SetCircuitBreakerOptions(DefaultCircuitBreakerOptions())
+
+    path := filepath.Join(t.TempDir(), "collector.sock")
+    listener, err := net.Listen("unix", path)
+    if err != nil {
+        t.Fatal(err)
+    }
+    lines := make(chan string, 10)
+    conns := make(chan net.Conn, 2)
+    go listenLines(listener, lines, conns)
+
+    handler := NewNetLogHandler(NetLogHandlerOptions{
+        Name: "net-circuit",
+        Network: "unix",
+        Address: path,
+        MinBackoff: time.Millisecond,
+        MaxBackoff: time.Millisecond,
+    })
+    defer handler.Close()
+
+    bucket := newBucket(handler, HandlerOptions{})
+    bucketMtx.Lock()
+    buckets["net-circuit"] = bucket
+    bucketMtx.Unlock()
+    defer func() {
+        bucketMtx.Lock()
+        delete(buckets, "net-circuit")
+        bucketMtx.Unlock()
+    }()
+
+    bucket.queue.push([]byte(`{"n":1}`))
+    bucket.drain(context.Background())
+    expectLines(t, lines, `{"n":1}`)
+
+    // the collector goes away, two failures open the circuit and the last entry is buffered
+    listener.Close()
+    (<-conns).Close()
+
+    for i := 2; i <= 4; i++ {
+        bucket.queue.push([]byte(fmt.Sprintf(`{"n":%d}`, i)))
+    }
+    bucket.drain(context.Background())
+    if stats := bucket.stats("net-circuit"); stats.Circuit != "open" || stats.Queued != 1 {
+        t.Fatalf("expected an open circuit with 1 queued entry, got %+v", stats)
+    }
+    if err := HealthCheck()["net-circuit"]; !errors.Is(err, ErrCircuitOpen) {
+        t.Errorf("expected the handler to be unhealthy, got %v", err)
+    }
+
+    // the probe redials and fails while the collector is down
+    time.Sleep(30*time.Millisecond)
+    bucket.drain(context.Background())
+    if state, _, _ := bucket.breaker.status(); state != CircuitOpen || bucket.queue.count() != 1 {
+        t.Fatalf("expected the failed probe to keep the circuit open, got %v", state)
+    }
+
+    // the collector comes back, the probe reconnects and sends the spilled records
+    if listener, err = net.Listen("unix", path); err != nil {
+        t.Fatal(err)
+    }
+    defer listener.Close()
+    go listenLines(listener, lines, conns)
+
+    time.Sleep(30*time.Millisecond)
+    options := CurrentCircuitBreakerOptions()
+    if !bucket.breaker.allow(handler, options) {
+        t.Fatal("expected the probe to pass")
+    }
+    if state, _, _ := bucket.breaker.status(); state != CircuitHalfOpen {
+        t.Fatalf("expected a half-open circuit after the probe, got %v", state)
+    }
+    expectLines(t, lines, `{"n":2}`, `{"n":3}`)
+
+    bucket.drain(context.Background())
+    if stats := bucket.stats("net-circuit"); stats.Circuit != "closed" || stats.Queued != 0 {
+        t.Errorf("expected the delivered entry to close the circuit, got %+v", stats)
+    }
+    expectLines(t, lines, `{"n":4}`)
+    if err := HealthCheck()["net-circuit"]; err != nil {
+        t.Errorf("expected the handler to be healthy, got %v", err)
+    }
+}
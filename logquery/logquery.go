@@ -0,0 +1,421 @@
+//	The MIT License (MIT)
+//
+//	Copyright (c) 2016, Cagatay Dogan
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//		The above copyright notice and this permission notice shall be included in
+//		all copies or substantial portions of the Software.
+//
+//		THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//		IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//		FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//		AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//		LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//		OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+//		THE SOFTWARE.
+
+package logquery
+
+import (
+    "bufio"
+    "bytes"
+    "context"
+    "encoding/json"
+    "fmt"
+    "io"
+    "os"
+    "regexp"
+    "strconv"
+    "strings"
+    "time"
+    "github.com/ocdogan/goutils/logmanager"
+)
+
+// Format defines the format of the log lines to read
+type Format byte
+
+const (
+    // AutoFormat detects the format of every line, the lines starting with { are read as JSON
+    AutoFormat Format = iota
+    // JSONFormat reads the lines written in logmanager.JSONFormat
+    JSONFormat
+    // TextFormat reads the lines written in logmanager.TextFormat
+    TextFormat
+)
+
+// ParseFormat parses the format names auto, json and text
+func ParseFormat(s string) (Format, error) {
+    switch strings.ToLower(s) {
+    case "", "auto":
+        return AutoFormat, nil
+    case "json":
+        return JSONFormat, nil
+    case "text", "logfmt":
+        return TextFormat, nil
+    }
+    return AutoFormat, fmt.Errorf("logquery: unknown format %q", s)
+}
+
+// ArgOp is the comparison used by an ArgPredicate
+type ArgOp string
+
+const (
+    // OpExists matches if the arg exists
+    OpExists ArgOp = ""
+    // OpEqual matches if the arg is equal to the value
+    OpEqual ArgOp = "="
+    // OpNotEqual matches if the arg is missing or not equal to the value
+    OpNotEqual ArgOp = "!="
+    // OpMatch matches if the arg matches the regular expression value
+    OpMatch ArgOp = "~"
+    // OpGreater matches if the arg is numerically greater than the value
+    OpGreater ArgOp = ">"
+    // OpGreaterOrEqual matches if the arg is numerically greater than or equal to the value
+    OpGreaterOrEqual ArgOp = ">="
+    // OpLess matches if the arg is numerically less than the value
+    OpLess ArgOp = "<"
+    // OpLessOrEqual matches if the arg is numerically less than or equal to the value
+    OpLessOrEqual ArgOp = "<="
+)
+
+// ArgPredicate filters the entries by an arg, nested args are addressed with dotted keys
+type ArgPredicate struct {
+    Key string
+    Op ArgOp
+    Value string
+    re *regexp.Regexp
+    num float64
+}
+
+// ParseArgPredicate parses predicates like key, key=value, key!=value, key~regexp, key>10 and key<=10
+func ParseArgPredicate(s string) (*ArgPredicate, error) {
+    pos := strings.IndexAny(s, "=!~<>")
+    if pos < 0 {
+        if s == "" {
+            return nil, fmt.Errorf("logquery: empty arg predicate")
+        }
+        return &ArgPredicate{ Key: s, Op: OpExists }, nil
+    }
+
+    result := &ArgPredicate{ Key: s[:pos] }
+    rest := s[pos:]
+    for _, op := range []ArgOp{ OpNotEqual, OpGreaterOrEqual, OpLessOrEqual, OpEqual, OpMatch, OpGreater, OpLess } {
+        if strings.HasPrefix(rest, string(op)) {
+            result.Op = op
+            result.Value = rest[len(op):]
+            break
+        }
+    }
+
+    if result.Key == "" || result.Op == OpExists {
+        return nil, fmt.Errorf("logquery: invalid arg predicate %q", s)
+    }
+
+    var err error
+    switch result.Op {
+    case OpMatch:
+        result.re, err = regexp.Compile(result.Value)
+    case OpGreater, OpGreaterOrEqual, OpLess, OpLessOrEqual:
+        result.num, err = strconv.ParseFloat(result.Value, 64)
+    }
+    if err != nil {
+        return nil, fmt.Errorf("logquery: invalid arg predicate %q: %v", s, err)
+    }
+    return result, nil
+}
+
+// Match returns if the args of the entry satisfy the predicate
+func (p *ArgPredicate) Match(entry *logmanager.LogEntry) bool {
+    value, ok := LookupArg(entry.Args(), p.Key)
+    if !ok {
+        return p.Op == OpNotEqual
+    }
+
+    switch p.Op {
+    case OpExists:
+        return true
+    case OpEqual:
+        return argString(value) == p.Value
+    case OpNotEqual:
+        return argString(value) != p.Value
+    case OpMatch:
+        return p.re.MatchString(argString(value))
+    }
+
+    num, err := strconv.ParseFloat(argString(value), 64)
+    if err != nil {
+        return false
+    }
+    switch p.Op {
+    case OpGreater:
+        return num > p.num
+    case OpGreaterOrEqual:
+        return num >= p.num
+    case OpLess:
+        return num < p.num
+    case OpLessOrEqual:
+        return num <= p.num
+    }
+    return false
+}
+
+// LookupArg finds the arg with the given key, the dotted keys are first looked up
+// as flattened keys as in text logs and then as paths of nested maps as in JSON logs
+func LookupArg(args map[string]interface{}, key string) (interface{}, bool) {
+    if args == nil {
+        return nil, false
+    }
+    if value, ok := args[key]; ok {
+        return value, true
+    }
+
+    pos := strings.IndexByte(key, '.')
+    for pos > -1 {
+        if m, ok := args[key[:pos]].(map[string]interface{}); ok {
+            if value, ok := LookupArg(m, key[pos+1:]); ok {
+                return value, true
+            }
+        }
+        next := strings.IndexByte(key[pos+1:], '.')
+        if next < 0 {
+            break
+        }
+        pos += next + 1
+    }
+    return nil, false
+}
+
+func argString(value interface{}) string {
+    switch v := value.(type) {
+    case nil:
+        return ""
+    case string:
+        return v
+    case json.Number:
+        return v.String()
+    }
+    return fmt.Sprint(value)
+}
+
+// Query selects the log entries, the zero values of the fields match every entry
+type Query struct {
+    // Levels is the set of the levels to match
+    Levels logmanager.LogLevel
+    // Since excludes the entries created before
+    Since time.Time
+    // Until excludes the entries created after
+    Until time.Time
+    // ID matches the entry with the given id
+    ID string
+    // Message matches the entries whose message matches the regular expression
+    Message *regexp.Regexp
+    // Args are the arg predicates which must all match
+    Args []*ArgPredicate
+}
+
+// Match returns if the entry satisfies the query
+func (q *Query) Match(entry *logmanager.LogEntry) bool {
+    if q == nil {
+        return true
+    }
+    if q.Levels != logmanager.LogLevel(0) && !q.Levels.Has(entry.Level()) {
+        return false
+    }
+    if !q.Since.IsZero() && entry.Time().Before(q.Since) {
+        return false
+    }
+    if !q.Until.IsZero() && entry.Time().After(q.Until) {
+        return false
+    }
+    if q.ID != "" && !strings.EqualFold(q.ID, entry.ID()) {
+        return false
+    }
+    if q.Message != nil && !q.Message.MatchString(entry.Message()) {
+        return false
+    }
+    for _, p := range q.Args {
+        if !p.Match(entry) {
+            return false
+        }
+    }
+    return true
+}
+
+// ParseLine parses a single log line in the given format
+func ParseLine(line []byte, format Format) (*logmanager.LogEntry, error) {
+    if format == AutoFormat {
+        format = TextFormat
+        if trimmed := bytes.TrimLeft(line, " \t"); len(trimmed) > 0 && trimmed[0] == '{' {
+            format = JSONFormat
+        }
+    }
+
+    if format == JSONFormat {
+        return logmanager.ParseJSON(line)
+    }
+    return logmanager.ParseText(line)
+}
+
+// ParseError is returned by Reader.Next for the lines which cannot be parsed
+type ParseError struct {
+    Line int
+    Err error
+}
+
+func (e *ParseError) Error() string {
+    return fmt.Sprintf("logquery: line %d: %v", e.Line, e.Err)
+}
+
+// Reader reads the log entries line by line
+type Reader struct {
+    reader *bufio.Reader
+    format Format
+    line int
+}
+
+// NewReader creates a reader which reads the entries in the given format from r
+func NewReader(r io.Reader, format Format) *Reader {
+    return &Reader{
+        reader: bufio.NewReaderSize(r, 64*1024),
+        format: format,
+    }
+}
+
+// Next returns the next entry, io.EOF is returned at the end of the input.
+// A line which cannot be parsed is reported with its line number and can be skipped
+// by calling Next again.
+func (r *Reader) Next() (*logmanager.LogEntry, error) {
+    for {
+        line, err := r.reader.ReadBytes('\n')
+        if len(line) == 0 && err != nil {
+            return nil, err
+        }
+
+        r.line++
+        line = bytes.TrimRight(line, "\r\n")
+        if len(bytes.TrimSpace(line)) == 0 {
+            if err != nil {
+                return nil, err
+            }
+            continue
+        }
+
+        entry, perr := ParseLine(line, r.format)
+        if perr != nil {
+            return nil, &ParseError{ Line: r.line, Err: perr }
+        }
+        return entry, nil
+    }
+}
+
+// Scan reads the entries from r and calls fn with the entries matching the query,
+// the lines which cannot be parsed are skipped
+func Scan(r io.Reader, format Format, q *Query, fn func(*logmanager.LogEntry) error) error {
+    reader := NewReader(r, format)
+    for {
+        entry, err := reader.Next()
+        if err == io.EOF {
+            return nil
+        }
+        if err != nil {
+            if _, ok := err.(*ParseError); ok {
+                continue
+            }
+            return err
+        }
+        if q.Match(entry) {
+            if err = fn(entry); err != nil {
+                return err
+            }
+        }
+    }
+}
+
+// Follow reads the file like tail -f, calls fn with the entries matching the query and
+// waits for new lines polling the file in the given interval until the context is done.
+// The file is read again from the start if it is truncated or replaced.
+func Follow(ctx context.Context, path string, format Format, q *Query, interval time.Duration,
+    fn func(*logmanager.LogEntry) error) error {
+    if interval <= 0 {
+        interval = 250*time.Millisecond
+    }
+
+    var (
+        file *os.File
+        info os.FileInfo
+        offset int64
+        pending []byte
+    )
+    defer func() {
+        if file != nil {
+            file.Close()
+        }
+    }()
+
+    buf := make([]byte, 64*1024)
+    for {
+        if file == nil {
+            var err error
+            if file, err = os.Open(path); err != nil && !os.IsNotExist(err) {
+                return err
+            }
+            if file != nil {
+                info, _ = file.Stat()
+                offset, pending = 0, pending[:0]
+            }
+        }
+
+        if file != nil {
+            for {
+                n, err := file.Read(buf)
+                if n > 0 {
+                    offset += int64(n)
+                    pending = append(pending, buf[:n]...)
+                    
+                    start := 0
+                    for {
+                        pos := bytes.IndexByte(pending[start:], '\n')
+                        if pos < 0 {
+                            break
+                        }
+                        line := bytes.TrimRight(pending[start:start+pos], "\r")
+                        start += pos + 1
+                        
+                        if len(bytes.TrimSpace(line)) > 0 {
+                            if entry, perr := ParseLine(line, format); perr == nil && q.Match(entry) {
+                                if err := fn(entry); err != nil {
+                                    return err
+                                }
+                            }
+                        }
+                    }
+                    pending = pending[:copy(pending, pending[start:])]
+                }
+                if err == io.EOF || n == 0 {
+                    break
+                }
+                if err != nil {
+                    return err
+                }
+            }
+
+            if current, err := os.Stat(path); err != nil || info == nil || !os.SameFile(info, current) || current.Size() < offset {
+                file.Close()
+                file = nil
+                continue
+            }
+        }
+
+        select {
+        case <-ctx.Done():
+            return nil
+        case <-time.After(interval):
+        }
+    }
+}
@@ -0,0 +1,153 @@
+//	The MIT License (MIT)
+//
+//	Copyright (c) 2016, Cagatay Dogan
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//		The above copyright notice and this permission notice shall be included in
+//		all copies or substantial portions of the Software.
+//
+//		THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//		IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//		FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//		AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//		LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//		OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+//		THE SOFTWARE.
+
+package logquery
+
+import (
+    "context"
+    "fmt"
+    "os"
+    "path/filepath"
+    "regexp"
+    "strings"
+    "testing"
+    "time"
+    "github.com/ocdogan/goutils/logmanager"
+)
+
+const testLog = `{"id":"A","time":"2016-05-04T03:02:01Z","duration":0,"level":"error","message":"db timeout","stack":"","args":{"db":{"name":"pg"},"retry":5}}
+id=B time=2016-05-04T03:02:02Z duration=1s level=info message="user login" user=42
+
+not a "log line
+id=C time=2016-05-04T03:02:03Z duration=0s level=warning message="db slow" db.name=mysql retry=1
+`
+
+func TestScan(t *testing.T) {
+    fmt.Println("\nTestScan\n~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~")
+
+    mustArg := func(s string) *ArgPredicate {
+        p, err := ParseArgPredicate(s)
+        if err != nil {
+            t.Fatal(err)
+        }
+        return p
+    }
+
+    tests := []struct{
+        query *Query
+        expected string
+    }{
+        { nil, "A,B,C" },
+        { &Query{ Levels: logmanager.LevelError | logmanager.LevelWarning }, "A,C" },
+        { &Query{ Message: regexp.MustCompile("^db") }, "A,C" },
+        { &Query{ ID: "b" }, "B" },
+        { &Query{ Args: []*ArgPredicate{ mustArg("db.name=pg") } }, "A" },
+        { &Query{ Args: []*ArgPredicate{ mustArg("db.name~^(pg|mysql)$"), mustArg("retry>=2") } }, "A" },
+        { &Query{ Args: []*ArgPredicate{ mustArg("user") } }, "B" },
+        { &Query{ Args: []*ArgPredicate{ mustArg("user!=42") } }, "A,C" },
+    }
+
+    for _, test := range tests {
+        var ids []string
+        err := Scan(strings.NewReader(testLog), AutoFormat, test.query, func(entry *logmanager.LogEntry) error {
+            ids = append(ids, entry.ID())
+            return nil
+        })
+        if err != nil {
+            t.Fatal(err)
+        }
+        if actual := strings.Join(ids, ","); actual != test.expected {
+            t.Errorf("query %+v: expected %s, got %s", test.query, test.expected, actual)
+        }
+    }
+}
+
+func TestFollow(t *testing.T) {
+    fmt.Println("\nTestFollow\n~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~")
+
+    dir := t.TempDir()
+    path := filepath.Join(dir, "app.log")
+    if err := os.WriteFile(path, []byte(testLog), 0644); err != nil {
+        t.Fatal(err)
+    }
+
+    ctx, cancel := context.WithCancel(context.Background())
+    ids := make(chan string, 10)
+    done := make(chan error, 1)
+    go func() {
+        done <- Follow(ctx, path, AutoFormat, &Query{ Levels: logmanager.LevelError | logmanager.LevelWarning }, 
+            5*time.Millisecond, func(entry *logmanager.LogEntry) error {
+                ids <- entry.ID()
+                return nil
+            })
+    }()
+
+    expect := func(expected ...string) {
+        for _, e := range expected {
+            select {
+            case id := <-ids:
+                if id != e {
+                    t.Errorf("expected %s, got %s", e, id)
+                }
+            case <-time.After(2*time.Second):
+                t.Fatalf("timeout waiting for %s", e)
+            }
+        }
+    }
+    expect("A", "C")
+
+    // a line is reported after it is completed, the lines not matching the query are skipped
+    file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+    if err != nil {
+        t.Fatal(err)
+    }
+    file.WriteString("id=D time=2016-05-04T03:02:04Z level=info message=skipped\nid=E time=2016-05-04T03:02:05Z lev")
+    time.Sleep(20*time.Millisecond)
+    file.WriteString("el=error message=appended\n")
+    file.Close()
+    expect("E")
+
+    // the replaced file is read from the start
+    replaced := filepath.Join(dir, "app.log.new")
+    if err = os.WriteFile(replaced, []byte("id=F time=2016-05-04T03:02:06Z level=warning message=rotated\n"), 0644); err != nil {
+        t.Fatal(err)
+    }
+    if err = os.Rename(replaced, path); err != nil {
+        t.Fatal(err)
+    }
+    expect("F")
+
+    cancel()
+    select {
+    case err = <-done:
+        if err != nil {
+            t.Error(err)
+        }
+    case <-time.After(2*time.Second):
+        t.Fatal("expected Follow to return after the context is done")
+    }
+    select {
+    case id := <-ids:
+        t.Errorf("unexpected entry %s", id)
+    default:
+    }
+}
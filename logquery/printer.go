@@ -0,0 +1,119 @@
+//	The MIT License (MIT)
+//
+//	Copyright (c) 2016, Cagatay Dogan
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//		The above copyright notice and this permission notice shall be included in
+//		all copies or substantial portions of the Software.
+//
+//		THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//		IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//		FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//		AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//		LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//		OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+//		THE SOFTWARE.
+
+package logquery
+
+import (
+    "fmt"
+    "io"
+    "strings"
+    "sync"
+    "text/tabwriter"
+    "time"
+    "github.com/ocdogan/goutils/logmanager"
+)
+
+// OutputFormat defines how the Printer writes the entries
+type OutputFormat byte
+
+const (
+    // OutputJSON writes the entries as JSON lines
+    OutputJSON OutputFormat = iota
+    // OutputText writes the entries as logfmt lines
+    OutputText
+    // OutputTable writes the entries as an aligned table
+    OutputTable
+)
+
+// ParseOutputFormat parses the output format names json, text and table
+func ParseOutputFormat(s string) (OutputFormat, error) {
+    switch strings.ToLower(s) {
+    case "json":
+        return OutputJSON, nil
+    case "", "text", "logfmt":
+        return OutputText, nil
+    case "table":
+        return OutputTable, nil
+    }
+    return OutputText, fmt.Errorf("logquery: unknown output format %q", s)
+}
+
+// Printer writes the entries to the output in the given format, it is safe for concurrent use
+type Printer struct {
+    sync.Mutex
+    w io.Writer
+    format OutputFormat
+    table *tabwriter.Writer
+    buf []byte
+}
+
+// NewPrinter creates a printer which writes into w
+func NewPrinter(w io.Writer, format OutputFormat) *Printer {
+    result := &Printer{
+        w: w,
+        format: format,
+    }
+    if format == OutputTable {
+        result.table = tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+        fmt.Fprintln(result.table, "TIME\tLEVEL\tID\tMESSAGE\tARGS")
+    }
+    return result
+}
+
+// Print writes the entry
+func (p *Printer) Print(entry *logmanager.LogEntry) error {
+    p.Lock()
+    defer p.Unlock()
+
+    switch p.format {
+    case OutputJSON:
+        p.buf = append(entry.AppendJSON(p.buf[:0]), '\n')
+    case OutputTable:
+        p.buf = append(p.buf[:0], entry.Time().Format(time.RFC3339Nano)...)
+        p.buf = append(p.buf, '\t')
+        p.buf = append(p.buf, entry.Level().String()...)
+        p.buf = append(p.buf, '\t')
+        p.buf = append(p.buf, entry.ID()...)
+        p.buf = append(p.buf, '\t')
+        p.buf = append(p.buf, strings.NewReplacer("\t", " ", "\n", " ").Replace(entry.Message())...)
+        p.buf = append(p.buf, '\t')
+        p.buf = append(logmanager.AppendLogfmt(p.buf, entry.Args()), '\n')
+        _, err := p.table.Write(p.buf)
+        return err
+    default:
+        p.buf = append(entry.AppendText(p.buf[:0]), '\n')
+    }
+
+    _, err := p.w.Write(p.buf)
+    return err
+}
+
+// Flush writes the buffered table rows, it should be called after the last entry
+func (p *Printer) Flush() error {
+    p.Lock()
+    defer p.Unlock()
+
+    if p.table != nil {
+        return p.table.Flush()
+    }
+    return nil
+}
@@ -0,0 +1,85 @@
+//	The MIT License (MIT)
+//
+//	Copyright (c) 2016, Cagatay Dogan
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//		The above copyright notice and this permission notice shall be included in
+//		all copies or substantial portions of the Software.
+//
+//		THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//		IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//		FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//		AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//		LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//		OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+//		THE SOFTWARE.
+
+package logquery
+
+import (
+    "bytes"
+    "fmt"
+    "strings"
+    "testing"
+)
+
+func TestPrinterTable(t *testing.T) {
+    fmt.Println("\nTestPrinterTable\n~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~")
+
+    var out bytes.Buffer
+    printer := NewPrinter(&out, OutputTable)
+
+    for _, line := range []string{ 
+        `id=A time=2016-05-04T03:02:01Z level=error message="db\ttimeout\nretrying" retry=5 db.name=pg`,
+        `id=LONGER time=2016-05-04T03:02:02.5Z level=info message=ok`,
+    } {
+        entry, err := ParseLine([]byte(line), TextFormat)
+        if err != nil {
+            t.Fatal(err)
+        }
+        if err = printer.Print(entry); err != nil {
+            t.Fatal(err)
+        }
+    }
+    if out.Len() != 0 {
+        t.Error("expected the rows to be buffered until Flush")
+    }
+    if err := printer.Flush(); err != nil {
+        t.Fatal(err)
+    }
+
+    lines := strings.Split(strings.TrimRight(out.String(), "\n"), "\n")
+    if len(lines) != 3 {
+        t.Fatalf("expected a header and 2 rows, got %q", out.String())
+    }
+
+    expected := [][]string{
+        { "TIME", "LEVEL", "ID", "MESSAGE", "ARGS" },
+        { "2016-05-04T03:02:01Z", "error", "A", "db timeout retrying", "db.name=pg retry=5" },
+        { "2016-05-04T03:02:02.5Z", "info", "LONGER", "ok", "" },
+    }
+    // every column starts at the same position in all the lines
+    for column := range expected[0] {
+        pos := -1
+        for i, line := range lines {
+            value := expected[i][column]
+            if value == "" {
+                continue
+            }
+            at := strings.Index(line, value)
+            if at < 0 {
+                t.Fatalf("expected %q in %q", value, line)
+            }
+            if pos >= 0 && at != pos {
+                t.Errorf("column %s is not aligned:\n%s", expected[0][column], out.String())
+            }
+            pos = at
+        }
+    }
+}
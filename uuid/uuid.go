@@ -26,6 +26,7 @@ import (
     "crypto/rand"
     "encoding/binary"
     "encoding/hex"
+    mrand "math/rand/v2"
     "net"
     "os"
     "sync"
@@ -35,6 +36,7 @@ import (
 
 const (
     sep = byte('-')
+    upperHex = "0123456789ABCDEF"
 )
 
 type UUID [16]byte
@@ -56,6 +58,22 @@ func (uuid UUID) String() string {
 	return strings.ToUpper(string(result))
 }
 
+// AppendString appends the string form of the uuid to dst without allocating
+func (uuid UUID) AppendString(dst []byte) []byte {
+    for i, b := range uuid {
+        if i == 4 || i == 6 || i == 8 || i == 10 {
+            dst = append(dst, sep)
+        }
+        dst = append(dst, upperHex[b>>4], upperHex[b&0x0f])
+    }
+    return dst
+}
+
+// IsZero returns if the uuid is not initialized
+func (uuid UUID) IsZero() bool {
+    return uuid == UUID{}
+}
+
 type seqID struct {
     sync.Mutex
     id uint64
@@ -82,6 +100,61 @@ func NewUUID() (*UUID, error) {
     return uuid, nil
 }
 
+// NewV4 creates a random RFC 4122 version 4 UUID
+func NewV4() (*UUID, error) {
+    uuid := new(UUID)
+    _, err := rand.Read(uuid[:])
+    if err != nil {
+        return nil, err
+    }
+    
+    uuid.setVersion(4)
+    return uuid, nil
+}
+
+// NewV7 creates an RFC 9562 version 7 UUID which is ordered by the given time
+func NewV7(t time.Time) (*UUID, error) {
+    uuid := new(UUID)
+    _, err := rand.Read(uuid[6:])
+    if err != nil {
+        return nil, err
+    }
+    
+    ms := uint64(t.UnixMilli())
+    uuid[0] = byte(ms >> 40)
+    uuid[1] = byte(ms >> 32)
+    uuid[2] = byte(ms >> 24)
+    uuid[3] = byte(ms >> 16)
+    uuid[4] = byte(ms >> 8)
+    uuid[5] = byte(ms)
+    
+    uuid.setVersion(7)
+    return uuid, nil
+}
+
+func (uuid *UUID) setVersion(version byte) {
+    uuid[6] = (uuid[6] & 0x0f) | (version << 4)
+    uuid[8] = (uuid[8] & 0x3f) | 0x80
+}
+
+// NewFastUUID creates a UUID without reading crypto/rand and without any heap allocation.
+// It is unique enough to identify records such as log entries but must not be used
+// where the value should be unpredictable.
+func NewFastUUID() UUID {
+    var uuid UUID
+    binary.BigEndian.PutUint64(uuid[0:8], mrand.Uint64())
+    binary.BigEndian.PutUint64(uuid[8:16], mrand.Uint64())
+    
+    var date [8]byte
+    binary.BigEndian.PutUint64(date[:], now + nextTick())
+
+    uuid.xor(euid, 0)
+    uuid.xor(date[:], 8)
+    uuid.xor(haddr, 4)
+    
+    return uuid
+}
+
 func (uuid *UUID) xor(bytes []byte, shift int) {
     for i, b := range bytes {
         pos := i + shift
@@ -92,14 +165,17 @@ func (uuid *UUID) xor(bytes []byte, shift int) {
     }
 }
 
-func getDate() []byte {    
+func nextTick() uint64 {
     id.Lock()
     id.id++
     tick := id.id
     id.Unlock()
-    
+    return tick
+}
+
+func getDate() []byte {    
     result := make([]byte, 8) 
-    binary.BigEndian.PutUint64(result, now + tick)
+    binary.BigEndian.PutUint64(result, now + nextTick())
 
     return result
 }